@@ -0,0 +1,56 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Litebrowsers/donatello/internal/admin"
+	"github.com/Litebrowsers/donatello/internal/db"
+)
+
+// challengeCommand inspects stored challenges.
+func challengeCommand(args []string) {
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Fprintln(os.Stderr, "usage: donatello challenge show <id> [flags]")
+		os.Exit(2)
+	}
+	id, args := leadingArgument(args[1:])
+	cfg := loadConfig("donatello challenge show", args)
+	if id == "" || id == "-" {
+		fatal("invalid arguments", errors.New("challenge ID is required"))
+	}
+
+	if err := db.InitDB(cfg.Database.Path); err != nil {
+		fatal("failed to connect database", err)
+	}
+	stored, err := admin.NewStore(db.DB).Challenge(context.Background(), id)
+	if err != nil {
+		fatal("failed to load challenge", err)
+	}
+	detail, err := admin.NewDetail(stored, cfg.Challenge.CanvasSize)
+	if err != nil {
+		fatal("failed to describe challenge", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(detail); err != nil {
+		fatal("failed to write challenge", err)
+	}
+}
@@ -0,0 +1,79 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Litebrowsers/donatello/internal/db"
+	"github.com/Litebrowsers/donatello/internal/similarity"
+)
+
+// cluster groups the recently answered challenges into renderer classes and prints a summary of each class.
+func cluster(args []string) {
+	var k, iterations int
+	var seed uint64
+	cfg := loadConfig("donatello cluster", args, func(fs *flag.FlagSet) {
+		fs.IntVar(&k, "k", 8, "number of clusters")
+		fs.IntVar(&iterations, "iterations", 100, "maximum number of k-means rounds")
+		fs.Uint64Var(&seed, "seed", 1, "random seed of the initial centroids")
+	})
+	if k < 1 || iterations < 1 {
+		fatal("invalid arguments", fmt.Errorf("-k and -iterations must be positive"))
+	}
+
+	if err := db.InitDB(cfg.Database.Path); err != nil {
+		fatal("failed to connect database", err)
+	}
+	points, err := similarity.NewSearcher(db.DB, cfg.Admin.SimilarityWindow).Points(context.Background())
+	if err != nil {
+		fatal("failed to load feature vectors", err)
+	}
+	clusters := similarity.NewIndex(points).Cluster(k, iterations, rand.New(rand.NewPCG(seed, seed)))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "CLUSTER\tCHALLENGES\tFINGERPRINTS\tTOP FINGERPRINT\tSHARE")
+	for i, c := range clusters {
+		top, count := topFingerprint(c.Fingerprints())
+		_, _ = fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%.2f\n", i+1, len(c.Members), len(c.Fingerprints()), shorten(top),
+			float64(count)/float64(len(c.Members)))
+	}
+	_ = w.Flush()
+}
+
+// topFingerprint returns the most frequent fingerprint in counts and its count. Ties go to the smaller fingerprint.
+func topFingerprint(counts map[string]int) (string, int) {
+	var top string
+	var best int
+	for fingerprint, count := range counts {
+		if count > best || count == best && cmp.Less(fingerprint, top) {
+			top, best = fingerprint, count
+		}
+	}
+	return top, best
+}
+
+// shorten truncates a fingerprint hash for display.
+func shorten(fingerprint string) string {
+	if len(fingerprint) > 16 {
+		return fingerprint[:16]
+	}
+	return fingerprint
+}
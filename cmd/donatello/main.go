@@ -15,306 +15,302 @@ thor betson at gmail dot com
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
-	"math/rand"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/Litebrowsers/donatello/internal/admin"
+	"github.com/Litebrowsers/donatello/internal/certs"
+	"github.com/Litebrowsers/donatello/internal/cleanup"
+	"github.com/Litebrowsers/donatello/internal/clock"
+	"github.com/Litebrowsers/donatello/internal/config"
 	"github.com/Litebrowsers/donatello/internal/db"
-	"github.com/Litebrowsers/donatello/internal/models"
-	"github.com/Litebrowsers/donatello/internal/tasks"
+	"github.com/Litebrowsers/donatello/internal/health"
+	"github.com/Litebrowsers/donatello/internal/logging"
+	"github.com/Litebrowsers/donatello/internal/metrics"
+	"github.com/Litebrowsers/donatello/internal/server"
+	"github.com/Litebrowsers/donatello/internal/similarity"
+	"github.com/Litebrowsers/donatello/internal/tracing"
+	"github.com/Litebrowsers/donatello/internal/web"
+	"github.com/Litebrowsers/donatello/pkg/challenge"
+	"github.com/Litebrowsers/donatello/pkg/verdict"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-	"golang.org/x/time/rate"
 )
 
-var challengeExpiration time.Duration
+// tracingShutdownTimeout limits flushing the buffered spans at exit.
+const tracingShutdownTimeout = 5 * time.Second
 
-// RateLimitMiddleware returns a gin.HandlerFunc that limits requests.
-func RateLimitMiddleware(limit rate.Limit, burst int) gin.HandlerFunc {
-	limiter := rate.NewLimiter(limit, burst)
-	return func(c *gin.Context) {
-		if !limiter.Allow() {
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
-			return
-		}
-		c.Next()
-	}
-}
+func main() {
+	slog.SetDefault(logging.New(os.Stdout, slog.LevelInfo, false))
 
-func cleanupExpiredChallenges() {
-	interval := challengeExpiration * 2
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		log.Println("Cleaning up expired challenges...")
-		// Update expired challenges that are still pending (JavaScript is NULL)
-		result := db.DB.Model(&models.Challenge{}).
-			Where("expires_at < ? AND java_script IS NULL", time.Now()).
-			Update("java_script", false)
-
-		if result.Error != nil {
-			log.Printf("Error cleaning up expired challenges: %v", result.Error)
-			continue
-		}
+	args := os.Args[1:]
+	command := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
 
-		if result.RowsAffected > 0 {
-			log.Printf("Marked %d challenges as no-js.", result.RowsAffected)
+	switch command {
+	case "serve":
+		cfg := loadConfig("donatello serve", args)
+		serve(cfg)
+	case "config":
+		if len(args) == 0 || args[0] != "print" {
+			fmt.Fprintln(os.Stderr, "usage: donatello config print [flags]")
+			os.Exit(2)
 		}
+		cfg := loadConfig("donatello config print", args[1:])
+		fmt.Println(cfg.String())
+	case "cluster":
+		cluster(args)
+	case "render":
+		render(args)
+	case "task":
+		taskCommand(args)
+	case "challenge":
+		challengeCommand(args)
+	case "help":
+		printUsage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", command)
+		printUsage()
+		os.Exit(2)
 	}
 }
 
-func main() {
-	err := db.InitDB()
-	if err != nil {
-		log.Fatalf("failed to connect database: %v", err)
+// loadConfig loads the configuration or exits when it is invalid.
+func loadConfig(name string, args []string, extra ...func(fs *flag.FlagSet)) *config.Config {
+	cfg, err := config.Load(name, args, os.Getenv, extra...)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
 	}
-	err = db.DB.AutoMigrate(&models.Task{}, &models.Challenge{})
 	if err != nil {
-		log.Fatalf("failed to migrate database: %v", err)
-	}
-
-	// Get challenge expiration from environment variable or use default
-	expirationStr := os.Getenv("CHALLENGE_EXPIRATION")
-	challengeExpiration = 500 * time.Millisecond // Default expiration
-
-	if expirationStr != "" {
-		parsedExpiration, err := time.ParseDuration(expirationStr)
-		if err == nil {
-			challengeExpiration = parsedExpiration
-		} else {
-			log.Printf("Invalid CHALLENGE_EXPIRATION format: %s. Using default 1m.", expirationStr)
-		}
-	}
-
-	go cleanupExpiredChallenges()
-
-	router := gin.Default()
-
-	// Configure port
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+		fatal("failed to load configuration", err)
 	}
+	return cfg
+}
 
-	canvasSize := 20
-	canvasSizeStr := os.Getenv("CANVAS_SIZE")
-	if canvasSizeStr != "" {
-		canvasSize, _ = strconv.Atoi(canvasSizeStr)
-	}
+// fatal logs msg with err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
 
-	// Apply Rate Limiter Middleware
-	router.Use(RateLimitMiddleware(rate.Every(time.Second/5), 10))
+func printUsage() {
+	fmt.Fprintln(os.Stderr, `usage: donatello [command] [flags]
 
-	router.GET("/challenge", func(c *gin.Context) {
-		id := c.Query("id")
-		if id == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "id query parameter is required"})
-			return
-		}
+commands:
+  serve         run the server (default)
+  config print  print the effective configuration
+  cluster       group answered challenges into renderer classes
+  render        draw a task or a stored challenge as PNG
+  task          generate, parse, render and hash tasks offline
+  challenge     show a stored challenge
+  help          show this help
 
-		var challenge models.Challenge
-		result := db.DB.First(&challenge, "id = ?", id)
-		if result.Error != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
-			return
-		}
+flags:`)
+	config.Usage(os.Stderr)
+}
 
-		gridOptions := []int{2, 4, 10}
-		gridSize := gridOptions[rand.Intn(len(gridOptions))]
-		color1 := tasks.GenerateRandomColor()
-		color2 := tasks.GenerateRandomColor()
-		chessboardTask := tasks.Chessboard{
-			GridSize: gridSize,
-			Color1:   color1,
-			Color2:   color2,
+// secondTaskPoolCheck checks that the second task pool is not empty.
+func secondTaskPoolCheck(store challenge.Store) health.Check {
+	return health.Check{Name: "second_task_pool", Run: func(ctx context.Context) error {
+		_, err := store.SecondTask(ctx)
+		if errors.Is(err, challenge.ErrNotFound) {
+			return errors.New("second task pool is empty")
 		}
+		return err
+	}}
+}
 
-		numShapesFirstTask := rand.Intn(6) + 1
-		randomShapesFirstTask := tasks.GenerateRandomEvenSizedPrimitives(canvasSize, numShapesFirstTask)
-
-		allShapes := append([]tasks.Shape{chessboardTask}, randomShapesFirstTask...)
-
-		firstTaskGenerator := tasks.NewTaskGenerator(allShapes...)
-
-		// Server-side drawing
-		canvas := tasks.NewCanvas(canvasSize, canvasSize)
-		err = canvas.DrawShapes(allShapes)
-		if err != nil {
-			return
-		}
-		hashes, err := canvas.CalculateHashes()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate hashes"})
-			return
-		}
+// verdictKey returns the key signing verdict tokens, generating a random one when no secret is configured.
+func verdictKey(secret string) (*verdict.Key, error) {
+	if secret != "" {
+		return verdict.NewKey([]byte(secret))
+	}
+	random := make([]byte, verdict.MinSecretLength)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	slog.Warn("no verdict secret configured, using a random one; tokens become invalid on restart")
+	return verdict.NewKey(random)
+}
 
-		combinedHash, err := canvas.CalculateCombinedHash(hashes)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate hashes"})
-			return
-		}
+// serve runs the server until SIGINT or SIGTERM is received.
+func serve(cfg *config.Config) {
+	level, _ := logging.ParseLevel(cfg.Log.Level)
+	logger := logging.New(os.Stdout, level, cfg.Log.Debug)
+	slog.SetDefault(logger)
+	if !cfg.Log.Debug {
+		gin.SetMode(gin.ReleaseMode)
+	}
 
-		firstTask := firstTaskGenerator.GenerateTask()
-
-		var secondTask models.Task
-		result = db.DB.Where("name = ?", "secondTask").First(&secondTask)
-		if result.Error != nil {
-			numShapesSecondTask := rand.Intn(6) + 1
-			randomShapesSecondTask := tasks.GenerateRandomShapes(canvasSize, numShapesSecondTask)
-			secondTaskGenerator := tasks.NewTaskGenerator(randomShapesSecondTask...)
-			secondTask.Value = secondTaskGenerator.GenerateTask()
-			secondTask.Name = "secondTask"
-			db.DB.Create(&secondTask)
-		}
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
 
-		secondTaskShapes, err := tasks.ParseTask(secondTask.Value)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse second task"})
-			return
-		}
+	err = db.InitDB(cfg.Database.Path)
+	if err != nil {
+		fatal("failed to connect database", err)
+	}
+	err = challenge.Migrate(db.DB)
+	if err != nil {
+		fatal("failed to migrate database", err)
+	}
 
-		secondTaskCanvas := tasks.NewCanvas(canvasSize, canvasSize)
-		err = secondTaskCanvas.DrawShapes(secondTaskShapes)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse second task"})
-			return
-		}
-		secondTaskHashes, err := secondTaskCanvas.CalculateHashes()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate hashes for second task"})
-			return
-		}
+	assets, err := web.New(cfg.Web.OverrideDir)
+	if err != nil {
+		fatal("failed to load web resources", err)
+	}
+	if cfg.Web.OverrideDir != "" {
+		logger.Info("serving web resources from disk", "dir", cfg.Web.OverrideDir)
+	}
 
-		secondTaskCombinedHash, err := secondTaskCanvas.CalculateCombinedHash(secondTaskHashes)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate combined hash for second task"})
-			return
-		}
+	verdicts, err := verdictKey(cfg.Verdict.Secret)
+	if err != nil {
+		fatal("failed to create verdict key", err)
+	}
 
-		challenge.Task = firstTask
-		challenge.ExpectedHash = combinedHash
-		challenge.Fingerprint = secondTaskCombinedHash
+	clk := clock.Real{}
+	store := challenge.NewGormStore(db.DB)
+	appMetrics := metrics.New()
+	if err := appMetrics.InstrumentDB(db.DB); err != nil {
+		fatal("failed to instrument database", err)
+	}
 
-		result = db.DB.Save(&challenge)
-		if result.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save task to cache"})
-			return
-		}
+	// Background tasks get their own context so they keep running while in-flight requests drain
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
 
-		c.JSON(http.StatusOK, gin.H{
-			"id":          id,
-			"first_task":  firstTask,
-			"second_task": secondTask.Value,
-		})
+	cleanupWorker := cleanup.NewWorker(db.DB, clk, cfg.CleanupInterval(), cfg.Cleanup.BatchSize, func(e cleanup.Event) {
+		appMetrics.ChallengesExpired.Inc()
+		logger.Info("challenge expired without an answer, marked as no-js",
+			logging.ChallengeKey, e.ChallengeID, "expires_at", e.ExpiresAt)
+	})
+	appMetrics.RegisterCleanup(cleanupWorker.Stats)
+	workerDone := make(chan struct{})
+	go func() {
+		cleanupWorker.Run(backgroundCtx)
+		close(workerDone)
+	}()
+
+	checker := health.NewChecker(2*time.Second,
+		health.Ping(db.DB),
+		health.Migrated(db.DB, challenge.Models()...),
+		secondTaskPoolCheck(store),
+		health.Heartbeat("cleanup_worker", clk, func() time.Time { return cleanupWorker.Stats().Heartbeat }, 2*cleanupWorker.Interval()),
+	)
+
+	app := server.New(cfg, server.Dependencies{
+		Store:      store,
+		Clock:      clk,
+		Verdicts:   verdicts,
+		Assets:     assets,
+		Metrics:    appMetrics,
+		Checker:    checker,
+		Similarity: similarity.NewSearcher(db.DB, cfg.Admin.SimilarityWindow),
+		Admin:      admin.NewStore(db.DB),
+		Cleanup:    cleanupWorker.Stats,
+		Logger:     logger,
 	})
-	router.POST("/challenge", func(c *gin.Context) {
-		var answer models.ChallengeAnswer
-
-		if err := c.ShouldBindJSON(&answer); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "invalid JSON: " + err.Error(),
-			})
-			return
-		}
-
-		fmt.Printf("Challenge ID: %s\n", answer.ID)
-		fmt.Printf("TotalHash1: %s\n", answer.FirstTaskHash)
-		fmt.Printf("TotalHash2: %s\n", answer.SecondTaskHash)
-
-		var challenge models.Challenge
-		result := db.DB.First(&challenge, "id = ?", answer.ID)
-		if result.Error != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
-			return
-		}
 
-		processingTime := time.Since(challenge.CreatedAt)
-		noiseDetect := challenge.ExpectedHash != answer.FirstTaskHash && answer.CopyMismatch != nil && *answer.CopyMismatch
+	// Seed the second task pool so the server is ready before the first challenge
+	if _, err := app.Service().EnsureSecondTask(context.Background()); err != nil {
+		fatal("failed to seed second task pool", err)
+	}
 
-		challenge.NoiseDetected = noiseDetect
+	srv := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Server.Port),
+		Handler:           app.Handler(),
+		ReadTimeout:       cfg.Server.ReadTimeout.Duration,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout.Duration,
+		WriteTimeout:      cfg.Server.WriteTimeout.Duration,
+		IdleTimeout:       cfg.Server.IdleTimeout.Duration,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
 
-		// Create a map for selective update
-		updateData := map[string]interface{}{
-			"NoiseDetected":  noiseDetect,
-			"ActualHash":     answer.FirstTaskHash,
-			"Fingerprint":    answer.SecondTaskHash,
-			"Metrics":        answer.SecondTaskMetrics,
-			"ProcessingTime": processingTime.Milliseconds(),
-			"CopyMismatch":   answer.CopyMismatch,
-			"JavaScript":     true,
+	useTLS := cfg.TLS.Enabled()
+	if useTLS {
+		reloader, err := certs.NewReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			fatal("failed to load TLS certificate", err)
 		}
-
-		if answer.DiffTaskHash != nil {
-			updateData["NoiseHash"] = *answer.DiffTaskHash
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
 		}
+		go reloader.Watch(backgroundCtx, cfg.TLS.ReloadInterval.Duration)
+
+		// SIGHUP forces an immediate certificate reload
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := reloader.Reload(); err != nil {
+					logger.Error("failed to reload TLS certificate", "error", err)
+					continue
+				}
+				logger.Info("reloaded TLS certificate", "cert_file", cfg.TLS.CertFile)
+			}
+		}()
+	}
 
-		if err := db.DB.Model(&challenge).Updates(updateData).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update challenge in cache"})
-			return
-		}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		c.JSON(http.StatusOK, gin.H{
-			"status":         "ok",
-			"noise_detected": noiseDetect,
-		})
-	})
+	listener, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		fatal("server can't be started", err)
+	}
 
-	router.GET("/", func(c *gin.Context) {
-		// Generate new challenge ID
-		id := uuid.New()
-		challenge := models.Challenge{
-			ID:        id.String(),
-			ExpiresAt: time.Now().Add(challengeExpiration),
-		}
-		result := db.DB.Create(&challenge)
-		if result.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create challenge"})
-			return
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("server starting", "port", cfg.Server.Port, "tls", useTLS)
+		if useTLS {
+			serveErr <- srv.ServeTLS(listener, "", "")
+		} else {
+			serveErr <- srv.Serve(listener)
 		}
+	}()
+	checker.SetReady(true)
 
-		// Read index.html
-		root, err := os.Getwd()
-		if err != nil {
-			c.String(http.StatusInternalServerError, "Failed to get working directory")
-			return
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("server can't be started", "error", err)
 		}
-		filePath := filepath.Join(root, "resources", "index.html")
-		htmlContent, err := os.ReadFile(filePath)
-		if err != nil {
-			c.String(http.StatusInternalServerError, "Failed to read index.html")
-			return
-		}
-
-		// Replace placeholder with the real ID
-		newHTML := strings.Replace(string(htmlContent), "__CHALLENGE_ID__", id.String(), 1)
-
-		// Serve the modified HTML
-		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(newHTML))
-	})
+	case <-ctx.Done():
+		logger.Info("shutting down, draining in-flight requests")
+	}
+	stop()
+	checker.SetReady(false)
 
-	router.GET("/predictor.worker.js", func(c *gin.Context) {
-		root, err := os.Getwd()
-		if err != nil {
-			c.String(http.StatusInternalServerError, "Failed to get working directory")
-			return
-		}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("server shutdown did not complete", "error", err)
+	}
 
-		filePath := filepath.Join(root, "resources", "predictor.worker.js")
+	stopBackground()
+	<-workerDone
 
-		c.Header("Content-Type", "application/javascript")
-		c.File(filePath)
-	})
-	log.Printf("Server starting on port %s", port)
-	err = router.Run(":" + port)
-	if err != nil {
-		log.Printf("Server can't be started %s", err.Error())
-		return
+	// The server may have used up shutdownCtx, the buffered spans get their own deadline
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		logger.Error("failed to flush traces", "error", err)
 	}
+	logger.Info("server stopped")
 }
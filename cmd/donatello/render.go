@@ -0,0 +1,101 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Litebrowsers/donatello/internal/admin"
+	"github.com/Litebrowsers/donatello/internal/db"
+	"github.com/Litebrowsers/donatello/internal/tasks"
+)
+
+// render draws a task, or a task of a stored challenge, as PNG.
+func render(args []string) {
+	var task, id, output string
+	var second bool
+	var size, scale int
+	cfg := loadConfig("donatello render", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&task, "task", "", "encoded task to draw")
+		fs.StringVar(&id, "id", "", "ID of a stored challenge whose first task is drawn")
+		fs.BoolVar(&second, "second", false, "draw the second task of the -id challenge instead")
+		fs.IntVar(&size, "size", 0, "canvas width and height (default -challenge.canvas_size)")
+		fs.IntVar(&scale, "scale", 1, fmt.Sprintf("factor every pixel is enlarged by, at most %d", tasks.MaxScale))
+		fs.StringVar(&output, "o", "-", "PNG file to write, - for stdout")
+	})
+	if (task == "") == (id == "") {
+		fatal("invalid arguments", errors.New("exactly one of -task and -id is required"))
+	}
+	size = canvasSize(size, cfg.Challenge.CanvasSize)
+	// Check the scale before any PNG bytes are written to stdout
+	if scale < 1 || scale > tasks.MaxScale {
+		fatal("invalid arguments", fmt.Errorf("-scale must be between 1 and %d", tasks.MaxScale))
+	}
+
+	if id != "" {
+		if err := db.InitDB(cfg.Database.Path); err != nil {
+			fatal("failed to connect database", err)
+		}
+		var err error
+		if task, err = storedTask(context.Background(), admin.NewStore(db.DB), id, second); err != nil {
+			fatal("failed to load task", err)
+		}
+	}
+	canvas, err := tasks.Render(task, size, size)
+	if err != nil {
+		fatal("failed to draw task", err)
+	}
+	writePNG(canvas, output, scale)
+}
+
+// writePNG writes canvas enlarged by scale as PNG to the file output, or to stdout for "-".
+func writePNG(canvas *tasks.Canvas, output string, scale int) {
+	var w io.Writer = os.Stdout
+	if output != "-" {
+		file, err := os.Create(output)
+		if err != nil {
+			fatal("failed to create output file", err)
+		}
+		defer func() { _ = file.Close() }()
+		w = file
+	}
+	buffered := bufio.NewWriter(w)
+	if err := canvas.EncodePNG(buffered, scale); err != nil {
+		fatal("failed to write PNG", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		fatal("failed to write PNG", err)
+	}
+}
+
+// storedTask returns the first or second task of the challenge id.
+func storedTask(ctx context.Context, store *admin.Store, id string, second bool) (string, error) {
+	stored, err := store.Challenge(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if stored.Task == "" {
+		return "", fmt.Errorf("challenge %s was not issued", id)
+	}
+	if second {
+		return store.Task(ctx, stored.SecondTaskID)
+	}
+	return stored.Task, nil
+}
@@ -0,0 +1,252 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Litebrowsers/donatello/internal/config"
+	"github.com/Litebrowsers/donatello/internal/logging"
+	"github.com/Litebrowsers/donatello/internal/tasks"
+	"github.com/Litebrowsers/donatello/pkg/challenge"
+)
+
+// maxGenerateAttempts limits the tasks generated while looking for one with the requested profile.
+const maxGenerateAttempts = 100000
+
+// taskCommand runs the offline task tools.
+func taskCommand(args []string) {
+	// Tasks and hashes are meant to be piped, so log to stderr
+	slog.SetDefault(logging.New(os.Stderr, slog.LevelInfo, false))
+	if len(args) == 0 {
+		taskUsage()
+	}
+	switch args[0] {
+	case "generate":
+		taskGenerate(args[1:])
+	case "parse":
+		taskParse(args[1:])
+	case "render":
+		taskRender(args[1:])
+	case "hash":
+		taskHash(args[1:])
+	default:
+		taskUsage()
+	}
+}
+
+func taskUsage() {
+	fmt.Fprintln(os.Stderr, `usage: donatello task <command> [task] [flags]
+
+commands:
+  generate  print random tasks
+  parse     validate a task and print its shapes
+  render    draw a task, as PNG with -png
+  hash      print the combined hash of a task
+
+parse, render and hash read the task from stdin when it is missing or "-".`)
+	os.Exit(2)
+}
+
+// taskGenerate prints random tasks, optionally only those with a profile.
+func taskGenerate(args []string) {
+	var profile string
+	var seed int64
+	var second bool
+	var count int
+	cfg := loadConfig("donatello task generate", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&profile, "profile", "", "only print tasks with this profile, e.g. X4+L+R")
+		fs.Int64Var(&seed, "seed", 0, "random seed, 0 for a random one")
+		fs.BoolVar(&second, "second", false, "generate second tasks instead of first tasks")
+		fs.IntVar(&count, "n", 1, "number of tasks")
+	})
+	if count < 1 {
+		fatal("invalid arguments", errors.New("-n must be positive"))
+	}
+
+	generator := challenge.RandomGenerator{}
+	if seed != 0 {
+		generator.Rand = rand.New(rand.NewSource(seed))
+	}
+	w := bufio.NewWriter(os.Stdout)
+	for range count {
+		shapes, err := generateTask(generator, cfg.Challenge.CanvasSize, second, profile)
+		if err != nil {
+			fatal("failed to generate task", err)
+		}
+		_, _ = fmt.Fprintln(w, tasks.NewTaskGenerator(shapes...).GenerateTask())
+	}
+	if err := w.Flush(); err != nil {
+		fatal("failed to write tasks", err)
+	}
+}
+
+// generateTask returns the shapes of a first or second task of g with profile, or of any profile if it is empty.
+func generateTask(g challenge.Generator, size int, second bool, profile string) ([]tasks.Shape, error) {
+	for range maxGenerateAttempts {
+		// Only the requested generator may draw from a seeded source, so the tasks match a server with the same seed
+		var shapes []tasks.Shape
+		if second {
+			shapes = g.SecondTask(size)
+		} else {
+			shapes = g.FirstTask(size)
+		}
+		if profile == "" || tasks.Profile(shapes) == profile {
+			return shapes, nil
+		}
+	}
+	return nil, fmt.Errorf("no task with profile %q in %d attempts", profile, maxGenerateAttempts)
+}
+
+// taskParse validates a task by drawing it and prints its shapes.
+func taskParse(args []string) {
+	var size int
+	task, cfg := loadTask("donatello task parse", args, func(fs *flag.FlagSet) {
+		fs.IntVar(&size, "size", 0, "canvas width and height (default -challenge.canvas_size)")
+	})
+	size = canvasSize(size, cfg.Challenge.CanvasSize)
+	shapes, err := tasks.ParseTask(task)
+	if err != nil {
+		fatal("invalid task", err)
+	}
+	if _, err := tasks.Render(task, size, size); err != nil {
+		fatal("invalid task", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "profile: %s\n\n", tasks.Profile(shapes))
+	_, _ = fmt.Fprintln(w, "#\tSHAPE\tPARAMETERS")
+	for i, shape := range shapes {
+		name := strings.TrimPrefix(fmt.Sprintf("%T", shape), "tasks.")
+		parameters := strings.Trim(fmt.Sprintf("%+v", shape), "{}")
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\n", i+1, name, parameters)
+	}
+	_ = w.Flush()
+}
+
+// taskRender draws a task and prints its channels, or writes it as PNG.
+func taskRender(args []string) {
+	var size, scale int
+	var asPNG bool
+	var output string
+	task, cfg := loadTask("donatello task render", args, func(fs *flag.FlagSet) {
+		fs.IntVar(&size, "size", 0, "canvas width and height (default -challenge.canvas_size)")
+		fs.BoolVar(&asPNG, "png", false, "write a PNG instead of printing the channel values")
+		fs.IntVar(&scale, "scale", 1, fmt.Sprintf("factor every pixel of the PNG is enlarged by, at most %d", tasks.MaxScale))
+		fs.StringVar(&output, "o", "-", "PNG file to write, - for stdout")
+	})
+	size = canvasSize(size, cfg.Challenge.CanvasSize)
+	if scale < 1 || scale > tasks.MaxScale {
+		fatal("invalid arguments", fmt.Errorf("-scale must be between 1 and %d", tasks.MaxScale))
+	}
+	canvas, err := tasks.Render(task, size, size)
+	if err != nil {
+		fatal("failed to draw task", err)
+	}
+	if asPNG {
+		writePNG(canvas, output, scale)
+		return
+	}
+	canvas.PrintMatrices()
+}
+
+// taskHash prints the combined hash of a task as the server expects it, and the channel hashes it combines.
+func taskHash(args []string) {
+	var size int
+	var channels bool
+	task, cfg := loadTask("donatello task hash", args, func(fs *flag.FlagSet) {
+		fs.IntVar(&size, "size", 0, "canvas width and height (default -challenge.canvas_size)")
+		fs.BoolVar(&channels, "channels", false, "also print the hash of each channel")
+	})
+	size = canvasSize(size, cfg.Challenge.CanvasSize)
+	if err := writeTaskHash(os.Stdout, task, size, channels); err != nil {
+		fatal("failed to hash task", err)
+	}
+}
+
+// writeTaskHash writes the combined hash of task drawn on a size×size canvas to w, followed by the channel hashes
+// if channels is set.
+func writeTaskHash(w io.Writer, task string, size int, channels bool) error {
+	canvas, err := tasks.Render(task, size, size)
+	if err != nil {
+		return err
+	}
+	hashes, err := canvas.CalculateHashes()
+	if err != nil {
+		return err
+	}
+	combined, err := canvas.CalculateCombinedHash(hashes)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintln(w, combined); err != nil {
+		return err
+	}
+	if channels {
+		for _, channel := range []string{"red", "green", "blue", "alpha"} {
+			if _, err := fmt.Fprintf(w, "%s %s\n", channel, hashes[channel]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadTask loads the configuration and the task, which is the first argument or read from stdin when it is missing
+// or "-".
+func loadTask(name string, args []string, extra func(fs *flag.FlagSet)) (string, *config.Config) {
+	task, args := leadingArgument(args)
+	cfg := loadConfig(name, args, extra)
+	if task == "" || task == "-" {
+		input, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fatal("failed to read task", err)
+		}
+		task = string(input)
+	}
+	task = strings.TrimSpace(task)
+	if task == "" {
+		fatal("invalid arguments", errors.New("task is empty"))
+	}
+	return task, cfg
+}
+
+// leadingArgument splits the first argument off args unless it is a flag.
+func leadingArgument(args []string) (string, []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && args[0] != "-" {
+		return "", args
+	}
+	return args[0], args[1:]
+}
+
+// canvasSize returns size, or fallback if it is zero. It exits for other sizes below 1.
+func canvasSize(size, fallback int) int {
+	if size == 0 {
+		return fallback
+	}
+	if size < 1 {
+		fatal("invalid arguments", errors.New("-size must be positive"))
+	}
+	return size
+}
@@ -0,0 +1,186 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/Litebrowsers/donatello/internal/tasks"
+	"github.com/Litebrowsers/donatello/pkg/challenge"
+)
+
+func TestLeadingArgument(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		argument string
+		rest     []string
+	}{
+		{name: "empty", args: nil, rest: nil},
+		{name: "task", args: []string{"C:FF0000:2:2:2", "-size", "8"}, argument: "C:FF0000:2:2:2", rest: []string{"-size", "8"}},
+		{name: "flag", args: []string{"-size", "8"}, rest: []string{"-size", "8"}},
+		{name: "stdin", args: []string{"-", "-size", "8"}, argument: "-", rest: []string{"-size", "8"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argument, rest := leadingArgument(tt.args)
+			if argument != tt.argument || !reflect.DeepEqual(rest, tt.rest) {
+				t.Errorf("leadingArgument() failed. Expected %q %v, got %q %v", tt.argument, tt.rest, argument, rest)
+			}
+		})
+	}
+}
+
+func TestCanvasSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		fallback int
+		expected int
+	}{
+		{name: "default", size: 0, fallback: 20, expected: 20},
+		{name: "flag", size: 64, fallback: 20, expected: 64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if size := canvasSize(tt.size, tt.fallback); size != tt.expected {
+				t.Errorf("canvasSize() failed. Expected %d, got %d", tt.expected, size)
+			}
+		})
+	}
+}
+
+// countingGenerator counts the tasks generated by the wrapped generator.
+type countingGenerator struct {
+	challenge.Generator
+	first, second int
+}
+
+func (g *countingGenerator) FirstTask(size int) []tasks.Shape {
+	g.first++
+	return g.Generator.FirstTask(size)
+}
+
+func (g *countingGenerator) SecondTask(size int) []tasks.Shape {
+	g.second++
+	return g.Generator.SecondTask(size)
+}
+
+func TestGenerateTask(t *testing.T) {
+	seeded := func() challenge.RandomGenerator {
+		return challenge.RandomGenerator{Rand: rand.New(rand.NewSource(7))}
+	}
+	// A profile the seed produces, though not necessarily first
+	reference := seeded()
+	var fifth []tasks.Shape
+	for range 5 {
+		fifth = reference.FirstTask(20)
+	}
+	profile := tasks.Profile(fifth)
+
+	tests := []struct {
+		name    string
+		second  bool
+		profile string
+		first   []tasks.Shape
+		wantErr bool
+	}{
+		{name: "any first task", first: seeded().FirstTask(20)},
+		{name: "any second task", second: true, first: seeded().SecondTask(20)},
+		{name: "profile", profile: profile},
+		{name: "unknown profile", profile: "Z", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &countingGenerator{Generator: seeded()}
+			if tt.wantErr {
+				// Keep the attempts cheap
+				g.Generator = fixedShapes{}
+			}
+			shapes, err := generateTask(g, 20, tt.second, tt.profile)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("generateTask() failed. Expected an error for an unknown profile")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("generateTask() failed: %v", err)
+			}
+			if tt.first != nil && !reflect.DeepEqual(shapes, tt.first) {
+				t.Errorf("generateTask() failed. Expected the seeded task %v, got %v", tt.first, shapes)
+			}
+			if tt.profile != "" && tasks.Profile(shapes) != tt.profile {
+				t.Errorf("generateTask() failed. Expected profile %q, got %q", tt.profile, tasks.Profile(shapes))
+			}
+			// Drawing from the other generator would shift the seeded sequence
+			if tt.second && g.first != 0 || !tt.second && g.second != 0 {
+				t.Errorf("generateTask() failed. Generated %d first and %d second tasks", g.first, g.second)
+			}
+		})
+	}
+}
+
+// fixedShapes always generates the same chessboard.
+type fixedShapes struct{}
+
+func (fixedShapes) FirstTask(int) []tasks.Shape {
+	return []tasks.Shape{tasks.Chessboard{GridSize: 2, Color1: "000000", Color2: "FFFFFF"}}
+}
+
+func (g fixedShapes) SecondTask(size int) []tasks.Shape {
+	return g.FirstTask(size)
+}
+
+func TestWriteTaskHash(t *testing.T) {
+	const task = "X:2:FF0000:0000FF;R:00FF00:2:2:2:2"
+	canvas, err := tasks.Render(task, 8, 8)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	hashes, _ := canvas.CalculateHashes()
+	combined, _ := canvas.CalculateCombinedHash(hashes)
+
+	tests := []struct {
+		name     string
+		channels bool
+		expected string
+	}{
+		{name: "combined", expected: combined + "\n"},
+		{
+			name:     "channels",
+			channels: true,
+			expected: combined + "\nred " + hashes["red"] + "\ngreen " + hashes["green"] + "\nblue " + hashes["blue"] +
+				"\nalpha " + hashes["alpha"] + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := writeTaskHash(&out, task, 8, tt.channels); err != nil {
+				t.Fatalf("writeTaskHash() failed: %v", err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("writeTaskHash() failed. Expected %q, got %q", tt.expected, out.String())
+			}
+		})
+	}
+
+	if err := writeTaskHash(&bytes.Buffer{}, "invalid", 8, false); err == nil {
+		t.Errorf("writeTaskHash() failed. Expected an error for an invalid task, got %v", err)
+	}
+}
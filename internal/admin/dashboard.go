@@ -0,0 +1,244 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"html/template"
+	"image"
+	"strconv"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/cleanup"
+	"github.com/Litebrowsers/donatello/internal/tasks"
+)
+
+// LiveWindow is the period of the live throughput of the dashboard.
+const LiveWindow = 5 * time.Minute
+
+// Dashboard is the data of the admin dashboard page.
+type Dashboard struct {
+	From, To time.Time
+	// LiveCreated and LiveAnswered count the challenges created in the LiveWindow before To.
+	LiveCreated, LiveAnswered int64
+	// Totals sums Hours.
+	Totals           HourStat
+	NoiseRate        Rate
+	NoJavaScriptRate Rate
+	// Hours has a row for every hour from From to To, oldest first.
+	Hours           []HourBar
+	Fingerprints    []Bar
+	ProcessingTimes []Bar
+	// Recent are the newest challenges.
+	Recent []Summary
+	// Cleanup are the statistics of the cleanup worker, nil if unknown.
+	Cleanup *cleanup.Stats
+}
+
+// Rate is the share of Part in Whole.
+type Rate struct {
+	Part, Whole int64
+}
+
+// String formats r as a percentage, or a dash if Whole is zero.
+func (r Rate) String() string {
+	if r.Whole == 0 {
+		return "–"
+	}
+	return strconv.FormatFloat(100*float64(r.Part)/float64(r.Whole), 'f', 1, 64) + "%"
+}
+
+// noiseRate is the share of noisy challenges among the answered ones.
+func noiseRate(stat HourStat) Rate {
+	return Rate{Part: stat.NoiseDetected, Whole: stat.Answered}
+}
+
+// noJavaScriptRate is the share of no-JS challenges among the answered and expired ones.
+func noJavaScriptRate(stat HourStat) Rate {
+	return Rate{Part: stat.NoJavaScript, Whole: stat.Answered + stat.NoJavaScript}
+}
+
+// HourBar is a row of the throughput chart.
+type HourBar struct {
+	HourStat
+	// Width and AnsweredWidth are the bar lengths of Created and Answered in percent of the busiest hour.
+	Width, AnsweredWidth int
+	NoiseRate            Rate
+	NoJavaScriptRate     Rate
+}
+
+// Bar is a row of a bar chart.
+type Bar struct {
+	Label string
+	Count int64
+	// Width is the bar length in percent of the longest bar of the chart.
+	Width int
+}
+
+// Dashboard collects the dashboard data of the challenges created in [from, to) and the recent newest challenges.
+func (s *Store) Dashboard(ctx context.Context, from, to time.Time, recent int) (*Dashboard, error) {
+	d := &Dashboard{From: from, To: to}
+	var err error
+	live := Filter{From: to.Add(-LiveWindow), To: to}
+	if d.LiveCreated, err = s.Count(ctx, live); err != nil {
+		return nil, err
+	}
+	live.JavaScript = JavaScriptConfirmed
+	if d.LiveAnswered, err = s.Count(ctx, live); err != nil {
+		return nil, err
+	}
+
+	hours, err := s.Hourly(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	d.Hours = hourBars(hours, from, to)
+	for _, hour := range hours {
+		d.Totals.Created += hour.Created
+		d.Totals.Answered += hour.Answered
+		d.Totals.NoJavaScript += hour.NoJavaScript
+		d.Totals.NoiseDetected += hour.NoiseDetected
+	}
+	d.NoiseRate = noiseRate(d.Totals)
+	d.NoJavaScriptRate = noJavaScriptRate(d.Totals)
+
+	fingerprints, err := s.TopFingerprints(ctx, from, to, 10)
+	if err != nil {
+		return nil, err
+	}
+	for _, fingerprint := range fingerprints {
+		d.Fingerprints = append(d.Fingerprints, Bar{Label: fingerprint.Fingerprint, Count: fingerprint.Count})
+	}
+	scale(d.Fingerprints)
+
+	processingTimes, err := s.ProcessingTimes(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for i, count := range processingTimes {
+		label := "≥ " + formatMilliseconds(ProcessingTimeBuckets[len(ProcessingTimeBuckets)-1])
+		if i < len(ProcessingTimeBuckets) {
+			label = "< " + formatMilliseconds(ProcessingTimeBuckets[i])
+		}
+		d.ProcessingTimes = append(d.ProcessingTimes, Bar{Label: label, Count: count})
+	}
+	scale(d.ProcessingTimes)
+
+	challenges, _, err := s.Challenges(ctx, Filter{}, Page{Limit: recent})
+	if err != nil {
+		return nil, err
+	}
+	for i := range challenges {
+		d.Recent = append(d.Recent, NewSummary(&challenges[i]))
+	}
+	return d, nil
+}
+
+// hourBars returns a row for every hour from from to to, including the hours without challenges.
+func hourBars(stats []HourStat, from, to time.Time) []HourBar {
+	byHour := make(map[time.Time]HourStat, len(stats))
+	var busiest int64
+	for _, stat := range stats {
+		byHour[stat.Hour] = stat
+		busiest = max(busiest, stat.Created)
+	}
+	var bars []HourBar
+	for hour := from.UTC().Truncate(time.Hour); hour.Before(to); hour = hour.Add(time.Hour) {
+		stat, ok := byHour[hour]
+		if !ok {
+			stat = HourStat{Hour: hour}
+		}
+		bars = append(bars, HourBar{
+			HourStat:         stat,
+			Width:            width(stat.Created, busiest),
+			AnsweredWidth:    width(stat.Answered, busiest),
+			NoiseRate:        noiseRate(stat),
+			NoJavaScriptRate: noJavaScriptRate(stat),
+		})
+	}
+	return bars
+}
+
+// scale sets the widths of bars relative to the longest bar.
+func scale(bars []Bar) {
+	var longest int64
+	for _, bar := range bars {
+		longest = max(longest, bar.Count)
+	}
+	for i := range bars {
+		bars[i].Width = width(bars[i].Count, longest)
+	}
+}
+
+// width returns value in percent of longest. Non-zero values are at least 1% so they stay visible.
+func width(value, longest int64) int {
+	if value <= 0 || longest <= 0 {
+		return 0
+	}
+	return max(1, int(100*value/longest))
+}
+
+// formatMilliseconds formats a duration in milliseconds, using seconds from one second on.
+func formatMilliseconds(ms int64) string {
+	if ms < 1000 {
+		return strconv.FormatInt(ms, 10) + " ms"
+	}
+	return strconv.FormatFloat(float64(ms)/1000, 'f', -1, 64) + " s"
+}
+
+// ChallengeView is the data of the dashboard page of a challenge.
+type ChallengeView struct {
+	*Detail
+	// Expected, Submitted, Uploaded and UploadDiff are the canvases as PNG data URLs, empty without the canvas.
+	Expected, Submitted, Uploaded, UploadDiff template.URL
+}
+
+// NewChallengeView returns the view of detail with the canvases enlarged by scale.
+func NewChallengeView(detail *Detail, scale int) (*ChallengeView, error) {
+	view := &ChallengeView{Detail: detail}
+	var err error
+	if detail.ExpectedCanvas != nil {
+		if view.Expected, err = dataURL(detail.ExpectedCanvas.Image(), scale); err != nil {
+			return nil, err
+		}
+	}
+	if detail.SubmittedCanvas != nil {
+		if view.Submitted, err = dataURL(detail.SubmittedCanvas.Image(), scale); err != nil {
+			return nil, err
+		}
+	}
+	if detail.Upload != nil {
+		if view.Uploaded, err = dataURL(detail.Upload.Image, scale); err != nil {
+			return nil, err
+		}
+	}
+	if detail.Upload != nil && detail.Upload.Diff != nil {
+		if view.UploadDiff, err = dataURL(detail.Upload.Diff, scale); err != nil {
+			return nil, err
+		}
+	}
+	return view, nil
+}
+
+// dataURL encodes img enlarged by scale as a PNG data URL.
+func dataURL(img *image.NRGBA, scale int) (template.URL, error) {
+	var encoded bytes.Buffer
+	if err := tasks.EncodePNG(&encoded, img, scale); err != nil {
+		return "", err
+	}
+	// The data URL is built from the encoded image only, so it is safe to use as an image source
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(encoded.Bytes())), nil
+}
@@ -0,0 +1,94 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/models"
+	"github.com/Litebrowsers/donatello/internal/tasks"
+)
+
+func TestStore_Dashboard(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	// The pending challenge was created 3 minutes before the end, within the live window
+	d, err := store.Dashboard(context.Background(), start, start.Add(93*time.Minute), 3)
+	if err != nil {
+		t.Fatalf("Dashboard() failed: %v", err)
+	}
+	if d.LiveCreated != 1 || d.LiveAnswered != 0 {
+		t.Errorf("Dashboard() failed. Expected 1 live challenge, got %d created and %d answered", d.LiveCreated, d.LiveAnswered)
+	}
+	if d.Totals.Created != 4 || d.NoiseRate.String() != "50.0%" || d.NoJavaScriptRate.String() != "33.3%" {
+		t.Errorf("Dashboard() failed. Unexpected totals %+v, noise rate %v and no-JS rate %v", d.Totals, d.NoiseRate, d.NoJavaScriptRate)
+	}
+	if len(d.Hours) != 2 || d.Hours[0].Width != 100 || d.Hours[0].AnsweredWidth != 100 || d.Hours[1].AnsweredWidth != 0 {
+		t.Errorf("Dashboard() failed. Unexpected hours %+v", d.Hours)
+	}
+	if len(d.Fingerprints) != 2 || d.Fingerprints[0].Width != 100 {
+		t.Errorf("Dashboard() failed. Unexpected fingerprints %+v", d.Fingerprints)
+	}
+	if first, last := d.ProcessingTimes[0], d.ProcessingTimes[len(d.ProcessingTimes)-1]; first.Label != "< 100 ms" ||
+		first.Count != 1 || last.Label != "≥ 10 s" || last.Count != 1 || d.ProcessingTimes[4].Label != "< 2.5 s" {
+		t.Errorf("Dashboard() failed. Unexpected processing times %+v", d.ProcessingTimes)
+	}
+	if len(d.Recent) != 3 || d.Recent[0].ID != "pending" {
+		t.Errorf("Dashboard() failed. Unexpected recent challenges %+v", d.Recent)
+	}
+
+	// Hours without challenges are charted empty
+	d, err = store.Dashboard(context.Background(), start.Add(-2*time.Hour), start.Add(time.Hour), 3)
+	if err != nil || len(d.Hours) != 3 || d.Hours[0].Created != 0 || !d.Hours[2].Hour.Equal(start) {
+		t.Errorf("Dashboard() failed. Expected 3 hours, got %+v (%v)", d.Hours, err)
+	}
+	if d.Hours[0].NoiseRate.String() != "–" {
+		t.Errorf("Rate.String() failed. Expected a dash without challenges, got %v", d.Hours[0].NoiseRate)
+	}
+}
+
+func TestNewChallengeView(t *testing.T) {
+	task := tasks.NewTaskGenerator(tasks.Rectangle{Color: "FF8000", W: 2, H: 2, X: 1, Y: 1}).GenerateTask()
+	detail, err := NewDetail(&models.Challenge{ID: "c1", Task: task}, 4)
+	if err != nil {
+		t.Fatalf("NewDetail() failed: %v", err)
+	}
+
+	view, err := NewChallengeView(detail, 2)
+	if err != nil {
+		t.Fatalf("NewChallengeView() failed: %v", err)
+	}
+	if view.Submitted != "" {
+		t.Errorf("NewChallengeView() failed. Expected no submitted canvas, got %q", view.Submitted)
+	}
+	encoded, ok := strings.CutPrefix(string(view.Expected), "data:image/png;base64,")
+	if !ok {
+		t.Fatalf("NewChallengeView() failed. Expected a PNG data URL, got %q", view.Expected)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(encoded)
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+	// Pixel (1, 1) is enlarged to (2, 2) to (3, 3)
+	if r, g, b, a := img.At(3, 3).RGBA(); img.Bounds().Dx() != 8 || r>>8 != 0xFF || g>>8 != 0x80 || b != 0 || a>>8 != 0xFF {
+		t.Errorf("NewChallengeView() failed. Expected an orange pixel at (3, 3), got %v", img.At(3, 3))
+	}
+}
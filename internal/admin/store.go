@@ -0,0 +1,258 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package admin queries stored challenges for operators.
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned when a challenge or task does not exist.
+var ErrNotFound = errors.New("admin: not found")
+
+// JavaScript states a challenge can be filtered by.
+const (
+	// JavaScriptConfirmed matches answered challenges.
+	JavaScriptConfirmed = "true"
+	// JavaScriptMissing matches challenges the cleanup worker marked as no-js.
+	JavaScriptMissing = "false"
+	// JavaScriptPending matches challenges that were neither answered nor expired yet.
+	JavaScriptPending = "pending"
+)
+
+// Filter selects challenges. Zero values do not filter.
+type Filter struct {
+	// From and To limit the creation time to [From, To).
+	From, To time.Time
+	// Noise matches the NoiseDetected flag.
+	Noise *bool
+	// JavaScript is one of JavaScriptConfirmed, JavaScriptMissing or JavaScriptPending.
+	JavaScript  string
+	Fingerprint string
+	// MinRisk and MaxRisk limit the risk score to [MinRisk, MaxRisk].
+	MinRisk, MaxRisk *float64
+}
+
+// Page is a window of a result list.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// HourStat aggregates the challenges created in one hour.
+type HourStat struct {
+	Hour          time.Time `json:"hour"`
+	Created       int64     `json:"created"`
+	Answered      int64     `json:"answered"`
+	NoJavaScript  int64     `json:"no_javascript"`
+	NoiseDetected int64     `json:"noise_detected"`
+	// MeanRisk is the mean risk score of the answered challenges.
+	MeanRisk float64 `json:"mean_risk"`
+}
+
+// FingerprintCount is the number of answered challenges with a fingerprint.
+type FingerprintCount struct {
+	Fingerprint string `json:"fingerprint"`
+	Count       int64  `json:"count"`
+}
+
+// ProcessingTimeBuckets are the upper bounds in milliseconds of the processing time histogram. Longer processing
+// times fall in a last bucket.
+var ProcessingTimeBuckets = []int64{100, 250, 500, 1000, 2500, 5000, 10000}
+
+// Store queries challenges. The Challenge and ChallengeFeature tables must be migrated.
+type Store struct {
+	db *gorm.DB
+}
+
+// NewStore creates a new Store.
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// apply adds the conditions of f to query.
+func (f Filter) apply(query *gorm.DB) *gorm.DB {
+	if !f.From.IsZero() {
+		query = query.Where("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		query = query.Where("created_at < ?", f.To)
+	}
+	if f.Noise != nil {
+		query = query.Where("noise_detected = ?", *f.Noise)
+	}
+	switch f.JavaScript {
+	case JavaScriptConfirmed:
+		query = query.Where("java_script = ?", true)
+	case JavaScriptMissing:
+		query = query.Where("java_script = ?", false)
+	case JavaScriptPending:
+		query = query.Where("java_script IS NULL")
+	}
+	if f.Fingerprint != "" {
+		query = query.Where("fingerprint = ?", f.Fingerprint)
+	}
+	if f.MinRisk != nil {
+		query = query.Where("risk_score >= ?", *f.MinRisk)
+	}
+	if f.MaxRisk != nil {
+		query = query.Where("risk_score <= ?", *f.MaxRisk)
+	}
+	return query
+}
+
+// Count returns the number of challenges matching filter.
+func (s *Store) Count(ctx context.Context, filter Filter) (int64, error) {
+	var count int64
+	err := filter.apply(s.db.WithContext(ctx).Model(&models.Challenge{})).Count(&count).Error
+	return count, err
+}
+
+// Challenges returns the challenges matching filter in page, newest first, and the number of all matches.
+func (s *Store) Challenges(ctx context.Context, filter Filter, page Page) ([]models.Challenge, int64, error) {
+	total, err := s.Count(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	var challenges []models.Challenge
+	err = filter.apply(s.db.WithContext(ctx)).
+		Order("created_at DESC, id").
+		Limit(page.Limit).
+		Offset(page.Offset).
+		Find(&challenges).Error
+	return challenges, total, err
+}
+
+// Challenge returns the challenge id with its feature rows and canvas upload or ErrNotFound.
+func (s *Store) Challenge(ctx context.Context, id string) (*models.Challenge, error) {
+	var challenge models.Challenge
+	err := s.db.WithContext(ctx).Preload("Features").Preload("Upload").First(&challenge, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// Task returns the value of the second task id or ErrNotFound.
+func (s *Store) Task(ctx context.Context, id uint) (string, error) {
+	var task models.Task
+	err := s.db.WithContext(ctx).First(&task, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrNotFound
+	}
+	return task.Value, err
+}
+
+// hourRow is a row of the hourly aggregate query.
+type hourRow struct {
+	Hour          string
+	Created       int64
+	Answered      int64
+	NoJavaScript  int64
+	NoiseDetected int64
+	MeanRisk      *float64
+}
+
+// Hourly aggregates the challenges created in [from, to) per UTC hour, oldest first. Hours without challenges
+// are left out.
+func (s *Store) Hourly(ctx context.Context, from, to time.Time) ([]HourStat, error) {
+	var rows []hourRow
+	err := s.db.WithContext(ctx).Model(&models.Challenge{}).
+		Select(`strftime('%Y-%m-%dT%H:00:00Z', created_at) AS hour,
+			COUNT(*) AS created,
+			SUM(CASE WHEN java_script THEN 1 ELSE 0 END) AS answered,
+			SUM(CASE WHEN java_script = false THEN 1 ELSE 0 END) AS no_java_script,
+			SUM(CASE WHEN noise_detected THEN 1 ELSE 0 END) AS noise_detected,
+			AVG(CASE WHEN java_script THEN risk_score END) AS mean_risk`).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("hour").
+		Order("hour").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]HourStat, 0, len(rows))
+	for _, row := range rows {
+		hour, err := time.Parse(time.RFC3339, row.Hour)
+		if err != nil {
+			return nil, err
+		}
+		stat := HourStat{
+			Hour:          hour,
+			Created:       row.Created,
+			Answered:      row.Answered,
+			NoJavaScript:  row.NoJavaScript,
+			NoiseDetected: row.NoiseDetected,
+		}
+		if row.MeanRisk != nil {
+			stat.MeanRisk = *row.MeanRisk
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+// TopFingerprints returns the n most frequent fingerprints of the challenges created in [from, to) and answered,
+// most frequent first.
+func (s *Store) TopFingerprints(ctx context.Context, from, to time.Time, n int) ([]FingerprintCount, error) {
+	var counts []FingerprintCount
+	err := s.db.WithContext(ctx).Model(&models.Challenge{}).
+		Select("fingerprint, COUNT(*) AS count").
+		Where("created_at >= ? AND created_at < ? AND java_script AND fingerprint <> ''", from, to).
+		Group("fingerprint").
+		Order("count DESC, fingerprint").
+		Limit(n).
+		Scan(&counts).Error
+	return counts, err
+}
+
+// ProcessingTimes counts the challenges created in [from, to) and answered by processing time. The counts follow
+// ProcessingTimeBuckets and the last one holds the longer processing times.
+func (s *Store) ProcessingTimes(ctx context.Context, from, to time.Time) ([]int64, error) {
+	var bucket strings.Builder
+	bucket.WriteString("CASE")
+	for i, bound := range ProcessingTimeBuckets {
+		fmt.Fprintf(&bucket, " WHEN processing_time < %d THEN %d", bound, i)
+	}
+	fmt.Fprintf(&bucket, " ELSE %d END", len(ProcessingTimeBuckets))
+
+	var rows []struct {
+		Bucket int
+		Count  int64
+	}
+	err := s.db.WithContext(ctx).Model(&models.Challenge{}).
+		Select(bucket.String()+" AS bucket, COUNT(*) AS count").
+		Where("created_at >= ? AND created_at < ? AND java_script", from, to).
+		Group("bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]int64, len(ProcessingTimeBuckets)+1)
+	for _, row := range rows {
+		counts[row.Bucket] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,209 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var start = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestStore(t *testing.T) (*Store, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Challenge{}, &models.ChallengeFeature{}, &models.Task{}, &models.CanvasUpload{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	yes, no := true, false
+	challenges := []models.Challenge{
+		{ID: "answered", JavaScript: &yes, RiskScore: 0.2, Fingerprint: "fp1", ProcessingTime: 80},
+		{ID: "noisy", JavaScript: &yes, NoiseDetected: true, RiskScore: 1, Fingerprint: "fp2", ProcessingTime: 12000},
+		{ID: "expired", JavaScript: &no},
+		{ID: "pending"},
+	}
+	for i, c := range challenges {
+		// One challenge per half hour, so the first two share an hour
+		c.CreatedAt = start.Add(time.Duration(i) * 30 * time.Minute)
+		c.ExpiresAt = c.CreatedAt.Add(time.Minute)
+		if err := db.Create(&c).Error; err != nil {
+			t.Fatalf("Failed to create challenge: %v", err)
+		}
+	}
+	return NewStore(db), db
+}
+
+func TestStore_Challenges(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+	yes, no := true, false
+	minRisk, maxRisk := 0.5, 0.5
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{name: "all", expected: []string{"pending", "expired", "noisy", "answered"}},
+		{name: "time range", filter: Filter{From: start.Add(30 * time.Minute), To: start.Add(90 * time.Minute)}, expected: []string{"expired", "noisy"}},
+		{name: "noise", filter: Filter{Noise: &yes}, expected: []string{"noisy"}},
+		{name: "no noise", filter: Filter{Noise: &no}, expected: []string{"pending", "expired", "answered"}},
+		{name: "javascript", filter: Filter{JavaScript: JavaScriptConfirmed}, expected: []string{"noisy", "answered"}},
+		{name: "no javascript", filter: Filter{JavaScript: JavaScriptMissing}, expected: []string{"expired"}},
+		{name: "pending", filter: Filter{JavaScript: JavaScriptPending}, expected: []string{"pending"}},
+		{name: "fingerprint", filter: Filter{Fingerprint: "fp1"}, expected: []string{"answered"}},
+		{name: "min risk", filter: Filter{MinRisk: &minRisk}, expected: []string{"noisy"}},
+		{name: "max risk", filter: Filter{MaxRisk: &maxRisk, JavaScript: JavaScriptConfirmed}, expected: []string{"answered"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenges, total, err := store.Challenges(ctx, tt.filter, Page{Limit: 10})
+			if err != nil {
+				t.Fatalf("Challenges() failed: %v", err)
+			}
+			var ids []string
+			for _, c := range challenges {
+				ids = append(ids, c.ID)
+			}
+			if total != int64(len(tt.expected)) || len(ids) != len(tt.expected) {
+				t.Fatalf("Challenges() failed. Expected %v, got %v (total %d)", tt.expected, ids, total)
+			}
+			for i := range ids {
+				if ids[i] != tt.expected[i] {
+					t.Errorf("Challenges() failed. Expected %v, got %v", tt.expected, ids)
+					break
+				}
+			}
+		})
+	}
+
+	challenges, total, err := store.Challenges(ctx, Filter{}, Page{Limit: 2, Offset: 1})
+	if err != nil || total != 4 || len(challenges) != 2 || challenges[0].ID != "expired" || challenges[1].ID != "noisy" {
+		t.Errorf("Challenges() failed to paginate. Got %d challenges of %d (%v)", len(challenges), total, err)
+	}
+}
+
+func TestStore_Challenge(t *testing.T) {
+	store, db := newTestStore(t)
+	ctx := context.Background()
+	if err := db.Create(&models.ChallengeFeature{ChallengeID: "answered", Channel: "a", Version: 1}).Error; err != nil {
+		t.Fatalf("Failed to create feature row: %v", err)
+	}
+
+	challenge, err := store.Challenge(ctx, "answered")
+	if err != nil {
+		t.Fatalf("Challenge() failed: %v", err)
+	}
+	if challenge.Fingerprint != "fp1" || len(challenge.Features) != 1 {
+		t.Errorf("Challenge() failed. Unexpected challenge %+v", challenge)
+	}
+	if _, err := store.Challenge(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Challenge() failed. Expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func TestStore_Task(t *testing.T) {
+	store, db := newTestStore(t)
+	ctx := context.Background()
+	task := models.Task{Value: "R:FF0000:1:1:0:0"}
+	if err := db.Create(&task).Error; err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	if value, err := store.Task(ctx, task.ID); err != nil || value != task.Value {
+		t.Errorf("Task() failed. Expected %q, got %q (%v)", task.Value, value, err)
+	}
+	if _, err := store.Task(ctx, task.ID+1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Task() failed. Expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func TestStore_Hourly(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	stats, err := store.Hourly(context.Background(), start, start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Hourly() failed: %v", err)
+	}
+	expected := []HourStat{
+		{Hour: start, Created: 2, Answered: 2, NoiseDetected: 1, MeanRisk: 0.6},
+		{Hour: start.Add(time.Hour), Created: 2, NoJavaScript: 1},
+	}
+	if len(stats) != len(expected) {
+		t.Fatalf("Hourly() failed. Expected %+v, got %+v", expected, stats)
+	}
+	for i := range expected {
+		if !stats[i].Hour.Equal(expected[i].Hour) || stats[i].Created != expected[i].Created ||
+			stats[i].Answered != expected[i].Answered || stats[i].NoJavaScript != expected[i].NoJavaScript ||
+			stats[i].NoiseDetected != expected[i].NoiseDetected || stats[i].MeanRisk != expected[i].MeanRisk {
+			t.Errorf("Hourly() failed. Expected %+v, got %+v", expected[i], stats[i])
+		}
+	}
+}
+
+func TestStore_TopFingerprints(t *testing.T) {
+	store, db := newTestStore(t)
+	ctx := context.Background()
+	yes := true
+	again := models.Challenge{ID: "again", JavaScript: &yes, Fingerprint: "fp2"}
+	again.CreatedAt = start
+	if err := db.Create(&again).Error; err != nil {
+		t.Fatalf("Failed to create challenge: %v", err)
+	}
+
+	counts, err := store.TopFingerprints(ctx, start, start.Add(24*time.Hour), 10)
+	if err != nil {
+		t.Fatalf("TopFingerprints() failed: %v", err)
+	}
+	expected := []FingerprintCount{{Fingerprint: "fp2", Count: 2}, {Fingerprint: "fp1", Count: 1}}
+	if len(counts) != len(expected) || counts[0] != expected[0] || counts[1] != expected[1] {
+		t.Errorf("TopFingerprints() failed. Expected %+v, got %+v", expected, counts)
+	}
+	if counts, _ := store.TopFingerprints(ctx, start, start.Add(24*time.Hour), 1); len(counts) != 1 {
+		t.Errorf("TopFingerprints() failed. Expected 1 fingerprint, got %+v", counts)
+	}
+}
+
+func TestStore_ProcessingTimes(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	counts, err := store.ProcessingTimes(context.Background(), start, start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("ProcessingTimes() failed: %v", err)
+	}
+	// 80 ms is in the first bucket and 12 s in the last, unanswered challenges are left out
+	expected := []int64{1, 0, 0, 0, 0, 0, 0, 1}
+	if len(counts) != len(expected) {
+		t.Fatalf("ProcessingTimes() failed. Expected %v, got %v", expected, counts)
+	}
+	for i := range expected {
+		if counts[i] != expected[i] {
+			t.Errorf("ProcessingTimes() failed. Expected %v, got %v", expected, counts)
+			break
+		}
+	}
+}
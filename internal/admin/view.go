@@ -0,0 +1,254 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package admin
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/analysis"
+	"github.com/Litebrowsers/donatello/internal/models"
+	"github.com/Litebrowsers/donatello/internal/tasks"
+)
+
+// Summary is the JSON view of a challenge in a list.
+type Summary struct {
+	ID             string     `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	IssuedAt       *time.Time `json:"issued_at"`
+	AnsweredAt     *time.Time `json:"answered_at"`
+	Profile        string     `json:"profile"`
+	JavaScript     *bool      `json:"javascript"`
+	NoiseDetected  bool       `json:"noise_detected"`
+	RiskScore      float64    `json:"risk_score"`
+	Fingerprint    string     `json:"fingerprint"`
+	ProcessingTime int64      `json:"processing_time_ms"`
+	MismatchScope  string     `json:"mismatch_scope"`
+	NoiseClass     string     `json:"noise_class"`
+	VisitorID      string     `json:"visitor_id"`
+}
+
+// Detail is the JSON view of a single challenge.
+type Detail struct {
+	Summary
+	Task                  string               `json:"task"`
+	SecondTaskID          uint                 `json:"second_task_id"`
+	ExpectedHash          string               `json:"expected_hash"`
+	ActualHash            string               `json:"actual_hash"`
+	ExpectedChannelHashes []string             `json:"expected_channel_hashes"`
+	NoiseHash             *string              `json:"noise_hash"`
+	CopyMismatch          *bool                `json:"copy_mismatch"`
+	Timings               models.Timings       `json:"timings"`
+	TimingAnomalies       []string             `json:"timing_anomalies"`
+	Inconsistencies       []string             `json:"inconsistencies"`
+	MismatchChannels      []string             `json:"mismatch_channels"`
+	MismatchTiles         []string             `json:"mismatch_tiles"`
+	Noise                 models.NoiseProfile  `json:"noise"`
+	Stability             models.Stability     `json:"stability"`
+	Features              models.FeatureVector `json:"features"`
+	// ExpectedCanvas is the first task rendered again on the server, nil if the challenge was not issued.
+	ExpectedCanvas *Canvas `json:"expected_canvas"`
+	// SubmittedCanvas is the client's rendering of the first task, rebuilt by applying its diff to ExpectedCanvas.
+	// It is nil if the client did not report a valid diff. Its HashMatches compares it with the actual hash.
+	SubmittedCanvas *Canvas `json:"submitted_canvas"`
+	// Upload is the canvas uploaded by the client, nil if it did not upload one.
+	Upload *Upload `json:"upload"`
+}
+
+// Upload describes a canvas uploaded by the client.
+type Upload struct {
+	Bytes int `json:"bytes"`
+	// Hash is the combined hash of the uploaded pixels and HashMatches compares it with the actual hash.
+	Hash        string `json:"hash"`
+	HashMatches bool   `json:"hash_matches"`
+	// DiffPixels is the number of pixels that differed from the server rendering when the canvas was uploaded.
+	DiffPixels int `json:"diff_pixels"`
+	// Image is the uploaded canvas and Diff marks its differences to ExpectedCanvas, see tasks.Diff. Diff is nil
+	// without an expected canvas.
+	Image *image.NRGBA `json:"-"`
+	Diff  *image.NRGBA `json:"-"`
+}
+
+// Canvas is a server-side rendering.
+type Canvas struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// RGBA holds the pixels row by row with interleaved red, green, blue and alpha values, base64 encoded in JSON.
+	RGBA []byte `json:"rgba"`
+	Hash string `json:"hash"`
+	// HashMatches reports whether the rendering still hashes to the stored expected hash. It does not when the
+	// canvas size or the renderer changed since the challenge was issued.
+	HashMatches bool `json:"hash_matches"`
+}
+
+// NewSummary returns the summary of c.
+func NewSummary(c *models.Challenge) Summary {
+	return Summary{
+		ID:             c.ID,
+		CreatedAt:      c.CreatedAt,
+		ExpiresAt:      c.ExpiresAt,
+		IssuedAt:       c.IssuedAt,
+		AnsweredAt:     c.AnsweredAt,
+		Profile:        c.Profile,
+		JavaScript:     c.JavaScript,
+		NoiseDetected:  c.NoiseDetected,
+		RiskScore:      c.RiskScore,
+		Fingerprint:    c.Fingerprint,
+		ProcessingTime: c.ProcessingTime,
+		MismatchScope:  c.MismatchScope,
+		NoiseClass:     c.Noise.Class,
+		VisitorID:      c.VisitorID,
+	}
+}
+
+// NewDetail returns the detail of c. The first task is rendered again on a canvasSize canvas.
+func NewDetail(c *models.Challenge, canvasSize int) (*Detail, error) {
+	detail := &Detail{
+		Summary:               NewSummary(c),
+		Task:                  c.Task,
+		SecondTaskID:          c.SecondTaskID,
+		ExpectedHash:          c.ExpectedHash,
+		ActualHash:            c.ActualHash,
+		ExpectedChannelHashes: split(c.ExpectedChannelHashes),
+		NoiseHash:             c.NoiseHash,
+		CopyMismatch:          c.CopyMismatch,
+		Timings:               c.Timings,
+		TimingAnomalies:       split(c.TimingAnomalies),
+		Inconsistencies:       split(c.Inconsistencies),
+		MismatchChannels:      split(c.MismatchChannels),
+		MismatchTiles:         split(c.MismatchTiles),
+		Noise:                 c.Noise,
+		Stability:             c.Stability,
+		Features:              featureVector(c.Features),
+	}
+	if c.Upload != nil {
+		img, err := png.Decode(bytes.NewReader(c.Upload.PNG))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode canvas upload: %w", err)
+		}
+		detail.Upload = &Upload{
+			Bytes:       len(c.Upload.PNG),
+			Hash:        c.Upload.Hash,
+			HashMatches: c.Upload.HashMatches,
+			DiffPixels:  c.Upload.DiffPixels,
+			Image:       tasks.FromImage(img).Image(),
+		}
+	}
+	if c.Task == "" {
+		return detail, nil
+	}
+	rendered, err := tasks.Render(c.Task, canvasSize, canvasSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render first task: %w", err)
+	}
+	if detail.ExpectedCanvas, err = newCanvas(rendered); err != nil {
+		return nil, fmt.Errorf("failed to hash first task: %w", err)
+	}
+	detail.ExpectedCanvas.HashMatches = detail.ExpectedCanvas.Hash == c.ExpectedHash
+	if detail.Upload != nil {
+		if detail.Upload.Diff, _, err = tasks.Diff(rendered, tasks.FromImage(detail.Upload.Image)); err != nil {
+			// The canvas size changed since the upload
+			detail.Upload.Diff = nil
+		}
+	}
+
+	// A stored diff is valid, so only an empty one of an unanalyzed answer is skipped
+	if c.Noise.Class == "" || c.Noise.Class == analysis.NoiseInvalid {
+		return detail, nil
+	}
+	if err := applyDiff(rendered, c.Diff); err != nil {
+		return nil, err
+	}
+	if detail.SubmittedCanvas, err = newCanvas(rendered); err != nil {
+		return nil, fmt.Errorf("failed to hash submitted canvas: %w", err)
+	}
+	detail.SubmittedCanvas.HashMatches = detail.SubmittedCanvas.Hash == c.ActualHash
+	return detail, nil
+}
+
+// Render draws task on a size×size canvas.
+func Render(task string, size int) (*Canvas, error) {
+	canvas, err := tasks.Render(task, size, size)
+	if err != nil {
+		return nil, err
+	}
+	return newCanvas(canvas)
+}
+
+// newCanvas hashes canvas and interleaves its channels.
+func newCanvas(canvas *tasks.Canvas) (*Canvas, error) {
+	hashes, err := canvas.CalculateHashes()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := canvas.CalculateCombinedHash(hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	img := canvas.Image()
+	return &Canvas{Width: img.Rect.Dx(), Height: img.Rect.Dy(), RGBA: img.Pix, Hash: hash}, nil
+}
+
+// Image returns the pixels of c as an image.
+func (c *Canvas) Image() *image.NRGBA {
+	return &image.NRGBA{Pix: c.RGBA, Stride: 4 * c.Width, Rect: image.Rect(0, 0, c.Width, c.Height)}
+}
+
+// applyDiff adds the differences of the comma separated sparse diff to canvas, see models.Challenge.Diff. It returns
+// tasks.ErrInvalidDiff if the diff is malformed or does not fit the canvas.
+func applyDiff(canvas *tasks.Canvas, diff string) error {
+	values := split(diff)
+	ints := make([]int, len(values))
+	for i, value := range values {
+		var err error
+		if ints[i], err = strconv.Atoi(value); err != nil {
+			return tasks.ErrInvalidDiff
+		}
+	}
+	return canvas.ApplyDiff(ints)
+}
+
+// featureVector rebuilds the feature vector from its stored rows.
+func featureVector(rows []models.ChallengeFeature) models.FeatureVector {
+	v := models.FeatureVector{Channels: make(map[string]models.ChannelFeatures, len(rows))}
+	for _, row := range rows {
+		v.Version = row.Version
+		v.Channels[row.Channel] = models.ChannelFeatures{
+			Mean:         row.Mean,
+			Std:          row.Std,
+			Min:          row.Min,
+			Max:          row.Max,
+			Median:       row.Median,
+			Histogram:    []float64{row.Bin0, row.Bin1, row.Bin2, row.Bin3, row.Bin4, row.Bin5, row.Bin6, row.Bin7},
+			GradientMean: row.GradientMean,
+			GradientMax:  row.GradientMax,
+		}
+	}
+	return v
+}
+
+// split splits a comma separated column into its values.
+func split(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
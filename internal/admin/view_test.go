@@ -0,0 +1,102 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package admin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Litebrowsers/donatello/internal/models"
+	"github.com/Litebrowsers/donatello/internal/tasks"
+)
+
+func TestNewDetail(t *testing.T) {
+	task := tasks.NewTaskGenerator(tasks.Rectangle{Color: "FF8000", W: 2, H: 2, X: 1, Y: 1}).GenerateTask()
+	expected, err := Render(task, 4)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	// Pixel (1, 1) is the first filled pixel
+	if pixel := expected.RGBA[4*(1*4+1):][:4]; pixel[0] != 0xFF || pixel[1] != 0x80 || pixel[2] != 0 || pixel[3] != 0xFF {
+		t.Errorf("Render() failed. Expected an orange pixel, got %v", pixel)
+	}
+	if len(expected.RGBA) != 4*4*4 || expected.RGBA[3] != 0 {
+		t.Errorf("Render() failed. Expected a transparent 4×4 canvas around the rectangle")
+	}
+
+	challenge := &models.Challenge{
+		ID:                    "c1",
+		Task:                  task,
+		ExpectedHash:          expected.Hash,
+		ExpectedChannelHashes: "r,g,b,a",
+		Features:              []models.ChallengeFeature{{Channel: "a", Version: 1, Mean: 0.5, Bin3: 1}},
+	}
+	detail, err := NewDetail(challenge, 4)
+	if err != nil {
+		t.Fatalf("NewDetail() failed: %v", err)
+	}
+	if detail.ExpectedCanvas == nil || !detail.ExpectedCanvas.HashMatches {
+		t.Errorf("NewDetail() failed. Expected the rendering to match the expected hash, got %+v", detail.ExpectedCanvas)
+	}
+	if len(detail.ExpectedChannelHashes) != 4 || len(detail.MismatchTiles) != 0 {
+		t.Errorf("NewDetail() failed. Unexpected lists %v and %v", detail.ExpectedChannelHashes, detail.MismatchTiles)
+	}
+	if a := detail.Features.Channels["a"]; detail.Features.Version != 1 || a.Mean != 0.5 || a.Histogram[3] != 1 {
+		t.Errorf("NewDetail() failed. Unexpected features %+v", detail.Features)
+	}
+
+	// A different canvas size no longer reproduces the hash
+	if detail, _ := NewDetail(challenge, 8); detail.ExpectedCanvas.HashMatches {
+		t.Errorf("NewDetail() failed. Expected a mismatch on another canvas size")
+	}
+	if detail, _ := NewDetail(&models.Challenge{ID: "created"}, 4); detail.ExpectedCanvas != nil {
+		t.Errorf("NewDetail() failed. Expected no canvas before the challenge was issued")
+	}
+}
+
+func TestNewDetail_Submitted(t *testing.T) {
+	task := tasks.NewTaskGenerator(tasks.Rectangle{Color: "FF8000", W: 2, H: 2, X: 1, Y: 1}).GenerateTask()
+	// The client changed the alpha of the transparent pixel 0 and the red of the orange pixel 5
+	submitted, _ := tasks.Render(task, 4, 4)
+	submitted.A.Pix[0], submitted.R.Pix[5] = 1, 0xFE
+	actual, _ := newCanvas(submitted)
+
+	challenge := &models.Challenge{
+		ID:         "c1",
+		Task:       task,
+		ActualHash: actual.Hash,
+		Noise:      models.NoiseProfile{Class: "farbling", Pixels: 2},
+		Diff:       "0,0,0,0,1,5,-1,0,0,0",
+	}
+	detail, err := NewDetail(challenge, 4)
+	if err != nil {
+		t.Fatalf("NewDetail() failed: %v", err)
+	}
+	if detail.SubmittedCanvas == nil || !detail.SubmittedCanvas.HashMatches {
+		t.Fatalf("NewDetail() failed. Expected the submitted canvas to match the actual hash, got %+v", detail.SubmittedCanvas)
+	}
+	if detail.SubmittedCanvas.RGBA[3] != 1 || detail.SubmittedCanvas.RGBA[4*5] != 0xFE || detail.ExpectedCanvas.RGBA[4*5] != 0xFF {
+		t.Errorf("NewDetail() failed. The diff was not applied to a copy of the expected canvas")
+	}
+
+	// Without a diff the client's rendering is unknown
+	challenge.Noise.Class = ""
+	if detail, _ := NewDetail(challenge, 4); detail.SubmittedCanvas != nil {
+		t.Errorf("NewDetail() failed. Expected no submitted canvas without a diff")
+	}
+	challenge.Noise.Class, challenge.Diff = "farbling", "0,0,0,0,-1"
+	if _, err := NewDetail(challenge, 4); !errors.Is(err, tasks.ErrInvalidDiff) {
+		t.Errorf("NewDetail() failed. Expected %v, got %v", tasks.ErrInvalidDiff, err)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package analysis
+
+import "github.com/Litebrowsers/donatello/internal/tasks"
+
+// Reasons reported by CheckConsistency.
+const (
+	// InconsistentActualHash is reported when the diff applied to the expected rendering does not hash to the
+	// reported actual hash, so the client did not send the diff of the canvas it hashed.
+	InconsistentActualHash = "actual_hash"
+	// InconsistentDiffHash is reported when the diff hash is not the noise fingerprint of the diff.
+	InconsistentDiffHash = "diff_hash"
+	// InconsistentCopy is reported when the client claims its canvas changed in the copy test although it rendered
+	// exactly the prediction, which only has opaque and transparent pixels and survives a PNG round trip.
+	InconsistentCopy = "copy_mismatch"
+	// InconsistentMissingDiff is reported when the rendering differs from the prediction but the client did not
+	// report the diff, so it can't be checked. Claiming the diff was too large is only accepted for canvases with
+	// more than MaxDiffPixels pixels.
+	InconsistentMissingDiff = "missing_diff"
+	// InconsistentInvalidDiff is reported when the rendering differs from the prediction but the reported diff is
+	// malformed, see AnalyzeNoise.
+	InconsistentInvalidDiff = "invalid_diff"
+)
+
+// CheckConsistency cross-checks the values a client reports about its rendering of the first task. The diff, which
+// must be valid for AnalyzeNoise, is applied to the server's expected rendering to rebuild the client's canvas, and
+// the actual hash and the diff hash are recomputed from it. A nil diffHash is not checked. It returns the reasons the
+// answer contradicts itself, or nil.
+func CheckConsistency(expected *tasks.Canvas, diff []int, actualHash string, diffHash *string, copyMismatch bool) ([]string, error) {
+	actual := expected.Clone()
+	var reasons []string
+	if err := actual.ApplyDiff(diff); err != nil {
+		// A diff leaving 0 to 255 can't come from any rendering
+		reasons = append(reasons, InconsistentActualHash)
+	} else {
+		var err error
+		if reasons, err = checkRebuilt(expected, actual, actualHash, diffHash); err != nil {
+			return nil, err
+		}
+	}
+	if copyMismatch && len(diff) == 0 {
+		reasons = append(reasons, InconsistentCopy)
+	}
+	return reasons, nil
+}
+
+// checkRebuilt compares the hashes of the rebuilt client canvas actual with the reported ones.
+func checkRebuilt(expected, actual *tasks.Canvas, actualHash string, diffHash *string) ([]string, error) {
+	hashes, err := actual.CalculateHashes()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := actual.CalculateCombinedHash(hashes)
+	if err != nil {
+		return nil, err
+	}
+	var reasons []string
+	if hash != actualHash {
+		reasons = append(reasons, InconsistentActualHash)
+	}
+	if diffHash != nil {
+		fingerprint, err := tasks.NoiseFingerprint(expected, actual)
+		if err != nil {
+			return nil, err
+		}
+		if fingerprint != *diffHash {
+			reasons = append(reasons, InconsistentDiffHash)
+		}
+	}
+	return reasons, nil
+}
+
+// CheckMissingDiff returns the reason the client's diff could not be checked with CheckConsistency although its
+// rendering of a canvas with pixels pixels differs from the prediction. diff is nil if the client reported none and
+// invalid otherwise; truncated is the client's claim that more than MaxDiffPixels pixels differ. It returns nil only
+// for a claim the canvas size allows.
+func CheckMissingDiff(diff []int, truncated bool, pixels int) []string {
+	switch {
+	case diff != nil:
+		return []string{InconsistentInvalidDiff}
+	case truncated && pixels > MaxDiffPixels:
+		return nil
+	default:
+		return []string{InconsistentMissingDiff}
+	}
+}
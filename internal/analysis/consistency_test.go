@@ -0,0 +1,94 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Litebrowsers/donatello/internal/tasks"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	expected, err := tasks.Render("X:2:FF0000:0000FF;R:00FF00:2:2:2:2", 8, 8)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	diff := []int{0, -1, 0, 1, 0, 9, 2, 0, 0, 0}
+	actual := expected.Clone()
+	if err := actual.ApplyDiff(diff); err != nil {
+		t.Fatalf("ApplyDiff() failed: %v", err)
+	}
+	hash := func(c *tasks.Canvas) string {
+		hashes, _ := c.CalculateHashes()
+		combined, _ := c.CalculateCombinedHash(hashes)
+		return combined
+	}
+	actualHash, expectedHash := hash(actual), hash(expected)
+	diffHash, _ := tasks.NoiseFingerprint(expected, actual)
+	emptyDiffHash, _ := tasks.NoiseFingerprint(expected, expected)
+	forged := "forged"
+
+	tests := []struct {
+		name         string
+		diff         []int
+		actualHash   string
+		diffHash     *string
+		copyMismatch bool
+		expected     []string
+	}{
+		{name: "consistent", diff: diff, actualHash: actualHash, diffHash: &diffHash, copyMismatch: true},
+		{name: "exact rendering", diff: []int{}, actualHash: expectedHash, diffHash: &emptyDiffHash},
+		{name: "no diff hash", diff: diff, actualHash: actualHash},
+		{name: "other actual hash", diff: diff, actualHash: expectedHash, diffHash: &diffHash, expected: []string{InconsistentActualHash}},
+		{name: "forged diff hash", diff: diff, actualHash: actualHash, diffHash: &forged, expected: []string{InconsistentDiffHash}},
+		{name: "diff of the prediction", diff: []int{}, actualHash: actualHash, diffHash: &diffHash, expected: []string{InconsistentActualHash, InconsistentDiffHash}},
+		{name: "diff out of range", diff: []int{0, 0, 0, 0, 1}, actualHash: actualHash, diffHash: &diffHash, expected: []string{InconsistentActualHash}},
+		{name: "forged copy mismatch", diff: []int{}, actualHash: expectedHash, diffHash: &emptyDiffHash, copyMismatch: true, expected: []string{InconsistentCopy}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reasons, err := CheckConsistency(expected, tt.diff, tt.actualHash, tt.diffHash, tt.copyMismatch)
+			if err != nil {
+				t.Fatalf("CheckConsistency() failed: %v", err)
+			}
+			if !reflect.DeepEqual(reasons, tt.expected) {
+				t.Errorf("CheckConsistency() failed. Expected %v, got %v", tt.expected, reasons)
+			}
+		})
+	}
+}
+
+func TestCheckMissingDiff(t *testing.T) {
+	tests := []struct {
+		name      string
+		diff      []int
+		truncated bool
+		pixels    int
+		expected  []string
+	}{
+		{name: "missing", pixels: 400, expected: []string{InconsistentMissingDiff}},
+		{name: "invalid", diff: []int{1}, pixels: 400, expected: []string{InconsistentInvalidDiff}},
+		{name: "invalid and truncated", diff: []int{1}, truncated: true, pixels: 128 * 128, expected: []string{InconsistentInvalidDiff}},
+		{name: "truncated", truncated: true, pixels: MaxDiffPixels + 1},
+		{name: "truncated small canvas", truncated: true, pixels: MaxDiffPixels, expected: []string{InconsistentMissingDiff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if reasons := CheckMissingDiff(tt.diff, tt.truncated, tt.pixels); !reflect.DeepEqual(reasons, tt.expected) {
+				t.Errorf("CheckMissingDiff() failed. Expected %v, got %v", tt.expected, reasons)
+			}
+		})
+	}
+}
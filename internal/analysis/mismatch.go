@@ -0,0 +1,75 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package analysis
+
+// Scopes of a mismatch reported by LocalizeMismatch.
+const (
+	// ScopeNone means the rendering matches the expected one.
+	ScopeNone = "none"
+	// ScopeGlobal means most tiles diverged, as with noise injected into the whole canvas.
+	ScopeGlobal = "global"
+	// ScopeLocalized means only some tiles diverged, as with a renderer drawing a shape differently.
+	ScopeLocalized = "localized"
+	// ScopeUnknown means the client did not report tile hashes.
+	ScopeUnknown = "unknown"
+)
+
+// GlobalTileFraction is the fraction of diverged tiles from which a mismatch is global.
+const GlobalTileFraction = 0.75
+
+// Channels are the names of the canvas channels in hashing order.
+var Channels = []string{"red", "green", "blue", "alpha"}
+
+// Mismatch locates the differences between an expected and an actual rendering.
+type Mismatch struct {
+	// Channels are the diverged channels, see Channels.
+	Channels []string
+	// Tiles are the indices of the diverged tiles in row-major order.
+	Tiles []int
+	Scope string
+}
+
+// LocalizeMismatch compares the per-channel and per-tile hashes of a rendering with the expected ones. Tiles are
+// only compared if the client reported as many as expected.
+func LocalizeMismatch(expectedChannels, actualChannels map[string]string, expectedTiles, actualTiles []string) Mismatch {
+	var m Mismatch
+	for _, channel := range Channels {
+		if expectedChannels[channel] != actualChannels[channel] {
+			m.Channels = append(m.Channels, channel)
+		}
+	}
+
+	if len(expectedTiles) == 0 || len(actualTiles) != len(expectedTiles) {
+		m.Scope = ScopeUnknown
+		if len(m.Channels) == 0 && len(actualChannels) > 0 {
+			m.Scope = ScopeNone
+		}
+		return m
+	}
+	for i := range expectedTiles {
+		if expectedTiles[i] != actualTiles[i] {
+			m.Tiles = append(m.Tiles, i)
+		}
+	}
+
+	switch {
+	case len(m.Tiles) == 0:
+		m.Scope = ScopeNone
+	case float64(len(m.Tiles)) >= GlobalTileFraction*float64(len(expectedTiles)):
+		m.Scope = ScopeGlobal
+	default:
+		m.Scope = ScopeLocalized
+	}
+	return m
+}
@@ -0,0 +1,67 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLocalizeMismatch(t *testing.T) {
+	expectedChannels := map[string]string{"red": "r", "green": "g", "blue": "b", "alpha": "a"}
+	expectedTiles := []string{"0", "1", "2", "3"}
+
+	tests := []struct {
+		name     string
+		channels map[string]string
+		tiles    []string
+		expected Mismatch
+	}{
+		{
+			name:     "match",
+			channels: expectedChannels,
+			tiles:    expectedTiles,
+			expected: Mismatch{Scope: ScopeNone},
+		},
+		{
+			name:     "global noise",
+			channels: map[string]string{"red": "x", "green": "x", "blue": "x", "alpha": "a"},
+			tiles:    []string{"x", "x", "2", "x"},
+			expected: Mismatch{Channels: []string{"red", "green", "blue"}, Tiles: []int{0, 1, 3}, Scope: ScopeGlobal},
+		},
+		{
+			name:     "localized",
+			channels: map[string]string{"red": "x", "green": "g", "blue": "b", "alpha": "a"},
+			tiles:    []string{"0", "1", "x", "3"},
+			expected: Mismatch{Channels: []string{"red"}, Tiles: []int{2}, Scope: ScopeLocalized},
+		},
+		{
+			name:     "no tiles reported",
+			channels: map[string]string{"red": "x", "green": "g", "blue": "b", "alpha": "a"},
+			expected: Mismatch{Channels: []string{"red"}, Scope: ScopeUnknown},
+		},
+		{
+			name:     "nothing reported",
+			expected: Mismatch{Channels: Channels, Scope: ScopeUnknown},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := LocalizeMismatch(expectedChannels, tt.channels, expectedTiles, tt.tiles)
+			if !reflect.DeepEqual(m, tt.expected) {
+				t.Errorf("LocalizeMismatch() failed. Expected %+v, got %+v", tt.expected, m)
+			}
+		})
+	}
+}
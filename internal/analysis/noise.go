@@ -0,0 +1,172 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package analysis
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/Litebrowsers/donatello/internal/models"
+)
+
+// Noise classes reported by AnalyzeNoise.
+const (
+	// NoiseNone means the rendering matches the prediction.
+	NoiseNone = "none"
+	// NoiseFarbling means few pixels changed by one or two levels, like the seeded farbling of privacy browsers.
+	NoiseFarbling = "farbling"
+	// NoiseRandom means most pixels changed by small amounts, like anti-fingerprinting extensions randomizing the
+	// canvas.
+	NoiseRandom = "random"
+	// NoiseRendering means large differences confined to a few tiles, like anti-aliasing of a different renderer.
+	NoiseRendering = "rendering"
+	// NoiseReplaced means large differences all over the canvas, like a blocked or substituted canvas.
+	NoiseReplaced = "replaced"
+	// NoiseOther is any other difference.
+	NoiseOther = "other"
+	// NoiseInvalid means the diff is malformed or does not fit the canvas.
+	NoiseInvalid = "invalid"
+)
+
+// MaxDiffPixels is the largest number of pixels a diff may list.
+const MaxDiffPixels = 4096
+
+// diffStride is the number of values per pixel in a diff: the index and the four channel differences.
+const diffStride = 5
+
+// magnitudeBuckets are the upper bounds of the magnitude histogram buckets. Larger magnitudes fall in a last bucket.
+var magnitudeBuckets = []int{1, 2, 4, 16}
+
+// AnalyzeNoise characterizes the sparse diff of a width×height rendering, see models.ChallengeAnswer.Diff. Tiles are
+// counted on a grid×grid grid like Canvas.TileHashes. The Seeded field is left to the caller, which knows other
+// challenges.
+func AnalyzeNoise(diff []int, width, height, grid int) models.NoiseProfile {
+	if len(diff)%diffStride != 0 || len(diff)/diffStride > MaxDiffPixels || grid <= 0 {
+		return models.NoiseProfile{Class: NoiseInvalid}
+	}
+
+	profile := models.NoiseProfile{Pixels: len(diff) / diffStride}
+	if profile.Pixels == 0 {
+		profile.Class = NoiseNone
+		return profile
+	}
+
+	var channels [4]bool
+	histogram := make([]int, len(magnitudeBuckets)+1)
+	tiles := make(map[int]bool)
+	pattern := sha256.New()
+	magnitudeSum := 0
+	previous := -1
+	for i := 0; i < len(diff); i += diffStride {
+		index := diff[i]
+		if index <= previous || index >= width*height {
+			return models.NoiseProfile{Class: NoiseInvalid}
+		}
+		previous = index
+
+		magnitude := 0
+		signs := make([]byte, 0, 4)
+		for c, delta := range diff[i+1 : i+diffStride] {
+			if delta < -255 || delta > 255 {
+				return models.NoiseProfile{Class: NoiseInvalid}
+			}
+			if delta != 0 {
+				channels[c] = true
+			}
+			magnitude = max(magnitude, abs(delta))
+			signs = append(signs, sign(delta))
+		}
+		if magnitude == 0 {
+			return models.NoiseProfile{Class: NoiseInvalid}
+		}
+
+		magnitudeSum += magnitude
+		profile.MaxMagnitude = max(profile.MaxMagnitude, magnitude)
+		histogram[bucket(magnitude)]++
+		x, y := index%width, index/width
+		tiles[(y*grid/height)*grid+x*grid/width] = true
+		pattern.Write([]byte(strconv.Itoa(index) + ":" + string(signs) + ";"))
+	}
+
+	var names []string
+	for c, changed := range channels {
+		if changed {
+			names = append(names, Channels[c])
+		}
+	}
+	profile.Channels = strings.Join(names, ",")
+	profile.MeanMagnitude = float64(magnitudeSum) / float64(profile.Pixels)
+	profile.Magnitudes = joinCounts(histogram)
+	profile.Tiles = len(tiles)
+	profile.Pattern = hex.EncodeToString(pattern.Sum(nil))
+	profile.Class = classify(profile, width*height, grid*grid)
+	return profile
+}
+
+// classify assigns the noise class from the shape of the profile.
+func classify(p models.NoiseProfile, pixels, tiles int) string {
+	coverage := float64(p.Pixels) / float64(pixels)
+	spread := float64(p.Tiles) / float64(tiles)
+	switch {
+	case p.MaxMagnitude <= 2 && coverage < 0.5:
+		return NoiseFarbling
+	case p.MaxMagnitude <= 16 && coverage >= 0.5:
+		return NoiseRandom
+	case p.MeanMagnitude > 64 && coverage >= 0.5:
+		return NoiseReplaced
+	case p.MeanMagnitude > 16 && spread < GlobalTileFraction:
+		return NoiseRendering
+	default:
+		return NoiseOther
+	}
+}
+
+// bucket returns the magnitude histogram bucket of magnitude.
+func bucket(magnitude int) int {
+	for i, upper := range magnitudeBuckets {
+		if magnitude <= upper {
+			return i
+		}
+	}
+	return len(magnitudeBuckets)
+}
+
+func joinCounts(counts []int) string {
+	parts := make([]string, len(counts))
+	for i, count := range counts {
+		parts[i] = strconv.Itoa(count)
+	}
+	return strings.Join(parts, ",")
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// sign returns '-', '0' or '+' for the sign of v.
+func sign(v int) byte {
+	switch {
+	case v < 0:
+		return '-'
+	case v > 0:
+		return '+'
+	default:
+		return '0'
+	}
+}
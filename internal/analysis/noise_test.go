@@ -0,0 +1,72 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package analysis
+
+import "testing"
+
+// diffOf builds a diff changing every step-th pixel of a 20×20 canvas by delta in the given channel.
+func diffOf(step, channel, delta int) []int {
+	var diff []int
+	for i := 0; i < 400; i += step {
+		pixel := []int{i, 0, 0, 0, 0}
+		pixel[1+channel] = delta
+		diff = append(diff, pixel...)
+	}
+	return diff
+}
+
+func TestAnalyzeNoise(t *testing.T) {
+	tests := []struct {
+		name     string
+		diff     []int
+		expected string
+	}{
+		{name: "no diff", expected: NoiseNone},
+		{name: "farbling", diff: diffOf(7, 0, 1), expected: NoiseFarbling},
+		{name: "random", diff: diffOf(1, 2, -5), expected: NoiseRandom},
+		{name: "replaced", diff: diffOf(1, 1, 200), expected: NoiseReplaced},
+		{name: "rendering", diff: []int{21, 90, 90, 0, 0, 22, 120, 0, 0, 0}, expected: NoiseRendering},
+		{name: "truncated", diff: []int{1, 2, 3}, expected: NoiseInvalid},
+		{name: "unordered", diff: []int{5, 1, 0, 0, 0, 4, 1, 0, 0, 0}, expected: NoiseInvalid},
+		{name: "outside the canvas", diff: []int{400, 1, 0, 0, 0}, expected: NoiseInvalid},
+		{name: "out of range", diff: []int{1, 300, 0, 0, 0}, expected: NoiseInvalid},
+		{name: "unchanged pixel", diff: []int{1, 0, 0, 0, 0}, expected: NoiseInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if profile := AnalyzeNoise(tt.diff, 20, 20, 4); profile.Class != tt.expected {
+				t.Errorf("AnalyzeNoise() failed. Expected %s, got %s (%+v)", tt.expected, profile.Class, profile)
+			}
+		})
+	}
+}
+
+func TestAnalyzeNoise_Profile(t *testing.T) {
+	diff := []int{0, 1, 0, 0, 0, 7, 0, -2, 0, 0, 399, 0, 0, 30, 0}
+	profile := AnalyzeNoise(diff, 20, 20, 4)
+
+	if profile.Pixels != 3 || profile.Channels != "red,green,blue" || profile.MaxMagnitude != 30 {
+		t.Errorf("AnalyzeNoise() failed. Unexpected profile %+v", profile)
+	}
+	if profile.MeanMagnitude != 11 || profile.Magnitudes != "1,1,0,0,1" || profile.Tiles != 3 {
+		t.Errorf("AnalyzeNoise() failed. Unexpected distribution %+v", profile)
+	}
+
+	// The pattern depends on positions and directions, not magnitudes
+	same := AnalyzeNoise([]int{0, 2, 0, 0, 0, 7, 0, -1, 0, 0, 399, 0, 0, 9, 0}, 20, 20, 4)
+	other := AnalyzeNoise([]int{0, -1, 0, 0, 0, 7, 0, -2, 0, 0, 399, 0, 0, 30, 0}, 20, 20, 4)
+	if same.Pattern != profile.Pattern || other.Pattern == profile.Pattern {
+		t.Errorf("Pattern failed. Expected %s to match and %s to differ from %s", same.Pattern, other.Pattern, profile.Pattern)
+	}
+}
@@ -0,0 +1,48 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package analysis
+
+import "github.com/Litebrowsers/donatello/internal/models"
+
+// MinRandomizedSessions is the number of sessions, including the current one, that must all have rendered the
+// second task differently before the fingerprint counts as randomized. A single change is usually a browser or
+// driver update.
+const MinRandomizedSessions = 3
+
+// CheckStability compares the Fingerprint and NoiseHash of current with the previous answered challenges of the
+// same visitor. Fingerprints are only compared between challenges with the same second task. NoiseHash depends on
+// the random first task unless the rendering matched the prediction, so its changes are reported but not judged.
+func CheckStability(current *models.Challenge, previous []models.Challenge) models.Stability {
+	stability := models.Stability{Sessions: len(previous)}
+	fingerprints := make(map[string]bool)
+	if current.Fingerprint != "" {
+		fingerprints[current.Fingerprint] = true
+	}
+	compared := 0
+	for _, p := range previous {
+		if p.SecondTaskID == current.SecondTaskID && p.Fingerprint != "" && current.Fingerprint != "" {
+			compared++
+			fingerprints[p.Fingerprint] = true
+			if p.Fingerprint != current.Fingerprint {
+				stability.FingerprintChanges++
+			}
+		}
+		if p.NoiseHash != nil && current.NoiseHash != nil && *p.NoiseHash != *current.NoiseHash {
+			stability.NoiseHashChanges++
+		}
+	}
+	stability.Fingerprints = len(fingerprints)
+	stability.Randomized = compared+1 >= MinRandomizedSessions && stability.Fingerprints == compared+1
+	return stability
+}
@@ -0,0 +1,73 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package analysis
+
+import (
+	"testing"
+
+	"github.com/Litebrowsers/donatello/internal/models"
+)
+
+func TestCheckStability(t *testing.T) {
+	session := func(fingerprint, noiseHash string) models.Challenge {
+		c := models.Challenge{SecondTaskID: 1, Fingerprint: fingerprint}
+		if noiseHash != "" {
+			c.NoiseHash = &noiseHash
+		}
+		return c
+	}
+	otherTask := session("x", "")
+	otherTask.SecondTaskID = 2
+
+	tests := []struct {
+		name     string
+		current  models.Challenge
+		previous []models.Challenge
+		expected models.Stability
+	}{
+		{name: "first visit", current: session("a", "n"), expected: models.Stability{Fingerprints: 1}},
+		{
+			name: "stable", current: session("a", "n"),
+			previous: []models.Challenge{session("a", "n"), session("a", "n")},
+			expected: models.Stability{Sessions: 2, Fingerprints: 1},
+		},
+		{
+			name: "updated browser", current: session("b", "n"),
+			previous: []models.Challenge{session("a", "n"), session("a", "n")},
+			expected: models.Stability{Sessions: 2, FingerprintChanges: 2, Fingerprints: 2},
+		},
+		{
+			name: "randomized", current: session("c", "n3"),
+			previous: []models.Challenge{session("b", "n2"), session("a", "n1")},
+			expected: models.Stability{Sessions: 2, FingerprintChanges: 2, NoiseHashChanges: 2, Fingerprints: 3, Randomized: true},
+		},
+		{
+			name: "too few sessions", current: session("b", ""),
+			previous: []models.Challenge{session("a", "n")},
+			expected: models.Stability{Sessions: 1, FingerprintChanges: 1, Fingerprints: 2},
+		},
+		{
+			name: "other second task", current: session("c", ""),
+			previous: []models.Challenge{otherTask, session("b", ""), session("a", "")},
+			expected: models.Stability{Sessions: 3, FingerprintChanges: 2, Fingerprints: 3, Randomized: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckStability(&tt.current, tt.previous); got != tt.expected {
+				t.Errorf("CheckStability() failed. Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
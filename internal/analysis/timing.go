@@ -0,0 +1,109 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package analysis inspects answered challenges for signs of automation.
+package analysis
+
+import (
+	"math"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/models"
+)
+
+// Reasons reported by CheckTimings.
+const (
+	// TimingTooFast is reported when the challenge was answered faster than a browser can draw and hash it.
+	TimingTooFast = "too_fast"
+	// TimingUniform is reported when the step durations are nearly identical, which real measurements never are.
+	TimingUniform = "uniform"
+	// TimingInconsistent is reported when the client claims more work than fits between the server-observed
+	// issue and answer times, or reports negative durations.
+	TimingInconsistent = "inconsistent"
+)
+
+const (
+	// MinServerWindow is the shortest plausible time between issuing a challenge and receiving its answer.
+	MinServerWindow = 10 * time.Millisecond
+	// MinClientWork is the shortest plausible total duration of the client-side steps after the fetch.
+	MinClientWork = time.Millisecond
+	// MaxUniformity is the coefficient of variation of the step durations at or below which they look fabricated.
+	MaxUniformity = 0.05
+	// MinClampResolution is the finest resolution of performance.now() treated as clamped. Browsers resisting
+	// fingerprinting, like Firefox with resistFingerprinting, Tor Browser and Brave, clamp it to 1ms or more and
+	// report short steps as 0.
+	MinClampResolution = time.Millisecond
+	// clockTolerance absorbs the different resolutions of performance.now() and the server clock.
+	clockTolerance = 5 * time.Millisecond
+)
+
+// CheckTimings cross-checks the client-reported timings against the server-observed times the challenge was issued
+// and answered at. It returns the reasons the timings look automated, or nil.
+func CheckTimings(t models.Timings, issuedAt, answeredAt time.Time) []string {
+	steps := []float64{t.Fetch, t.Draw, t.GetImageData, t.Hash, t.CopyTest}
+	for _, step := range steps {
+		if step < 0 || math.IsNaN(step) || math.IsInf(step, 0) {
+			return []string{TimingInconsistent}
+		}
+	}
+
+	var reasons []string
+	window := answeredAt.Sub(issuedAt)
+	work := milliseconds(t.Draw + t.GetImageData + t.Hash + t.CopyTest)
+	// A clamped timer can't measure the client work, only the server window
+	if window < MinServerWindow || (work < MinClientWork && !clamped(steps)) {
+		reasons = append(reasons, TimingTooFast)
+	}
+	if uniform(steps) {
+		reasons = append(reasons, TimingUniform)
+	}
+	// All steps after the fetch happen between the response to GET /challenge and the arrival of POST /challenge
+	if work > window+clockTolerance {
+		reasons = append(reasons, TimingInconsistent)
+	}
+	return reasons
+}
+
+// clamped reports whether values, in milliseconds, look measured with a timer clamped to MinClampResolution or
+// coarser: every value is either 0 or at least the resolution.
+func clamped(values []float64) bool {
+	for _, v := range values {
+		if v > 0 && milliseconds(v) < MinClampResolution {
+			return false
+		}
+	}
+	return true
+}
+
+// uniform reports whether the coefficient of variation of values is at most MaxUniformity.
+func uniform(values []float64) bool {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if mean == 0 {
+		return false
+	}
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(values))
+	return math.Sqrt(variance)/mean <= MaxUniformity
+}
+
+// milliseconds converts ms to a time.Duration.
+func milliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,56 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package analysis
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/models"
+)
+
+func TestCheckTimings(t *testing.T) {
+	issuedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	browser := models.Timings{Fetch: 42.3, Draw: 3.1, GetImageData: 0.8, Hash: 2.4, CopyTest: 11.7}
+
+	tests := []struct {
+		name     string
+		timings  models.Timings
+		window   time.Duration
+		expected []string
+	}{
+		{name: "browser", timings: browser, window: 60 * time.Millisecond},
+		{name: "answered too fast", timings: browser, window: 5 * time.Millisecond, expected: []string{TimingTooFast, TimingInconsistent}},
+		{name: "no client work", timings: models.Timings{Fetch: 30.2, Draw: 0.1, Hash: 0.2}, window: time.Second, expected: []string{TimingTooFast}},
+		// Browsers clamping performance.now() report the short steps as 0
+		{name: "clamped to 1ms", timings: models.Timings{Fetch: 31}, window: time.Second},
+		{name: "clamped to 100ms", timings: models.Timings{Fetch: 100}, window: 150 * time.Millisecond},
+		{name: "clamped to 16.67ms", timings: models.Timings{Fetch: 33.34, CopyTest: 16.67}, window: 80 * time.Millisecond},
+		{name: "clamped and too fast", timings: models.Timings{}, window: 5 * time.Millisecond, expected: []string{TimingTooFast}},
+		{name: "uniform", timings: models.Timings{Fetch: 10, Draw: 10, GetImageData: 10, Hash: 10, CopyTest: 10.2}, window: time.Second, expected: []string{TimingUniform}},
+		{name: "more work than the window", timings: browser, window: 12 * time.Millisecond, expected: []string{TimingInconsistent}},
+		{name: "negative", timings: models.Timings{Fetch: 10, Draw: -1}, window: time.Second, expected: []string{TimingInconsistent}},
+		{name: "not a number", timings: models.Timings{Fetch: math.NaN()}, window: time.Second, expected: []string{TimingInconsistent}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reasons := CheckTimings(tt.timings, issuedAt, issuedAt.Add(tt.window))
+			if !reflect.DeepEqual(reasons, tt.expected) {
+				t.Errorf("CheckTimings() failed. Expected %v, got %v", tt.expected, reasons)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package cleanup marks expired challenges that were never answered as no-JS.
+package cleanup
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/clock"
+	"github.com/Litebrowsers/donatello/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultBatchSize is the number of challenges processed per batch when none is configured.
+const DefaultBatchSize = 500
+
+// Event is emitted for every challenge the worker marks as no-JS.
+type Event struct {
+	ChallengeID string
+	ExpiresAt   time.Time
+	MarkedAt    time.Time
+}
+
+// Stats describes the worker's activity.
+type Stats struct {
+	LastRun      time.Time
+	LastDuration time.Duration
+	LastRows     int64
+	TotalRows    int64
+	Runs         int64
+	Errors       int64
+	// Heartbeat is the last time the worker loop was alive.
+	Heartbeat time.Time
+}
+
+// Worker periodically marks expired, unanswered challenges as no-JS.
+type Worker struct {
+	db        *gorm.DB
+	clock     clock.Clock
+	interval  time.Duration
+	batchSize int
+	onExpired func(Event)
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// NewWorker creates a new Worker. clk defaults to the system clock if nil. onExpired is called once per expired
+// challenge and may be nil.
+func NewWorker(db *gorm.DB, clk clock.Clock, interval time.Duration, batchSize int, onExpired func(Event)) *Worker {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if clk == nil {
+		clk = clock.Real{}
+	}
+	return &Worker{
+		db:        db,
+		clock:     clk,
+		interval:  interval,
+		batchSize: batchSize,
+		onExpired: onExpired,
+	}
+}
+
+// Run processes expired challenges every interval until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.beat()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rows, err := w.RunOnce(ctx)
+			w.beat()
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("failed to clean up expired challenges", "error", err)
+				}
+				continue
+			}
+			if rows > 0 {
+				slog.Info("marked expired challenges as no-js", "rows", rows)
+			}
+		}
+	}
+}
+
+// RunOnce marks all currently expired challenges in batches and returns the number of rows updated.
+func (w *Worker) RunOnce(ctx context.Context) (int64, error) {
+	start := w.clock.Now()
+	var total int64
+	var err error
+
+	for ctx.Err() == nil {
+		var selected, marked int
+		selected, marked, err = w.processBatch(ctx, start)
+		total += int64(marked)
+		if err != nil || selected < w.batchSize {
+			break
+		}
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	w.mu.Lock()
+	w.stats.LastRun = start
+	w.stats.LastDuration = w.clock.Now().Sub(start)
+	w.stats.LastRows = total
+	w.stats.TotalRows += total
+	w.stats.Runs++
+	if err != nil {
+		w.stats.Errors++
+	}
+	w.mu.Unlock()
+
+	return total, err
+}
+
+// Interval returns the time between two runs.
+func (w *Worker) Interval() time.Duration {
+	return w.interval
+}
+
+// beat records that the worker loop is alive.
+func (w *Worker) beat() {
+	w.mu.Lock()
+	w.stats.Heartbeat = w.clock.Now()
+	w.mu.Unlock()
+}
+
+// Stats returns a snapshot of the worker's activity.
+func (w *Worker) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stats
+}
+
+// processBatch marks at most batchSize challenges that expired before now and returns the number of challenges
+// selected and marked. Challenges answered between both steps are skipped. Challenges expiring exactly at now are
+// still valid, see models.Challenge.Expired.
+func (w *Worker) processBatch(ctx context.Context, now time.Time) (int, int, error) {
+	var batch, marked []models.Challenge
+	err := w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Challenge{}).
+			Select("id", "expires_at").
+			Where("expires_at < ? AND java_script IS NULL", now).
+			Order("expires_at").
+			Limit(w.batchSize).
+			Find(&batch).Error
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+
+		ids := make([]string, len(batch))
+		for i, challenge := range batch {
+			ids[i] = challenge.ID
+		}
+		// Only the returned rows were still unanswered
+		return tx.Model(&marked).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+			Where("id IN ? AND java_script IS NULL", ids).
+			Update("java_script", false).Error
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if w.onExpired != nil {
+		updated := make(map[string]bool, len(marked))
+		for _, challenge := range marked {
+			updated[challenge.ID] = true
+		}
+		markedAt := w.clock.Now()
+		// Events follow the expiry order of the batch
+		for _, challenge := range batch {
+			if updated[challenge.ID] {
+				w.onExpired(Event{ChallengeID: challenge.ID, ExpiresAt: challenge.ExpiresAt, MarkedAt: markedAt})
+			}
+		}
+	}
+	return len(batch), len(marked), nil
+}
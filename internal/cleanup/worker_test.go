@@ -0,0 +1,194 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package cleanup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/clock"
+	"github.com/Litebrowsers/donatello/internal/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Challenge{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	return db
+}
+
+func TestWorker_RunOnce(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	answered := true
+
+	challenges := []models.Challenge{
+		{ID: "expired-1", ExpiresAt: now.Add(-3 * time.Minute)},
+		{ID: "expired-2", ExpiresAt: now.Add(-2 * time.Minute)},
+		{ID: "expired-3", ExpiresAt: now.Add(-1 * time.Minute)},
+		{ID: "answered", ExpiresAt: now.Add(-1 * time.Minute), JavaScript: &answered},
+		{ID: "pending", ExpiresAt: now.Add(time.Minute)},
+	}
+	for i := range challenges {
+		if err := db.Create(&challenges[i]).Error; err != nil {
+			t.Fatalf("Failed to create challenge %s: %v", challenges[i].ID, err)
+		}
+	}
+
+	var events []Event
+	worker := NewWorker(db, clock.NewFake(now), time.Minute, 2, func(e Event) {
+		events = append(events, e)
+	})
+
+	rows, err := worker.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce() returned an error: %v", err)
+	}
+	if rows != 3 {
+		t.Errorf("Expected 3 rows to be marked, got %d", rows)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	for i, id := range []string{"expired-1", "expired-2", "expired-3"} {
+		if events[i].ChallengeID != id {
+			t.Errorf("Event %d: expected challenge %s, got %s", i, id, events[i].ChallengeID)
+		}
+	}
+
+	expectations := map[string]*bool{"expired-1": new(bool), "answered": &answered, "pending": nil}
+	for id, expected := range expectations {
+		var challenge models.Challenge
+		if err := db.First(&challenge, "id = ?", id).Error; err != nil {
+			t.Fatalf("Failed to load challenge %s: %v", id, err)
+		}
+		switch {
+		case expected == nil && challenge.JavaScript != nil:
+			t.Errorf("Challenge %s: expected JavaScript to stay NULL, got %v", id, *challenge.JavaScript)
+		case expected != nil && (challenge.JavaScript == nil || *challenge.JavaScript != *expected):
+			t.Errorf("Challenge %s: expected JavaScript %v, got %v", id, *expected, challenge.JavaScript)
+		}
+	}
+
+	stats := worker.Stats()
+	if stats.Runs != 1 || stats.LastRows != 3 || stats.TotalRows != 3 || stats.Errors != 0 {
+		t.Errorf("Unexpected stats after first run: %+v", stats)
+	}
+
+	rows, err = worker.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("Second RunOnce() returned an error: %v", err)
+	}
+	if rows != 0 {
+		t.Errorf("Expected no rows on second run, got %d", rows)
+	}
+	if stats := worker.Stats(); stats.Runs != 2 || stats.LastRows != 0 || stats.TotalRows != 3 {
+		t.Errorf("Unexpected stats after second run: %+v", stats)
+	}
+}
+
+func TestWorker_ConcurrentAnswer(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, id := range []string{"expired", "answering"} {
+		if err := db.Create(&models.Challenge{ID: id, ExpiresAt: now.Add(-time.Minute)}).Error; err != nil {
+			t.Fatalf("Failed to create challenge %s: %v", id, err)
+		}
+	}
+	// The answer arrives after the worker selected the batch but before it updates it
+	err := db.Callback().Update().Before("gorm:update").Register("test:answer", func(tx *gorm.DB) {
+		if _, err := tx.Statement.ConnPool.ExecContext(tx.Statement.Context,
+			"UPDATE challenges SET java_script = true WHERE id = ?", "answering"); err != nil {
+			t.Errorf("Failed to answer the challenge: %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Failed to register the callback: %v", err)
+	}
+
+	var events []Event
+	worker := NewWorker(db, clock.NewFake(now), time.Minute, 0, func(e Event) {
+		events = append(events, e)
+	})
+	rows, err := worker.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce() returned an error: %v", err)
+	}
+	if rows != 1 || len(events) != 1 || events[0].ChallengeID != "expired" {
+		t.Errorf("Expected only the unanswered challenge to be marked, got %d rows and %+v", rows, events)
+	}
+}
+
+func TestWorker_ExpiryBoundary(t *testing.T) {
+	db := newTestDB(t)
+	expiresAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	if err := db.Create(&models.Challenge{ID: "boundary", ExpiresAt: expiresAt}).Error; err != nil {
+		t.Fatalf("Failed to create challenge: %v", err)
+	}
+
+	fake := clock.NewFake(expiresAt)
+	var events []Event
+	worker := NewWorker(db, fake, time.Minute, 0, func(e Event) {
+		events = append(events, e)
+	})
+
+	// The challenge can still be answered exactly at ExpiresAt
+	if rows, err := worker.RunOnce(context.Background()); err != nil || rows != 0 {
+		t.Fatalf("Expected no rows at ExpiresAt, got %d (%v)", rows, err)
+	}
+
+	fake.Advance(time.Nanosecond)
+	if rows, err := worker.RunOnce(context.Background()); err != nil || rows != 1 {
+		t.Fatalf("Expected 1 row right after ExpiresAt, got %d (%v)", rows, err)
+	}
+	if len(events) != 1 || !events[0].MarkedAt.Equal(fake.Now()) {
+		t.Errorf("Expected the event to be marked at %v, got %+v", fake.Now(), events)
+	}
+	if stats := worker.Stats(); !stats.LastRun.Equal(fake.Now()) || stats.LastDuration != 0 {
+		t.Errorf("Expected the stats to use the fake clock, got %+v", stats)
+	}
+}
+
+func TestWorker_RunStopsOnCancel(t *testing.T) {
+	db := newTestDB(t)
+	worker := NewWorker(db, nil, time.Millisecond, 0, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		worker.Run(ctx)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not return after the context was cancelled")
+	}
+	if worker.Stats().Heartbeat.IsZero() {
+		t.Error("Expected Run() to record a heartbeat")
+	}
+}
@@ -0,0 +1,37 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	fake := NewFake(start)
+	if !fake.Now().Equal(start) {
+		t.Errorf("Now() failed. Expected %v, got %v", start, fake.Now())
+	}
+
+	fake.Advance(time.Minute)
+	if expected := start.Add(time.Minute); !fake.Now().Equal(expected) {
+		t.Errorf("Advance() failed. Expected %v, got %v", expected, fake.Now())
+	}
+
+	fake.Set(start)
+	if !fake.Now().Equal(start) {
+		t.Errorf("Set() failed. Expected %v, got %v", start, fake.Now())
+	}
+}
@@ -0,0 +1,334 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package config loads and validates the application configuration.
+//
+// Values are resolved in the following order, later sources overriding earlier ones:
+// built-in defaults, the JSON configuration file, environment variables and command line flags.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Config is the complete application configuration.
+type Config struct {
+	Server    ServerConfig    `json:"server"`
+	TLS       TLSConfig       `json:"tls"`
+	Challenge ChallengeConfig `json:"challenge"`
+	Cleanup   CleanupConfig   `json:"cleanup"`
+	Database  DatabaseConfig  `json:"database"`
+	RateLimit RateLimitConfig `json:"rate_limit"`
+	Log       LogConfig       `json:"log"`
+	Tracing   TracingConfig   `json:"tracing"`
+	Web       WebConfig       `json:"web"`
+	CORS      CORSConfig      `json:"cors"`
+	Verdict   VerdictConfig   `json:"verdict"`
+	Admin     AdminConfig     `json:"admin"`
+	Visitor   VisitorConfig   `json:"visitor"`
+}
+
+// ServerConfig configures the HTTP server.
+type ServerConfig struct {
+	Port              int      `json:"port"`
+	ReadTimeout       Duration `json:"read_timeout"`
+	ReadHeaderTimeout Duration `json:"read_header_timeout"`
+	WriteTimeout      Duration `json:"write_timeout"`
+	IdleTimeout       Duration `json:"idle_timeout"`
+	ShutdownTimeout   Duration `json:"shutdown_timeout"`
+	MaxHeaderBytes    int      `json:"max_header_bytes"`
+	MaxBodyBytes      int64    `json:"max_body_bytes"`
+	// TrustedProxies lists the IP addresses and CIDR ranges of reverse proxies whose X-Forwarded-For and
+	// X-Forwarded-Proto headers are honoured.
+	TrustedProxies []string `json:"trusted_proxies"`
+}
+
+// TrustedProxyPrefixes parses TrustedProxies. Single addresses become prefixes of their full length.
+func (c ServerConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(c.TrustedProxies))
+	for _, proxy := range c.TrustedProxies {
+		if addr, err := netip.ParseAddr(proxy); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// TLSConfig configures TLS. TLS is enabled when both files are set.
+type TLSConfig struct {
+	CertFile       string   `json:"cert_file"`
+	KeyFile        string   `json:"key_file"`
+	ReloadInterval Duration `json:"reload_interval"`
+}
+
+// Enabled reports whether TLS is configured.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+// ChallengeConfig configures challenge generation.
+type ChallengeConfig struct {
+	Expiration Duration `json:"expiration"`
+	CanvasSize int      `json:"canvas_size"`
+	// UploadMaxBytes is the largest canvas upload of the SDK as a PNG data URL. Uploads are disabled when zero.
+	UploadMaxBytes int `json:"upload_max_bytes"`
+}
+
+// CleanupConfig configures the expired challenge cleanup worker.
+type CleanupConfig struct {
+	// Interval defaults to twice the challenge expiration when zero.
+	Interval  Duration `json:"interval"`
+	BatchSize int      `json:"batch_size"`
+}
+
+// DatabaseConfig configures the database connection.
+type DatabaseConfig struct {
+	Path string `json:"path"`
+}
+
+// RateLimitConfig configures the global request rate limiter.
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+}
+
+// LogConfig configures logging.
+type LogConfig struct {
+	Level string `json:"level"`
+	// Debug enables debug mode, which logs fingerprint hashes in clear text.
+	Debug bool `json:"debug"`
+}
+
+// TracingConfig configures OpenTelemetry tracing.
+type TracingConfig struct {
+	// Exporter is one of none, stdout or otlp.
+	Exporter string `json:"exporter"`
+	// Endpoint is the host:port of the OTLP/HTTP collector.
+	Endpoint    string  `json:"endpoint"`
+	Insecure    bool    `json:"insecure"`
+	SampleRatio float64 `json:"sample_ratio"`
+	ServiceName string  `json:"service_name"`
+}
+
+// WebConfig configures the web resources.
+type WebConfig struct {
+	// OverrideDir serves index.html and the worker from disk instead of the embedded copies, for development.
+	OverrideDir string `json:"override_dir"`
+}
+
+// CORSConfig configures cross-origin access for the JS SDK.
+type CORSConfig struct {
+	// AllowedOrigins lists the origins that may run challenges, e.g. https://shop.example.com. "*" allows any.
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+// VerdictConfig configures verdict tokens.
+type VerdictConfig struct {
+	// Secret signs verdict tokens. A random secret is generated at startup when it is empty.
+	Secret string   `json:"secret"`
+	TTL    Duration `json:"ttl"`
+}
+
+// AdminConfig configures the admin API.
+type AdminConfig struct {
+	// Token authenticates admin requests as a bearer token. The admin API is disabled when it is empty.
+	Token string `json:"token"`
+	// SimilarityWindow is the number of most recently answered challenges searched for similar fingerprints.
+	SimilarityWindow int `json:"similarity_window"`
+}
+
+// VisitorConfig configures the visitor cookie linking the challenges of a browser.
+type VisitorConfig struct {
+	// Cookie enables the donatello_vid cookie. Without it fingerprints are not compared across sessions.
+	Cookie       bool     `json:"cookie"`
+	CookieMaxAge Duration `json:"cookie_max_age"`
+	// History is the number of previous sessions a fingerprint is compared with.
+	History int `json:"history"`
+}
+
+// Default returns the configuration used when nothing else is specified.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:              8080,
+			ReadTimeout:       Duration{10 * time.Second},
+			ReadHeaderTimeout: Duration{5 * time.Second},
+			WriteTimeout:      Duration{15 * time.Second},
+			IdleTimeout:       Duration{60 * time.Second},
+			ShutdownTimeout:   Duration{15 * time.Second},
+			MaxHeaderBytes:    32 << 10,
+			MaxBodyBytes:      64 << 10,
+		},
+		TLS: TLSConfig{
+			ReloadInterval: Duration{time.Minute},
+		},
+		Challenge: ChallengeConfig{
+			Expiration: Duration{time.Minute},
+			CanvasSize: 20,
+		},
+		Cleanup: CleanupConfig{
+			BatchSize: 500,
+		},
+		Database: DatabaseConfig{
+			Path: "donatello.db",
+		},
+		RateLimit: RateLimitConfig{
+			RequestsPerSecond: 5,
+			Burst:             10,
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			Endpoint:    "localhost:4318",
+			SampleRatio: 1,
+			ServiceName: "donatello",
+		},
+		Verdict: VerdictConfig{
+			TTL: Duration{30 * time.Minute},
+		},
+		Admin: AdminConfig{
+			SimilarityWindow: 10000,
+		},
+		Visitor: VisitorConfig{
+			CookieMaxAge: Duration{180 * 24 * time.Hour},
+			History:      10,
+		},
+	}
+}
+
+// CleanupInterval returns the effective cleanup interval.
+func (c *Config) CleanupInterval() time.Duration {
+	if c.Cleanup.Interval.Duration > 0 {
+		return c.Cleanup.Interval.Duration
+	}
+	return 2 * c.Challenge.Expiration.Duration
+}
+
+// Validate checks that all values are usable.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port must be between 1 and 65535, got %d", c.Server.Port)
+	check(c.Server.ReadTimeout.Duration > 0, "server.read_timeout must be positive")
+	check(c.Server.ReadHeaderTimeout.Duration > 0, "server.read_header_timeout must be positive")
+	check(c.Server.WriteTimeout.Duration > 0, "server.write_timeout must be positive")
+	check(c.Server.IdleTimeout.Duration > 0, "server.idle_timeout must be positive")
+	check(c.Server.ShutdownTimeout.Duration > 0, "server.shutdown_timeout must be positive")
+	check(c.Server.MaxHeaderBytes > 0, "server.max_header_bytes must be positive")
+	check(c.Server.MaxBodyBytes > 0, "server.max_body_bytes must be positive")
+	if _, err := c.Server.TrustedProxyPrefixes(); err != nil {
+		errs = append(errs, fmt.Errorf("server.trusted_proxies must be IP addresses or CIDR ranges: %w", err))
+	}
+
+	check((c.TLS.CertFile == "") == (c.TLS.KeyFile == ""), "tls.cert_file and tls.key_file must be set together")
+	check(c.TLS.ReloadInterval.Duration > 0, "tls.reload_interval must be positive")
+
+	check(c.Challenge.Expiration.Duration > 0, "challenge.expiration must be positive")
+	// Even sized primitives are up to 10 pixels wide and must fit into the canvas
+	check(c.Challenge.CanvasSize > 10 && c.Challenge.CanvasSize <= 1024, "challenge.canvas_size must be between 11 and 1024, got %d", c.Challenge.CanvasSize)
+	// The upload is sent in the answer body together with the hashes
+	check(c.Challenge.UploadMaxBytes >= 0 && int64(c.Challenge.UploadMaxBytes) < c.Server.MaxBodyBytes,
+		"challenge.upload_max_bytes must be between 0 and server.max_body_bytes, got %d", c.Challenge.UploadMaxBytes)
+
+	check(c.Cleanup.Interval.Duration >= 0, "cleanup.interval must not be negative")
+	check(c.Cleanup.BatchSize > 0, "cleanup.batch_size must be positive")
+
+	check(c.Database.Path != "", "database.path must not be empty")
+
+	check(c.RateLimit.RequestsPerSecond > 0, "rate_limit.requests_per_second must be positive")
+	check(c.RateLimit.Burst > 0, "rate_limit.burst must be positive")
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level must be one of debug, info, warn or error, got %q", c.Log.Level)
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be one of none, stdout or otlp, got %q", c.Tracing.Exporter))
+	}
+	check(c.Tracing.Exporter != "otlp" || c.Tracing.Endpoint != "", "tracing.endpoint must be set for the otlp exporter")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+	check(c.Tracing.ServiceName != "", "tracing.service_name must not be empty")
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		check(origin == "*" || strings.HasPrefix(origin, "http://") || strings.HasPrefix(origin, "https://"),
+			"cors.allowed_origins must be \"*\" or start with http:// or https://, got %q", origin)
+	}
+
+	check(c.Verdict.Secret == "" || len(c.Verdict.Secret) >= 32, "verdict.secret must be at least 32 bytes")
+	check(c.Verdict.TTL.Duration > 0, "verdict.ttl must be positive")
+
+	check(c.Admin.Token == "" || len(c.Admin.Token) >= 32, "admin.token must be at least 32 bytes")
+	check(c.Admin.SimilarityWindow > 0, "admin.similarity_window must be positive")
+
+	check(c.Visitor.CookieMaxAge.Duration >= time.Second, "visitor.cookie_max_age must be at least 1s")
+	check(c.Visitor.History > 0, "visitor.history must be positive")
+
+	return errors.Join(errs...)
+}
+
+// Duration is a time.Duration that is encoded as a string such as "1m30s".
+type Duration struct {
+	time.Duration
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// String returns the configuration as indented JSON.
+// Secrets are redacted.
+func (c *Config) String() string {
+	redacted := *c
+	if redacted.Verdict.Secret != "" {
+		redacted.Verdict.Secret = "[redacted]"
+	}
+	if redacted.Admin.Token != "" {
+		redacted.Admin.Token = "[redacted]"
+	}
+	data, err := json.MarshalIndent(redacted, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
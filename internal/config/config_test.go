@@ -0,0 +1,159 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envFrom(values map[string]string) func(string) string {
+	return func(name string) string {
+		return values[name]
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "donatello.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load("test", nil, envFrom(nil))
+	if err != nil {
+		t.Fatalf("Load() returned an error: %v", err)
+	}
+	if cfg.Server.Port != 8080 {
+		t.Errorf("Expected default port 8080, got %d", cfg.Server.Port)
+	}
+	if cfg.Challenge.Expiration.Duration != time.Minute {
+		t.Errorf("Expected default expiration 1m, got %s", cfg.Challenge.Expiration)
+	}
+	if cfg.CleanupInterval() != 2*time.Minute {
+		t.Errorf("Expected cleanup interval to default to twice the expiration, got %s", cfg.CleanupInterval())
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfigFile(t, `{
+		"server": {"port": 9000},
+		"challenge": {"expiration": "30s", "canvas_size": 40},
+		"database": {"path": "file.db"}
+	}`)
+	env := envFrom(map[string]string{
+		FileEnv:                path,
+		"CHALLENGE_EXPIRATION": "45s",
+		"DB_PATH":              "env.db",
+	})
+
+	cfg, err := Load("test", []string{"-database.path", "flag.db"}, env)
+	if err != nil {
+		t.Fatalf("Load() returned an error: %v", err)
+	}
+	if cfg.Server.Port != 9000 {
+		t.Errorf("Expected port from file 9000, got %d", cfg.Server.Port)
+	}
+	if cfg.Challenge.CanvasSize != 40 {
+		t.Errorf("Expected canvas size from file 40, got %d", cfg.Challenge.CanvasSize)
+	}
+	if cfg.Challenge.Expiration.Duration != 45*time.Second {
+		t.Errorf("Expected expiration from env 45s, got %s", cfg.Challenge.Expiration)
+	}
+	if cfg.Database.Path != "flag.db" {
+		t.Errorf("Expected database path from flag, got %s", cfg.Database.Path)
+	}
+	if cfg.Server.ReadTimeout.Duration != 10*time.Second {
+		t.Errorf("Expected read timeout to keep its default, got %s", cfg.Server.ReadTimeout)
+	}
+}
+
+func TestLoad_BoolFlags(t *testing.T) {
+	cfg, err := Load("test", []string{"-log.debug", "-database.path", "flag.db", "-visitor.cookie=false"}, envFrom(map[string]string{
+		"VISITOR_COOKIE": "true",
+	}))
+	if err != nil {
+		t.Fatalf("Load() returned an error: %v", err)
+	}
+	if !cfg.Log.Debug || cfg.Database.Path != "flag.db" || cfg.Visitor.Cookie {
+		t.Errorf("Expected the bare switch and the following flags to be parsed, got debug %v, path %s, cookie %v",
+			cfg.Log.Debug, cfg.Database.Path, cfg.Visitor.Cookie)
+	}
+}
+
+func TestLoad_Extra(t *testing.T) {
+	var k int
+	cfg, err := Load("test", []string{"-k", "3", "-database.path", "flag.db"}, envFrom(nil), func(fs *flag.FlagSet) {
+		fs.IntVar(&k, "k", 8, "number of clusters")
+	})
+	if err != nil {
+		t.Fatalf("Load() returned an error: %v", err)
+	}
+	if k != 3 || cfg.Database.Path != "flag.db" {
+		t.Errorf("Expected the command flag and the setting to be parsed, got %d and %s", k, cfg.Database.Path)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{name: "canvas size not a number", env: map[string]string{"CANVAS_SIZE": "big"}},
+		{name: "canvas size too small", env: map[string]string{"CANVAS_SIZE": "8"}},
+		{name: "expiration not a duration", env: map[string]string{"CHALLENGE_EXPIRATION": "soon"}},
+		{name: "negative expiration", args: []string{"-challenge.expiration", "-1s"}},
+		{name: "port out of range", env: map[string]string{"PORT": "70000"}},
+		{name: "tls key without certificate", env: map[string]string{"TLS_KEY_FILE": "tls.key"}},
+		{name: "trusted proxy not an address", env: map[string]string{"TRUSTED_PROXIES": "10.0.0.0/8, proxy.local"}},
+		{name: "origin without scheme", env: map[string]string{"CORS_ALLOWED_ORIGINS": "https://a.example, b.example"}},
+		{name: "short verdict secret", env: map[string]string{"VERDICT_SECRET": "secret"}},
+		{name: "short admin token", env: map[string]string{"ADMIN_TOKEN": "token"}},
+		{name: "empty similarity window", args: []string{"-admin.similarity_window", "0"}},
+		{name: "empty visitor history", env: map[string]string{"VISITOR_HISTORY": "0"}},
+		{name: "upload larger than body", env: map[string]string{"CANVAS_UPLOAD_MAX_BYTES": "65536"}},
+		{name: "unknown flag", args: []string{"-nope", "1"}},
+		{name: "unknown file key", args: []string{"-config", writeConfigFile(t, `{"server": {"prot": 1}}`)}},
+		{name: "missing file", args: []string{"-config", filepath.Join(t.TempDir(), "missing.json")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load("test", tt.args, envFrom(tt.env)); err == nil {
+				t.Errorf("Expected Load() to fail")
+			}
+		})
+	}
+}
+
+func TestConfig_StringRedactsSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Verdict.Secret = strings.Repeat("x", 32)
+	cfg.Admin.Token = strings.Repeat("y", 32)
+	out := cfg.String()
+	if strings.Contains(out, cfg.Verdict.Secret) {
+		t.Errorf("Expected the verdict secret to be redacted, got %s", out)
+	}
+	if strings.Contains(out, cfg.Admin.Token) {
+		t.Errorf("Expected the admin token to be redacted, got %s", out)
+	}
+}
@@ -0,0 +1,249 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FileEnv is the environment variable holding the path of the configuration file.
+const FileEnv = "DONATELLO_CONFIG"
+
+// setting binds a configuration field to its environment variable and command line flag.
+// The flag name is the dotted JSON path of the field.
+type setting struct {
+	name  string
+	env   string
+	usage string
+	field func(c *Config) any
+}
+
+var settings = []setting{
+	{"server.port", "PORT", "HTTP port", func(c *Config) any { return &c.Server.Port }},
+	{"server.read_timeout", "READ_TIMEOUT", "maximum duration for reading the entire request", func(c *Config) any { return &c.Server.ReadTimeout }},
+	{"server.read_header_timeout", "READ_HEADER_TIMEOUT", "maximum duration for reading request headers", func(c *Config) any { return &c.Server.ReadHeaderTimeout }},
+	{"server.write_timeout", "WRITE_TIMEOUT", "maximum duration before timing out response writes", func(c *Config) any { return &c.Server.WriteTimeout }},
+	{"server.idle_timeout", "IDLE_TIMEOUT", "keep-alive idle timeout", func(c *Config) any { return &c.Server.IdleTimeout }},
+	{"server.shutdown_timeout", "SHUTDOWN_TIMEOUT", "time allowed for draining in-flight requests", func(c *Config) any { return &c.Server.ShutdownTimeout }},
+	{"server.max_header_bytes", "MAX_HEADER_BYTES", "maximum size of request headers", func(c *Config) any { return &c.Server.MaxHeaderBytes }},
+	{"server.max_body_bytes", "MAX_BODY_BYTES", "maximum size of request bodies", func(c *Config) any { return &c.Server.MaxBodyBytes }},
+	{"server.trusted_proxies", "TRUSTED_PROXIES", "comma-separated addresses or CIDR ranges of trusted reverse proxies", func(c *Config) any { return &c.Server.TrustedProxies }},
+	{"tls.cert_file", "TLS_CERT_FILE", "TLS certificate file", func(c *Config) any { return &c.TLS.CertFile }},
+	{"tls.key_file", "TLS_KEY_FILE", "TLS private key file", func(c *Config) any { return &c.TLS.KeyFile }},
+	{"tls.reload_interval", "TLS_RELOAD_INTERVAL", "how often the TLS key pair is checked for changes", func(c *Config) any { return &c.TLS.ReloadInterval }},
+	{"challenge.expiration", "CHALLENGE_EXPIRATION", "time a client has to answer a challenge", func(c *Config) any { return &c.Challenge.Expiration }},
+	{"challenge.canvas_size", "CANVAS_SIZE", "width and height of the challenge canvas", func(c *Config) any { return &c.Challenge.CanvasSize }},
+	{"challenge.upload_max_bytes", "CANVAS_UPLOAD_MAX_BYTES", "largest canvas upload of the SDK (0 disables uploads)", func(c *Config) any { return &c.Challenge.UploadMaxBytes }},
+	{"cleanup.interval", "CLEANUP_INTERVAL", "cleanup worker interval (0 means twice the challenge expiration)", func(c *Config) any { return &c.Cleanup.Interval }},
+	{"cleanup.batch_size", "CLEANUP_BATCH_SIZE", "number of expired challenges processed per batch", func(c *Config) any { return &c.Cleanup.BatchSize }},
+	{"database.path", "DB_PATH", "SQLite database file", func(c *Config) any { return &c.Database.Path }},
+	{"rate_limit.requests_per_second", "RATE_LIMIT_RPS", "sustained request rate", func(c *Config) any { return &c.RateLimit.RequestsPerSecond }},
+	{"rate_limit.burst", "RATE_LIMIT_BURST", "request burst size", func(c *Config) any { return &c.RateLimit.Burst }},
+	{"log.level", "LOG_LEVEL", "minimum log level (debug, info, warn, error)", func(c *Config) any { return &c.Log.Level }},
+	{"log.debug", "LOG_DEBUG", "debug mode, logs fingerprint hashes in clear text", func(c *Config) any { return &c.Log.Debug }},
+	{"tracing.exporter", "TRACING_EXPORTER", "trace exporter (none, stdout, otlp)", func(c *Config) any { return &c.Tracing.Exporter }},
+	{"tracing.endpoint", "TRACING_ENDPOINT", "OTLP/HTTP collector host:port", func(c *Config) any { return &c.Tracing.Endpoint }},
+	{"tracing.insecure", "TRACING_INSECURE", "send traces to the collector over plain HTTP", func(c *Config) any { return &c.Tracing.Insecure }},
+	{"tracing.sample_ratio", "TRACING_SAMPLE_RATIO", "fraction of traces to sample (0-1)", func(c *Config) any { return &c.Tracing.SampleRatio }},
+	{"tracing.service_name", "TRACING_SERVICE_NAME", "service name reported in traces", func(c *Config) any { return &c.Tracing.ServiceName }},
+	{"web.override_dir", "WEB_OVERRIDE_DIR", "serve web resources from this directory instead of the embedded copies", func(c *Config) any { return &c.Web.OverrideDir }},
+	{"cors.allowed_origins", "CORS_ALLOWED_ORIGINS", "comma-separated origins allowed to run challenges", func(c *Config) any { return &c.CORS.AllowedOrigins }},
+	{"verdict.secret", "VERDICT_SECRET", "secret signing verdict tokens (at least 32 bytes, random when empty)", func(c *Config) any { return &c.Verdict.Secret }},
+	{"verdict.ttl", "VERDICT_TTL", "validity of verdict tokens", func(c *Config) any { return &c.Verdict.TTL }},
+	{"admin.token", "ADMIN_TOKEN", "bearer token of the admin API (at least 32 bytes, disabled when empty)", func(c *Config) any { return &c.Admin.Token }},
+	{"visitor.cookie", "VISITOR_COOKIE", "link the challenges of a browser with the donatello_vid cookie", func(c *Config) any { return &c.Visitor.Cookie }},
+	{"visitor.cookie_max_age", "VISITOR_COOKIE_MAX_AGE", "lifetime of the visitor cookie", func(c *Config) any { return &c.Visitor.CookieMaxAge }},
+	{"visitor.history", "VISITOR_HISTORY", "number of previous sessions a fingerprint is compared with", func(c *Config) any { return &c.Visitor.History }},
+	{"admin.similarity_window", "SIMILARITY_WINDOW", "number of recent answers searched for similar fingerprints", func(c *Config) any { return &c.Admin.SimilarityWindow }},
+}
+
+// Load resolves the configuration from defaults, the configuration file, the environment and args.
+// getenv is usually os.Getenv. The returned configuration has been validated.
+// Commands register their own flags with extra before args are parsed.
+func Load(name string, args []string, getenv func(string) string, extra ...func(fs *flag.FlagSet)) (*Config, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configFile := fs.String("config", getenv(FileEnv), "JSON configuration file (env "+FileEnv+")")
+	for _, register := range extra {
+		register(fs)
+	}
+
+	// Flags are recorded first and applied last so they take precedence over the file and environment
+	defaults := Default()
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		fs.Var(&recordedValue{setting: s, values: flagValues, def: formatValue(s.field(defaults))}, s.name,
+			fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			Usage(os.Stderr)
+		}
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	cfg := Default()
+	if *configFile != "" {
+		if err := loadFile(cfg, *configFile); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range settings {
+		if value := getenv(s.env); value != "" {
+			if err := setValue(s.field(cfg), value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if value, ok := flagValues[s.name]; ok {
+			if err := setValue(s.field(cfg), value); err != nil {
+				return nil, fmt.Errorf("invalid -%s: %w", s.name, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// loadFile decodes the JSON file at path into cfg. Unknown keys are rejected.
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// setValue parses raw into the field pointed to by field.
+func setValue(field any, raw string) error {
+	switch v := field.(type) {
+	case *string:
+		*v = raw
+	case *int:
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		*v = parsed
+	case *int64:
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		*v = parsed
+	case *float64:
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		*v = parsed
+	case *bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		*v = parsed
+	case *[]string:
+		*v = nil
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*v = append(*v, item)
+			}
+		}
+	case *Duration:
+		return v.UnmarshalText([]byte(raw))
+	default:
+		return fmt.Errorf("unsupported setting type %T", field)
+	}
+	return nil
+}
+
+// formatValue returns the textual form of the field pointed to by field.
+func formatValue(field any) string {
+	switch v := field.(type) {
+	case *string:
+		return *v
+	case *int:
+		return strconv.Itoa(*v)
+	case *int64:
+		return strconv.FormatInt(*v, 10)
+	case *float64:
+		return strconv.FormatFloat(*v, 'g', -1, 64)
+	case *bool:
+		return strconv.FormatBool(*v)
+	case *[]string:
+		return strings.Join(*v, ",")
+	case *Duration:
+		return v.String()
+	default:
+		return ""
+	}
+}
+
+// recordedValue is a flag.Value that stores the raw flag value for later use.
+type recordedValue struct {
+	setting setting
+	values  map[string]string
+	def     string
+}
+
+func (v *recordedValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.def
+}
+
+func (v *recordedValue) Set(raw string) error {
+	// Parse into a scratch configuration so malformed flags are reported by the flag set
+	if err := setValue(v.setting.field(Default()), raw); err != nil {
+		return err
+	}
+	v.values[v.setting.name] = raw
+	return nil
+}
+
+// IsBoolFlag lets boolean settings be passed as bare switches such as -log.debug, see flag.Value.
+func (v *recordedValue) IsBoolFlag() bool {
+	_, ok := v.setting.field(Default()).(*bool)
+	return ok
+}
+
+// Usage writes the documentation of every setting to w.
+func Usage(w io.Writer) {
+	defaults := Default()
+	_, _ = fmt.Fprintf(w, "  -config string\n\tJSON configuration file (env %s)\n", FileEnv)
+	for _, s := range settings {
+		_, _ = fmt.Fprintf(w, "  -%s\n\t%s (env %s, default %q)\n", s.name, s.usage, s.env, formatValue(s.field(defaults)))
+	}
+}
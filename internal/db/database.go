@@ -2,22 +2,29 @@
 package db
 
 import (
+	"log/slog"
+	"time"
+
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
-	"os"
+	"gorm.io/gorm/logger"
 )
 
 // DB is the global database connection.
 var DB *gorm.DB
 
-// InitDB initializes the database connection.
-func InitDB() error {
+// InitDB initializes the database connection to the SQLite file at dbPath.
+func InitDB(dbPath string) error {
 	var err error
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = "donatello.db"
-	}
-	DB, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	DB, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{
+		// Queries are logged through slog without their parameters so fingerprint hashes never leak
+		Logger: logger.NewSlogLogger(slog.Default(), logger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			IgnoreRecordNotFoundError: true,
+			ParameterizedQueries:      true,
+			LogLevel:                  logger.Warn,
+		}),
+	})
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,198 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package features validates and stores the feature vectors clients compute from the second task.
+package features
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/Litebrowsers/donatello/internal/models"
+)
+
+// Version is the current schema version of models.FeatureVector.
+const Version = 1
+
+// Bins is the number of histogram bins.
+const Bins = 8
+
+// Channels are the channel keys in storage order.
+var Channels = []string{"r", "g", "b", "a"}
+
+// legacyChannel is the channel the legacy metrics2 array describes.
+const legacyChannel = "a"
+
+// legacyLength is the number of values in a legacy metrics2 array: mean, std, min, max, median, the bins and the
+// gradient mean and max.
+const legacyLength = 5 + Bins + 2
+
+// histogramTolerance is how far the bins may sum from 1 due to float rounding in the client.
+const histogramTolerance = 0.01
+
+// ErrInvalid is returned for feature vectors that do not match the schema.
+var ErrInvalid = errors.New("invalid feature vector")
+
+// Validate checks that v matches the current schema.
+func Validate(v models.FeatureVector) error {
+	if v.Version != Version {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalid, v.Version)
+	}
+	if len(v.Channels) == 0 {
+		return fmt.Errorf("%w: no channels", ErrInvalid)
+	}
+	for name, channel := range v.Channels {
+		if !slices.Contains(Channels, name) {
+			return fmt.Errorf("%w: unknown channel %q", ErrInvalid, name)
+		}
+		if err := validateChannel(channel); err != nil {
+			return fmt.Errorf("%w: channel %s: %v", ErrInvalid, name, err)
+		}
+	}
+	return nil
+}
+
+func validateChannel(c models.ChannelFeatures) error {
+	values := []float64{c.Mean, c.Std, c.Min, c.Max, c.Median, c.GradientMean, c.GradientMax}
+	if len(c.Histogram) != Bins {
+		return fmt.Errorf("expected %d histogram bins, got %d", Bins, len(c.Histogram))
+	}
+	values = append(values, c.Histogram...)
+	for _, value := range values {
+		if math.IsNaN(value) || value < 0 || value > 1 {
+			return fmt.Errorf("value %v outside [0, 1]", value)
+		}
+	}
+	if c.Min > c.Mean || c.Mean > c.Max || c.Min > c.Median || c.Median > c.Max {
+		return errors.New("mean and median must lie between min and max")
+	}
+	if c.GradientMean > c.GradientMax {
+		return errors.New("gradient mean exceeds gradient max")
+	}
+	var sum float64
+	for _, bin := range c.Histogram {
+		sum += bin
+	}
+	if math.Abs(sum-1) > histogramTolerance {
+		return fmt.Errorf("histogram sums to %v", sum)
+	}
+	return nil
+}
+
+// FromLegacy converts a legacy metrics2 JSON array, which describes the alpha channel only, to a feature vector.
+func FromLegacy(metrics string) (models.FeatureVector, error) {
+	var values []float64
+	if err := json.Unmarshal([]byte(metrics), &values); err != nil {
+		return models.FeatureVector{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if len(values) != legacyLength {
+		return models.FeatureVector{}, fmt.Errorf("%w: expected %d legacy values, got %d", ErrInvalid, legacyLength, len(values))
+	}
+	v := models.FeatureVector{
+		Version: Version,
+		Channels: map[string]models.ChannelFeatures{legacyChannel: {
+			Mean:         values[0],
+			Std:          values[1],
+			Min:          values[2],
+			Max:          values[3],
+			Median:       values[4],
+			Histogram:    values[5 : 5+Bins],
+			GradientMean: values[5+Bins],
+			GradientMax:  values[6+Bins],
+		}},
+	}
+	return v, Validate(v)
+}
+
+// Compute returns the features of an n×n channel, the same way the SDK computes them.
+func Compute(channel []byte, n int) models.ChannelFeatures {
+	size := n * n
+	var sum, sumSq float64
+	lo, hi := 255.0, 0.0
+	histogram := make([]float64, Bins)
+	for _, b := range channel[:size] {
+		v := float64(b)
+		sum += v
+		sumSq += v * v
+		lo, hi = min(lo, v), max(hi, v)
+		histogram[min(Bins-1, int(b)*Bins/256)]++
+	}
+	mean := sum / float64(size)
+	std := math.Sqrt(math.Max(0, sumSq/float64(size)-mean*mean))
+	sorted := slices.Clone(channel[:size])
+	slices.Sort(sorted)
+	for i := range histogram {
+		histogram[i] /= float64(size)
+	}
+
+	var gradientSum, gradientMax float64
+	at := func(x, y int) float64 { return float64(channel[y*n+x]) }
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			c := at(x, y)
+			right, below := c, c
+			if x+1 < n {
+				right = at(x+1, y)
+			}
+			if y+1 < n {
+				below = at(x, y+1)
+			}
+			gradient := math.Hypot(right-c, below-c)
+			gradientSum += gradient
+			gradientMax = math.Max(gradientMax, gradient)
+		}
+	}
+
+	return models.ChannelFeatures{
+		Mean:         mean / 255,
+		Std:          std / 255,
+		Min:          lo / 255,
+		Max:          hi / 255,
+		Median:       float64(sorted[size/2]) / 255,
+		Histogram:    histogram,
+		GradientMean: gradientSum / float64(size) / 255,
+		GradientMax:  gradientMax / 255,
+	}
+}
+
+// Rows returns the rows storing v for a challenge in Channels order.
+func Rows(challengeID string, v models.FeatureVector) []models.ChallengeFeature {
+	var rows []models.ChallengeFeature
+	for _, name := range Channels {
+		c, ok := v.Channels[name]
+		if !ok {
+			continue
+		}
+		row := models.ChallengeFeature{
+			ChallengeID:  challengeID,
+			Channel:      name,
+			Version:      v.Version,
+			Mean:         c.Mean,
+			Std:          c.Std,
+			Min:          c.Min,
+			Max:          c.Max,
+			Median:       c.Median,
+			GradientMean: c.GradientMean,
+			GradientMax:  c.GradientMax,
+		}
+		bins := []*float64{&row.Bin0, &row.Bin1, &row.Bin2, &row.Bin3, &row.Bin4, &row.Bin5, &row.Bin6, &row.Bin7}
+		for i, bin := range bins {
+			*bin = c.Histogram[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
@@ -0,0 +1,103 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package features
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/Litebrowsers/donatello/internal/models"
+)
+
+func TestCompute(t *testing.T) {
+	// A 2×2 channel with one bright pixel
+	features := Compute([]byte{0, 0, 0, 255}, 2)
+
+	if features.Mean != 0.25 || features.Min != 0 || features.Max != 1 || features.Median != 0 {
+		t.Errorf("Compute() failed. Unexpected statistics %+v", features)
+	}
+	if features.Histogram[0] != 0.75 || features.Histogram[Bins-1] != 0.25 {
+		t.Errorf("Compute() failed. Unexpected histogram %v", features.Histogram)
+	}
+	// The pixels left of and above the bright one see a gradient of 255
+	if features.GradientMax != 1 || math.Abs(features.GradientMean-0.5) > 1e-9 {
+		t.Errorf("Compute() failed. Unexpected gradient %v/%v", features.GradientMean, features.GradientMax)
+	}
+	if err := Validate(models.FeatureVector{Version: Version, Channels: map[string]models.ChannelFeatures{"r": features}}); err != nil {
+		t.Errorf("Validate() failed for computed features: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Compute([]byte{10, 20, 30, 40}, 2)
+	with := func(change func(*models.ChannelFeatures)) models.FeatureVector {
+		c := valid
+		c.Histogram = append([]float64(nil), valid.Histogram...)
+		change(&c)
+		return models.FeatureVector{Version: Version, Channels: map[string]models.ChannelFeatures{"a": c}}
+	}
+
+	tests := []struct {
+		name   string
+		vector models.FeatureVector
+	}{
+		{name: "version", vector: models.FeatureVector{Version: 2, Channels: map[string]models.ChannelFeatures{"a": valid}}},
+		{name: "no channels", vector: models.FeatureVector{Version: Version}},
+		{name: "unknown channel", vector: models.FeatureVector{Version: Version, Channels: map[string]models.ChannelFeatures{"x": valid}}},
+		{name: "bins", vector: with(func(c *models.ChannelFeatures) { c.Histogram = c.Histogram[:7] })},
+		{name: "out of range", vector: with(func(c *models.ChannelFeatures) { c.Std = 2 })},
+		{name: "not a number", vector: with(func(c *models.ChannelFeatures) { c.Mean = math.NaN() })},
+		{name: "mean above max", vector: with(func(c *models.ChannelFeatures) { c.Mean = c.Max + 0.1 })},
+		{name: "histogram sum", vector: with(func(c *models.ChannelFeatures) { c.Histogram[0] += 0.5 })},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.vector); !errors.Is(err, ErrInvalid) {
+				t.Errorf("Validate() failed. Expected %v, got %v", ErrInvalid, err)
+			}
+		})
+	}
+}
+
+func TestFromLegacy(t *testing.T) {
+	v, err := FromLegacy("[0.5,0.1,0.2,0.8,0.5,0,0,0.5,0.5,0,0,0,0,0.05,0.3]")
+	if err != nil {
+		t.Fatalf("FromLegacy() failed: %v", err)
+	}
+	a := v.Channels["a"]
+	if len(v.Channels) != 1 || a.Mean != 0.5 || a.Histogram[2] != 0.5 || a.GradientMax != 0.3 {
+		t.Errorf("FromLegacy() failed. Unexpected vector %+v", v)
+	}
+
+	for _, metrics := range []string{"[]", "not json", "[1,2,3]"} {
+		if _, err := FromLegacy(metrics); !errors.Is(err, ErrInvalid) {
+			t.Errorf("FromLegacy(%q) failed. Expected %v, got %v", metrics, ErrInvalid, err)
+		}
+	}
+}
+
+func TestRows(t *testing.T) {
+	v := models.FeatureVector{Version: Version, Channels: map[string]models.ChannelFeatures{
+		"a": Compute([]byte{0, 0, 0, 255}, 2),
+		"r": Compute([]byte{1, 2, 3, 4}, 2),
+	}}
+	rows := Rows("challenge-1", v)
+	if len(rows) != 2 || rows[0].Channel != "r" || rows[1].Channel != "a" {
+		t.Fatalf("Rows() failed. Expected rows for r and a, got %+v", rows)
+	}
+	if rows[1].ChallengeID != "challenge-1" || rows[1].Bin0 != 0.75 || rows[1].Bin7 != 0.25 || rows[1].Version != Version {
+		t.Errorf("Rows() failed. Unexpected row %+v", rows[1])
+	}
+}
@@ -0,0 +1,135 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package health provides liveness and readiness endpoints for orchestrators.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/clock"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Check verifies that a dependency is ready to serve traffic.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Checker runs readiness checks. It reports not ready until SetReady(true) is called, so readiness fails
+// closed during startup, and again once SetReady(false) is called on shutdown.
+type Checker struct {
+	timeout time.Duration
+	checks  []Check
+	ready   atomic.Bool
+}
+
+// NewChecker creates a new Checker. Every check must complete within timeout.
+func NewChecker(timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{timeout: timeout, checks: checks}
+}
+
+// SetReady marks the server as accepting (true) or not accepting (false) traffic.
+func (c *Checker) SetReady(ready bool) {
+	c.ready.Store(ready)
+}
+
+// Ready runs all checks and returns the result of each one keyed by name.
+// ok is false when the server is not accepting traffic or any check failed.
+func (c *Checker) Ready(ctx context.Context) (results map[string]string, ok bool) {
+	results = make(map[string]string, len(c.checks))
+	ok = c.ready.Load()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	for _, check := range c.checks {
+		if err := check.Run(ctx); err != nil {
+			results[check.Name] = err.Error()
+			ok = false
+			continue
+		}
+		results[check.Name] = "ok"
+	}
+	return results, ok
+}
+
+// Liveness returns a gin.HandlerFunc that reports the process as alive.
+func Liveness() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// Readiness returns a gin.HandlerFunc that responds with 200 when c is ready and 503 otherwise.
+func (c *Checker) Readiness() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		results, ok := c.Ready(ctx.Request.Context())
+		if !ok {
+			status := "unavailable"
+			if !c.ready.Load() {
+				status = "not accepting traffic"
+			}
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"status": status, "checks": results})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok", "checks": results})
+	}
+}
+
+// Ping checks that the database is reachable.
+func Ping(db *gorm.DB) Check {
+	return Check{Name: "database", Run: func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}}
+}
+
+// Migrated checks that the tables of all models exist.
+func Migrated(db *gorm.DB, models ...any) Check {
+	return Check{Name: "migrations", Run: func(ctx context.Context) error {
+		migrator := db.WithContext(ctx).Migrator()
+		for _, model := range models {
+			if !migrator.HasTable(model) {
+				return fmt.Errorf("table for %T is missing", model)
+			}
+		}
+		return nil
+	}}
+}
+
+// Heartbeat checks that last returns a time no older than maxAge on clk, which defaults to the system clock if nil.
+func Heartbeat(name string, clk clock.Clock, last func() time.Time, maxAge time.Duration) Check {
+	if clk == nil {
+		clk = clock.Real{}
+	}
+	return Check{Name: name, Run: func(context.Context) error {
+		beat := last()
+		if beat.IsZero() {
+			return errors.New("no heartbeat yet")
+		}
+		if age := clk.Now().Sub(beat); age > maxAge {
+			return fmt.Errorf("last heartbeat %s ago", age.Round(time.Second))
+		}
+		return nil
+	}}
+}
@@ -0,0 +1,108 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/clock"
+	"github.com/Litebrowsers/donatello/internal/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	return db
+}
+
+func TestChecker_Ready(t *testing.T) {
+	db := newTestDB(t)
+	var beat time.Time
+	fake := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	checker := NewChecker(time.Second,
+		Ping(db),
+		Migrated(db, &models.Challenge{}),
+		Heartbeat("cleanup_worker", fake, func() time.Time { return beat }, time.Minute),
+	)
+
+	checker.SetReady(true)
+	results, ok := checker.Ready(context.Background())
+	if ok {
+		t.Error("Expected checker not to be ready without migrations and heartbeat")
+	}
+	if results["database"] != "ok" || results["migrations"] == "ok" || results["cleanup_worker"] == "ok" {
+		t.Errorf("Unexpected results before migration: %v", results)
+	}
+
+	if err := db.AutoMigrate(&models.Challenge{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	beat = fake.Now()
+	if results, ok := checker.Ready(context.Background()); !ok {
+		t.Errorf("Expected checker to be ready, got %v", results)
+	}
+
+	// Exactly maxAge old is still fresh
+	fake.Advance(time.Minute)
+	if results, ok := checker.Ready(context.Background()); !ok {
+		t.Errorf("Expected a heartbeat of maxAge to be fresh, got %v", results)
+	}
+
+	fake.Advance(time.Second)
+	if results, ok := checker.Ready(context.Background()); ok || results["cleanup_worker"] == "ok" {
+		t.Errorf("Expected stale heartbeat to fail readiness, got %v", results)
+	}
+}
+
+func TestReadiness_FailsClosed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	checker := NewChecker(time.Second)
+	router := gin.New()
+	router.GET("/readyz", checker.Readiness())
+	router.GET("/healthz", Liveness())
+
+	status := func(path string) int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	if code := status("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("Readiness during startup failed. Expected %d, got %d", http.StatusServiceUnavailable, code)
+	}
+	checker.SetReady(true)
+	if code := status("/readyz"); code != http.StatusOK {
+		t.Errorf("Readiness while serving failed. Expected %d, got %d", http.StatusOK, code)
+	}
+	checker.SetReady(false)
+	if code := status("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("Readiness during shutdown failed. Expected %d, got %d", http.StatusServiceUnavailable, code)
+	}
+	if code := status("/healthz"); code != http.StatusOK {
+		t.Errorf("Liveness failed. Expected %d, got %d", http.StatusOK, code)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package logging configures structured JSON logging and carries request scoped loggers in contexts.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// ChallengeKey is the attribute key holding the challenge ID.
+const ChallengeKey = "challenge_id"
+
+// redacted is the value logged in place of fingerprint hashes outside of debug mode.
+const redacted = "[redacted]"
+
+// hashKeys lists the attribute keys whose values are fingerprint hashes.
+var hashKeys = map[string]bool{
+	"expected_hash":    true,
+	"actual_hash":      true,
+	"first_task_hash":  true,
+	"second_task_hash": true,
+	"diff_hash":        true,
+	"noise_hash":       true,
+	"fingerprint":      true,
+}
+
+// New returns a JSON logger writing to w. Fingerprint hashes are redacted unless debug is set.
+func New(w io.Writer, level slog.Level, debug bool) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if !debug {
+		opts.ReplaceAttr = func(_ []string, a slog.Attr) slog.Attr {
+			if hashKeys[a.Key] {
+				return slog.String(a.Key, redacted)
+			}
+			return a
+		}
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// ParseLevel parses a level name such as "debug", "info", "warn" or "error".
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(strings.TrimSpace(name)))
+	return level, err
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// WithChallenge attaches the challenge ID to the logger carried by ctx.
+func WithChallenge(ctx context.Context, challengeID string) (context.Context, *slog.Logger) {
+	logger := FromContext(ctx).With(ChallengeKey, challengeID)
+	return WithLogger(ctx, logger), logger
+}
@@ -0,0 +1,108 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		var line map[string]any
+		if err := decoder.Decode(&line); err != nil {
+			t.Fatalf("Failed to decode log line: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestNew_RedactsHashes(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, slog.LevelInfo, false).Info("answered", "fingerprint", "abc123", "status", "ok")
+	New(&buf, slog.LevelInfo, true).Info("answered", "fingerprint", "abc123")
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 log lines, got %d", len(lines))
+	}
+	if lines[0]["fingerprint"] != redacted {
+		t.Errorf("Expected fingerprint to be redacted, got %v", lines[0]["fingerprint"])
+	}
+	if lines[0]["status"] != "ok" {
+		t.Errorf("Expected other attributes to be kept, got %v", lines[0]["status"])
+	}
+	if lines[1]["fingerprint"] != "abc123" {
+		t.Errorf("Expected fingerprint in debug mode, got %v", lines[1]["fingerprint"])
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("warn")
+	if err != nil || level != slog.LevelWarn {
+		t.Errorf("ParseLevel(\"warn\") failed. Expected %v, got %v (%v)", slog.LevelWarn, level, err)
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("Expected ParseLevel(\"loud\") to fail")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	router := gin.New()
+	router.Use(Middleware(New(&buf, slog.LevelInfo, false)))
+	router.GET("/challenge", func(c *gin.Context) {
+		ctx, logger := WithChallenge(c.Request.Context(), "challenge-1")
+		c.Request = c.Request.WithContext(ctx)
+		logger.Info("challenge issued")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/challenge", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Header().Get(RequestIDHeader) != "req-42" {
+		t.Errorf("Expected request ID to be echoed, got %q", rec.Header().Get(RequestIDHeader))
+	}
+	lines := decodeLines(t, &buf)
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 log lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		if line["request_id"] != "req-42" || line[ChallengeKey] != "challenge-1" {
+			t.Errorf("Expected request and challenge IDs on every line, got %v", line)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/challenge", nil)
+	req.Header.Set(RequestIDHeader, "not a valid id\n")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if id := rec.Header().Get(RequestIDHeader); id == "" || id == "not a valid id\n" {
+		t.Errorf("Expected invalid request ID to be replaced, got %q", id)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/cleanup"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+// RegisterCleanup exposes the statistics of the cleanup worker.
+func (m *Metrics) RegisterCleanup(stats func() cleanup.Stats) {
+	m.registry.MustRegister(&cleanupCollector{stats: stats})
+}
+
+var (
+	cleanupLastRunDesc = prometheus.NewDesc(namespace+"_cleanup_last_run_timestamp_seconds",
+		"Start time of the last cleanup run.", nil, nil)
+	cleanupLastDurationDesc = prometheus.NewDesc(namespace+"_cleanup_last_duration_seconds",
+		"Duration of the last cleanup run.", nil, nil)
+	cleanupLastRowsDesc = prometheus.NewDesc(namespace+"_cleanup_last_rows",
+		"Number of challenges marked as no-js by the last cleanup run.", nil, nil)
+	cleanupRunsDesc = prometheus.NewDesc(namespace+"_cleanup_runs_total",
+		"Number of cleanup runs.", nil, nil)
+	cleanupErrorsDesc = prometheus.NewDesc(namespace+"_cleanup_errors_total",
+		"Number of failed cleanup runs.", nil, nil)
+)
+
+// cleanupCollector reads the cleanup worker statistics on every scrape.
+type cleanupCollector struct {
+	stats func() cleanup.Stats
+}
+
+func (c *cleanupCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cleanupLastRunDesc
+	ch <- cleanupLastDurationDesc
+	ch <- cleanupLastRowsDesc
+	ch <- cleanupRunsDesc
+	ch <- cleanupErrorsDesc
+}
+
+func (c *cleanupCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.stats()
+	var lastRun float64
+	if !stats.LastRun.IsZero() {
+		lastRun = float64(stats.LastRun.UnixNano()) / float64(time.Second)
+	}
+	ch <- prometheus.MustNewConstMetric(cleanupLastRunDesc, prometheus.GaugeValue, lastRun)
+	ch <- prometheus.MustNewConstMetric(cleanupLastDurationDesc, prometheus.GaugeValue, stats.LastDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(cleanupLastRowsDesc, prometheus.GaugeValue, float64(stats.LastRows))
+	ch <- prometheus.MustNewConstMetric(cleanupRunsDesc, prometheus.CounterValue, float64(stats.Runs))
+	ch <- prometheus.MustNewConstMetric(cleanupErrorsDesc, prometheus.CounterValue, float64(stats.Errors))
+}
+
+const dbStartKey = "metrics:start"
+
+// InstrumentDB records the latency of every database operation executed through db.
+func (m *Metrics) InstrumentDB(db *gorm.DB) error {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(dbStartKey, time.Now())
+	}
+	after := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			if start, ok := tx.InstanceGet(dbStartKey); ok {
+				m.DBQueryDuration.WithLabelValues(operation).Observe(time.Since(start.(time.Time)).Seconds())
+			}
+		}
+	}
+
+	callbacks := db.Callback()
+	hooks := []struct {
+		operation     string
+		before, after registerer
+	}{
+		{"create", callbacks.Create().Before("gorm:create"), callbacks.Create().After("gorm:create")},
+		{"query", callbacks.Query().Before("gorm:query"), callbacks.Query().After("gorm:query")},
+		{"update", callbacks.Update().Before("gorm:update"), callbacks.Update().After("gorm:update")},
+		{"delete", callbacks.Delete().Before("gorm:delete"), callbacks.Delete().After("gorm:delete")},
+		{"row", callbacks.Row().Before("gorm:row"), callbacks.Row().After("gorm:row")},
+		{"raw", callbacks.Raw().Before("gorm:raw"), callbacks.Raw().After("gorm:raw")},
+	}
+	for _, hook := range hooks {
+		if err := hook.before.Register("metrics:before_"+hook.operation, before); err != nil {
+			return err
+		}
+		if err := hook.after.Register("metrics:after_"+hook.operation, after(hook.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registerer is implemented by gorm callback chains.
+type registerer interface {
+	Register(name string, fn func(*gorm.DB)) error
+}
@@ -0,0 +1,154 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package metrics exposes application metrics in the Prometheus format.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "donatello"
+
+// Metrics holds all application collectors.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	ChallengesCreated  prometheus.Counter
+	ChallengesIssued   *prometheus.CounterVec
+	ChallengesAnswered *prometheus.CounterVec
+	ChallengesExpired  prometheus.Counter
+	NoiseDetected      *prometheus.CounterVec
+	ProcessingTime     *prometheus.HistogramVec
+	HashMismatches     *prometheus.CounterVec
+	TimingAnomalies    *prometheus.CounterVec
+	Inconsistencies    *prometheus.CounterVec
+	NoiseClasses       *prometheus.CounterVec
+	Stability          *prometheus.CounterVec
+	CanvasUploads      *prometheus.CounterVec
+	RateLimited        prometheus.Counter
+	DBQueryDuration    *prometheus.HistogramVec
+}
+
+// New creates the collectors and registers them on a new registry together with the Go and process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		ChallengesCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "challenges_created_total",
+			Help:      "Number of challenges created by GET /.",
+		}),
+		ChallengesIssued: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "challenges_issued_total",
+			Help:      "Number of challenges whose tasks were sent to a client.",
+		}, []string{"profile", "pool"}),
+		ChallengesAnswered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "challenges_answered_total",
+			Help:      "Number of challenges answered by a client.",
+		}, []string{"profile", "pool"}),
+		ChallengesExpired: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "challenges_expired_total",
+			Help:      "Number of challenges marked as no-js by the cleanup worker.",
+		}),
+		NoiseDetected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "challenges_noise_detected_total",
+			Help:      "Number of answered challenges with detected canvas noise.",
+		}, []string{"profile", "pool"}),
+		ProcessingTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "challenge_processing_seconds",
+			Help:      "Time between challenge creation and the client's answer.",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"profile", "pool"}),
+		HashMismatches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "hash_mismatches_total",
+			Help:      "Number of answers whose first task hash differs from the expected hash.",
+		}, []string{"profile"}),
+		TimingAnomalies: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "timing_anomalies_total",
+			Help:      "Number of answers whose client timings look automated, by reason.",
+		}, []string{"reason"}),
+		Inconsistencies: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "answer_inconsistencies_total",
+			Help:      "Number of answers whose reported diff contradicts the rest of the answer, by reason.",
+		}, []string{"reason"}),
+		NoiseClasses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "noise_classes_total",
+			Help:      "Number of answers with a reported diff, by noise class and whether the pattern repeated.",
+		}, []string{"class", "seeded"}),
+		Stability: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "fingerprint_stability_total",
+			Help:      "Number of answers of returning visitors, by whether the fingerprint was stable, changed or randomized.",
+		}, []string{"result"}),
+		CanvasUploads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "canvas_uploads_total",
+			Help:      "Number of canvas uploads, by whether the uploaded pixels hash to the reported hash or the upload was invalid.",
+		}, []string{"result"}),
+		RateLimited: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limit_rejections_total",
+			Help:      "Number of requests rejected by the rate limiter.",
+		}),
+		DBQueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Latency of database operations.",
+			Buckets:   []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 1},
+		}, []string{"operation"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.ChallengesCreated,
+		m.ChallengesIssued,
+		m.ChallengesAnswered,
+		m.ChallengesExpired,
+		m.NoiseDetected,
+		m.ProcessingTime,
+		m.HashMismatches,
+		m.TimingAnomalies,
+		m.Inconsistencies,
+		m.NoiseClasses,
+		m.Stability,
+		m.CanvasUploads,
+		m.RateLimited,
+		m.DBQueryDuration,
+	)
+	return m
+}
+
+// Registry returns the registry holding all collectors.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler returns the HTTP handler serving the metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
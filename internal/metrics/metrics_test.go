@@ -0,0 +1,93 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/cleanup"
+	"github.com/Litebrowsers/donatello/internal/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMetrics_InstrumentDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Task{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	m := New()
+	if err := m.InstrumentDB(db); err != nil {
+		t.Fatalf("InstrumentDB() returned an error: %v", err)
+	}
+
+	db.Create(&models.Task{Name: "secondTask", Value: "R:FF0000:1:1:0:0"})
+	var task models.Task
+	db.First(&task)
+
+	families, err := m.Registry().Gather()
+	if err != nil {
+		t.Fatalf("Gather() returned an error: %v", err)
+	}
+	observed := make(map[string]uint64)
+	for _, family := range families {
+		if family.GetName() != "donatello_db_query_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "operation" {
+					observed[label.GetValue()] = metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	for _, operation := range []string{"create", "query"} {
+		if observed[operation] == 0 {
+			t.Errorf("Expected %s operations to be observed, got %v", operation, observed)
+		}
+	}
+}
+
+func TestMetrics_RegisterCleanup(t *testing.T) {
+	m := New()
+	lastRun := time.Unix(1700000000, 0)
+	m.RegisterCleanup(func() cleanup.Stats {
+		return cleanup.Stats{LastRun: lastRun, LastDuration: 2 * time.Second, LastRows: 7, Runs: 3, Errors: 1}
+	})
+
+	expected := `
+# HELP donatello_cleanup_last_rows Number of challenges marked as no-js by the last cleanup run.
+# TYPE donatello_cleanup_last_rows gauge
+donatello_cleanup_last_rows 7
+# HELP donatello_cleanup_runs_total Number of cleanup runs.
+# TYPE donatello_cleanup_runs_total counter
+donatello_cleanup_runs_total 3
+`
+	err := testutil.GatherAndCompare(m.Registry(), strings.NewReader(expected),
+		"donatello_cleanup_last_rows", "donatello_cleanup_runs_total")
+	if err != nil {
+		t.Errorf("Unexpected cleanup metrics: %v", err)
+	}
+}
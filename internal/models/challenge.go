@@ -25,6 +25,8 @@ type Challenge struct {
 	gorm.Model
 	ID             string `gorm:"primaryKey"`
 	Task           string
+	Profile        string
+	SecondTaskID   uint
 	ActualHash     string
 	ExpectedHash   string
 	ExpiresAt      time.Time
@@ -35,4 +37,101 @@ type Challenge struct {
 	ProcessingTime int64
 	CopyMismatch   *bool
 	JavaScript     *bool `gorm:"default:null"`
+	RiskScore      float64
+	// TraceParent is the W3C trace context of the request that created the challenge
+	TraceParent string
+	// IssuedAt and AnsweredAt are the server-observed times of GET /challenge and POST /challenge
+	IssuedAt   *time.Time
+	AnsweredAt *time.Time
+	Timings    Timings `gorm:"embedded;embeddedPrefix:timing_"`
+	// TimingAnomalies lists the reasons the timings look automated, comma separated
+	TimingAnomalies string
+	// ExpectedChannelHashes are the red, green, blue and alpha hashes of the first task, comma separated
+	ExpectedChannelHashes string
+	// ExpectedTileHashes are the tile hashes of the first task in row-major order, comma separated
+	ExpectedTileHashes string
+	// MismatchScope is none, global, localized or unknown, see analysis.LocalizeMismatch
+	MismatchScope string
+	// MismatchChannels and MismatchTiles are the diverged channels and tile indices, comma separated
+	MismatchChannels string
+	MismatchTiles    string
+	Noise            NoiseProfile `gorm:"embedded;embeddedPrefix:noise_"`
+	// Diff is the valid sparse diff of the first task reported by the client, comma separated, see
+	// ChallengeAnswer.Diff. It is empty if the client did not report one or Noise.Class is invalid.
+	Diff string
+	// Inconsistencies lists the reasons the reported diff contradicts the other values of the answer, comma
+	// separated, see analysis.CheckConsistency
+	Inconsistencies string
+	// Features are the per-channel feature vectors of the second task
+	Features []ChallengeFeature `gorm:"foreignKey:ChallengeID"`
+	// VisitorID identifies the browser across sessions with the donatello_vid cookie, empty when disabled
+	VisitorID string    `gorm:"index"`
+	Stability Stability `gorm:"embedded;embeddedPrefix:stability_"`
+	// Upload is the canvas uploaded by the SDK, nil if it did not upload one
+	Upload *CanvasUpload `gorm:"foreignKey:ChallengeID"`
+}
+
+// Stability compares the hashes of an answered challenge with the previous sessions of the same visitor, see
+// analysis.CheckStability.
+type Stability struct {
+	// Sessions is the number of previous answered challenges of the visitor that were compared.
+	Sessions int
+	// FingerprintChanges counts the previous sessions with the same second task and a different Fingerprint.
+	FingerprintChanges int
+	// NoiseHashChanges counts the previous sessions with a different NoiseHash.
+	NoiseHashChanges int
+	// Fingerprints is the number of distinct fingerprints of the second task, including this challenge.
+	Fingerprints int
+	// Randomized is set when every session rendered the second task differently.
+	Randomized bool
+}
+
+// NoiseProfile characterizes the difference between the expected and the client's rendering of the first task, see
+// analysis.AnalyzeNoise.
+type NoiseProfile struct {
+	// Class is the kind of noise, e.g. farbling or random.
+	Class string
+	// Pixels is the number of pixels that differ.
+	Pixels int
+	// Channels are the channels with differences, comma separated.
+	Channels string
+	// MaxMagnitude and MeanMagnitude describe the largest per-channel difference of the differing pixels.
+	MaxMagnitude  int
+	MeanMagnitude float64
+	// Magnitudes counts the differing pixels by magnitude: 1, 2, 3-4, 5-16 and above 16, comma separated.
+	Magnitudes string
+	// Tiles is the number of tiles with differences.
+	Tiles int
+	// Pattern hashes the positions and directions of the differences. Seeded noise repeats it.
+	Pattern string
+	// Seeded is set when another challenge showed the same pattern.
+	Seeded bool
+}
+
+// ChannelHashes are the SHA256 hashes of the channels of a rendering.
+type ChannelHashes struct {
+	R string `json:"r"`
+	G string `json:"g"`
+	B string `json:"b"`
+	A string `json:"a"`
+}
+
+// Timings are the durations of the client-side steps of a challenge in milliseconds, as reported by the SDK.
+type Timings struct {
+	// Fetch is the round trip of GET /challenge.
+	Fetch float64 `json:"fetch"`
+	// Draw is the time spent drawing both tasks.
+	Draw float64 `json:"draw"`
+	// GetImageData is the time spent reading back the pixels.
+	GetImageData float64 `json:"getImageData"`
+	// Hash is the time spent hashing the pixels.
+	Hash float64 `json:"hash"`
+	// CopyTest is the time spent on the data URL copy test.
+	CopyTest float64 `json:"copyTest"`
+}
+
+// Expired reports whether the challenge can no longer be answered at now. A challenge is still valid exactly at
+// ExpiresAt.
+func (c *Challenge) Expired(now time.Time) bool {
+	return now.After(c.ExpiresAt)
 }
@@ -0,0 +1,60 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package models
+
+// FeatureVector describes the pixels of the second task per channel. It is sent by the client as features2.
+type FeatureVector struct {
+	// Version is the schema version, see features.Version.
+	Version int `json:"version"`
+	// Channels are keyed by r, g, b and a.
+	Channels map[string]ChannelFeatures `json:"channels"`
+}
+
+// ChannelFeatures are the statistics of one channel. Values are normalized to [0, 1]: intensities are divided by 255
+// and histogram bins are fractions of the pixels.
+type ChannelFeatures struct {
+	Mean   float64 `json:"mean"`
+	Std    float64 `json:"std"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Median float64 `json:"median"`
+	// Histogram splits the intensities into 8 equal bins.
+	Histogram []float64 `json:"histogram"`
+	// GradientMean and GradientMax describe the magnitude of the gradient to the right and lower neighbours.
+	GradientMean float64 `json:"gradientMean"`
+	GradientMax  float64 `json:"gradientMax"`
+}
+
+// ChallengeFeature is the stored feature vector of one channel of an answered challenge.
+type ChallengeFeature struct {
+	ID           uint   `gorm:"primaryKey"`
+	ChallengeID  string `gorm:"index;uniqueIndex:idx_challenge_feature_channel"`
+	Channel      string `gorm:"uniqueIndex:idx_challenge_feature_channel"`
+	Version      int
+	Mean         float64
+	Std          float64
+	Min          float64
+	Max          float64
+	Median       float64
+	Bin0         float64
+	Bin1         float64
+	Bin2         float64
+	Bin3         float64
+	Bin4         float64
+	Bin5         float64
+	Bin6         float64
+	Bin7         float64
+	GradientMean float64
+	GradientMax  float64
+}
@@ -15,10 +15,24 @@ package models
 
 // ChallengeAnswer represents the answer to a challenge that is sent from the client.
 type ChallengeAnswer struct {
-	ID                string  `json:"id" binding:"required"`
-	FirstTaskHash     string  `json:"totalHash1" binding:"required"`
-	DiffTaskHash      *string `json:"diffHash"`
-	SecondTaskHash    string  `json:"totalHash2" binding:"required"`
-	SecondTaskMetrics string  `json:"metrics2" binding:"required"`
-	CopyMismatch      *bool   `json:"copyMismatch"`
+	ID             string  `json:"id" binding:"required"`
+	FirstTaskHash  string  `json:"totalHash1" binding:"required"`
+	DiffTaskHash   *string `json:"diffHash"`
+	SecondTaskHash string  `json:"totalHash2" binding:"required"`
+	// SecondTaskMetrics is the legacy, untyped form of Features
+	SecondTaskMetrics string `json:"metrics2"`
+	CopyMismatch      *bool  `json:"copyMismatch"`
+	// Timings, ChannelHashes and TileHashes are optional so answers of older SDK versions are accepted
+	Timings       *Timings       `json:"timings"`
+	ChannelHashes *ChannelHashes `json:"channelHashes1"`
+	TileHashes    []string       `json:"tileHashes1"`
+	// Diff lists the pixels of the first task that differ from the prediction as flat [index, dr, dg, db, da, ...]
+	// with the signed differences actual - expected
+	Diff []int `json:"diff1"`
+	// DiffTruncated is set instead of Diff when more pixels differ than a diff may list
+	DiffTruncated bool `json:"diffTruncated"`
+	// Features describes the second task rendering. Either Features or SecondTaskMetrics is required
+	Features *FeatureVector `json:"features2"`
+	// Canvas is the optional rendering of the first task as a PNG data URL
+	Canvas string `json:"canvas1"`
 }
@@ -0,0 +1,31 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package models
+
+import "time"
+
+// CanvasUpload is the rendering of the first task that the SDK of a challenge uploaded for offline investigation.
+type CanvasUpload struct {
+	ID          uint   `gorm:"primaryKey"`
+	ChallengeID string `gorm:"uniqueIndex"`
+	CreatedAt   time.Time
+	// PNG is the image as uploaded.
+	PNG []byte
+	// Hash is the combined hash of the decoded pixels. HashMatches reports whether it equals the actual hash the
+	// client reported.
+	Hash        string
+	HashMatches bool
+	// DiffPixels is the number of pixels that differ from the server rendering.
+	DiffPixels int
+}
@@ -0,0 +1,67 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package risk scores answered challenges.
+package risk
+
+// Weights of the individual signals. A hash mismatch on its own is common for legitimate renderers with unusual
+// anti-aliasing, so it weighs less than noise, which points to deliberate canvas tampering.
+const (
+	weightHashMismatch  = 0.2
+	weightNoiseDetected = 0.6
+	weightCopyMismatch  = 0.2
+	weightTimingAnomaly = 0.3
+	weightRandomized    = 0.4
+	weightInconsistent  = 0.5
+)
+
+// Signals are the observations about an answered challenge that contribute to its risk score.
+type Signals struct {
+	// HashMismatch is set when the rendered canvas differs from the expected one.
+	HashMismatch bool
+	// NoiseDetected is set when the difference looks like injected noise.
+	NoiseDetected bool
+	// CopyMismatch is set when the canvas changed when copied through a data URL.
+	CopyMismatch bool
+	// TimingAnomaly is set when the client's timings are too fast, too uniform or inconsistent.
+	TimingAnomaly bool
+	// FingerprintRandomized is set when the visitor rendered the second task differently in every session.
+	FingerprintRandomized bool
+	// Inconsistent is set when the reported diff contradicts the rest of the answer, so the client made up values
+	// instead of rendering.
+	Inconsistent bool
+}
+
+// Score returns the risk score for s, between 0 (no risk) and 1 (high risk).
+func Score(s Signals) float64 {
+	var score float64
+	if s.HashMismatch {
+		score += weightHashMismatch
+	}
+	if s.NoiseDetected {
+		score += weightNoiseDetected
+	}
+	if s.CopyMismatch {
+		score += weightCopyMismatch
+	}
+	if s.TimingAnomaly {
+		score += weightTimingAnomaly
+	}
+	if s.FingerprintRandomized {
+		score += weightRandomized
+	}
+	if s.Inconsistent {
+		score += weightInconsistent
+	}
+	return min(score, 1)
+}
@@ -0,0 +1,42 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package risk
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name     string
+		signals  Signals
+		expected float64
+	}{
+		{"clean", Signals{}, 0},
+		{"mismatch only", Signals{HashMismatch: true}, 0.2},
+		{"noise", Signals{HashMismatch: true, NoiseDetected: true, CopyMismatch: true}, 1},
+		{"timing", Signals{TimingAnomaly: true}, 0.3},
+		{"randomized", Signals{HashMismatch: true, FingerprintRandomized: true}, 0.6},
+		{"inconsistent", Signals{HashMismatch: true, Inconsistent: true}, 0.7},
+		{"capped", Signals{HashMismatch: true, NoiseDetected: true, CopyMismatch: true, TimingAnomaly: true}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if score := Score(tt.signals); math.Abs(score-tt.expected) > 1e-9 {
+				t.Errorf("Score() failed. Expected %v, got %v", tt.expected, score)
+			}
+		})
+	}
+}
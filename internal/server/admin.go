@@ -0,0 +1,380 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/admin"
+	"github.com/Litebrowsers/donatello/internal/logging"
+	"github.com/Litebrowsers/donatello/internal/similarity"
+	"github.com/Litebrowsers/donatello/internal/tasks"
+	"github.com/gin-gonic/gin"
+)
+
+// maxSimilar is the largest number of similar challenges returned.
+const maxSimilar = 100
+
+// similarFingerprints handles GET /admin/fingerprints/:id/similar and sends the challenges that rendered the second task like the challenge in the path.
+// The k query parameter limits the number of matches and defaults to 10.
+func (s *Server) similarFingerprints(c *gin.Context) {
+	k := 10
+	if raw := c.Query("k"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxSimilar {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "k must be between 1 and " + strconv.Itoa(maxSimilar)})
+			return
+		}
+		k = parsed
+	}
+
+	ctx, reqLogger := logging.WithChallenge(c.Request.Context(), c.Param("id"))
+	similar, err := s.similarity.Similar(ctx, c.Param("id"), k)
+	if errors.Is(err, similarity.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found or not answered"})
+		return
+	}
+	if err != nil {
+		reqLogger.Error("failed to search similar fingerprints", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search similar fingerprints"})
+		return
+	}
+	c.JSON(http.StatusOK, similar)
+}
+
+// Limits of the admin API.
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+	// maxStatsRange is the longest time range of the hourly statistics.
+	maxStatsRange = 31 * 24 * time.Hour
+)
+
+// listChallenges handles GET /admin/api/challenges and sends a page of the challenges matching the query, newest
+// first. See parseFilter and parsePage for the query parameters.
+func (s *Server) listChallenges(c *gin.Context) {
+	filter, err := parseFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := parsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	challenges, total, err := s.admin.Challenges(c.Request.Context(), filter, page)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("failed to list challenges", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list challenges"})
+		return
+	}
+	summaries := make([]admin.Summary, 0, len(challenges))
+	for i := range challenges {
+		summaries = append(summaries, admin.NewSummary(&challenges[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"challenges": summaries,
+		"total":      total,
+		"limit":      page.Limit,
+		"offset":     page.Offset,
+	})
+}
+
+// challengeDetail handles GET /admin/api/challenges/:id and sends the challenge with its expected canvas.
+func (s *Server) challengeDetail(c *gin.Context) {
+	ctx, reqLogger := logging.WithChallenge(c.Request.Context(), c.Param("id"))
+	stored, err := s.admin.Challenge(ctx, c.Param("id"))
+	if errors.Is(err, admin.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
+		return
+	}
+	if err != nil {
+		reqLogger.Error("failed to load challenge", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load challenge"})
+		return
+	}
+	detail, err := admin.NewDetail(stored, s.cfg.Challenge.CanvasSize)
+	if err != nil {
+		reqLogger.Error("failed to render challenge", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render challenge"})
+		return
+	}
+	c.JSON(http.StatusOK, detail)
+}
+
+// hourlyStats handles GET /admin/api/stats/hourly and sends the challenge counts per hour between the from and to
+// query parameters, which default to the last 24 hours.
+func (s *Server) hourlyStats(c *gin.Context) {
+	to := s.clock.Now()
+	from := to.Add(-24 * time.Hour)
+	var err error
+	if raw := c.Query("from"); raw != "" {
+		if from, err = time.Parse(time.RFC3339, raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be an RFC 3339 time"})
+			return
+		}
+	}
+	if raw := c.Query("to"); raw != "" {
+		if to, err = time.Parse(time.RFC3339, raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be an RFC 3339 time"})
+			return
+		}
+	}
+	if !from.Before(to) || to.Sub(from) > maxStatsRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to and at most 31 days earlier"})
+		return
+	}
+
+	stats, err := s.admin.Hourly(c.Request.Context(), from.UTC(), to.UTC())
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("failed to aggregate challenges", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate challenges"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "hours": stats})
+}
+
+// parseFilter parses the query parameters from and to (RFC 3339), noise (true or false), javascript (true, false or
+// pending), fingerprint, min_risk and max_risk.
+func parseFilter(c *gin.Context) (admin.Filter, error) {
+	var filter admin.Filter
+	var err error
+	if raw := c.Query("from"); raw != "" {
+		if filter.From, err = time.Parse(time.RFC3339, raw); err != nil {
+			return filter, errors.New("from must be an RFC 3339 time")
+		}
+		filter.From = filter.From.UTC()
+	}
+	if raw := c.Query("to"); raw != "" {
+		if filter.To, err = time.Parse(time.RFC3339, raw); err != nil {
+			return filter, errors.New("to must be an RFC 3339 time")
+		}
+		filter.To = filter.To.UTC()
+	}
+	if raw := c.Query("noise"); raw != "" {
+		noise, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, errors.New("noise must be true or false")
+		}
+		filter.Noise = &noise
+	}
+	switch filter.JavaScript = c.Query("javascript"); filter.JavaScript {
+	case "", admin.JavaScriptConfirmed, admin.JavaScriptMissing, admin.JavaScriptPending:
+	default:
+		return filter, errors.New("javascript must be true, false or pending")
+	}
+	filter.Fingerprint = c.Query("fingerprint")
+	if filter.MinRisk, err = parseRisk(c, "min_risk"); err != nil {
+		return filter, err
+	}
+	if filter.MaxRisk, err = parseRisk(c, "max_risk"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// parseRisk parses the risk score query parameter name, nil if it is not set.
+func parseRisk(c *gin.Context, name string) (*float64, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	risk, err := strconv.ParseFloat(raw, 64)
+	if err != nil || risk < 0 || risk > 1 {
+		return nil, errors.New(name + " must be between 0 and 1")
+	}
+	return &risk, nil
+}
+
+// parsePage parses the query parameters limit (1 to 500, default 50) and offset.
+func parsePage(c *gin.Context) (admin.Page, error) {
+	page := admin.Page{Limit: defaultPageLimit}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return page, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageLimit))
+		}
+		page.Limit = limit
+	}
+	if raw := c.Query("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return page, errors.New("offset must not be negative")
+		}
+		page.Offset = offset
+	}
+	return page, nil
+}
+
+// Limits of the PNG rendering.
+const (
+	maxRenderSize = 512
+	// maxRenderPixels is the largest width of a rendered PNG.
+	maxRenderPixels = 4096
+)
+
+// renderTask handles GET /admin/api/render and sends the task query parameter drawn as PNG. See parseRender for the
+// size and scale query parameters.
+func (s *Server) renderTask(c *gin.Context) {
+	task := c.Query("task")
+	if task == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "task query parameter is required"})
+		return
+	}
+	size, scale, err := s.parseRender(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	canvas, err := tasks.Render(task, size, size)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task: " + err.Error()})
+		return
+	}
+	writePNG(c, canvas.Image(), scale)
+}
+
+// renderChallenge handles GET /admin/api/challenges/:id/render and sends the first task of the challenge, or the
+// second task with task=second, drawn as PNG. See parseRender for the size and scale query parameters.
+func (s *Server) renderChallenge(c *gin.Context) {
+	size, scale, err := s.parseRender(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	which := c.DefaultQuery("task", "first")
+	if which != "first" && which != "second" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "task must be first or second"})
+		return
+	}
+
+	ctx, reqLogger := logging.WithChallenge(c.Request.Context(), c.Param("id"))
+	stored, err := s.admin.Challenge(ctx, c.Param("id"))
+	if errors.Is(err, admin.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
+		return
+	}
+	if err != nil {
+		reqLogger.Error("failed to load challenge", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load challenge"})
+		return
+	}
+	// Tasks are assigned when the challenge is issued
+	if stored.Task == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not issued"})
+		return
+	}
+	task := stored.Task
+	if which == "second" {
+		if task, err = s.admin.Task(ctx, stored.SecondTaskID); err != nil {
+			reqLogger.Error("failed to load second task", "second_task_id", stored.SecondTaskID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load second task"})
+			return
+		}
+	}
+	canvas, err := tasks.Render(task, size, size)
+	if err != nil {
+		reqLogger.Error("failed to render task", "task", which, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render task"})
+		return
+	}
+	writePNG(c, canvas.Image(), scale)
+}
+
+// parseRender parses the query parameters size, the canvas width and height (1 to 512, default CANVAS_SIZE), and
+// scale, the factor every pixel is enlarged by (default 1). The scaled image is at most 4096 pixels wide.
+func (s *Server) parseRender(c *gin.Context) (size, scale int, err error) {
+	size, scale = s.cfg.Challenge.CanvasSize, 1
+	if raw := c.Query("size"); raw != "" {
+		if size, err = strconv.Atoi(raw); err != nil || size < 1 || size > maxRenderSize {
+			return 0, 0, errors.New("size must be between 1 and " + strconv.Itoa(maxRenderSize))
+		}
+	}
+	if raw := c.Query("scale"); raw != "" {
+		if scale, err = strconv.Atoi(raw); err != nil || scale < 1 || scale > tasks.MaxScale {
+			return 0, 0, errors.New("scale must be between 1 and " + strconv.Itoa(tasks.MaxScale))
+		}
+	}
+	if size*scale > maxRenderPixels {
+		return 0, 0, errors.New("size × scale must not exceed " + strconv.Itoa(maxRenderPixels))
+	}
+	return size, scale, nil
+}
+
+// writePNG responds with img enlarged by scale as PNG.
+func writePNG(c *gin.Context, img *image.NRGBA, scale int) {
+	var encoded bytes.Buffer
+	if err := tasks.EncodePNG(&encoded, img, scale); err != nil {
+		logging.FromContext(c.Request.Context()).Error("failed to encode PNG", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode PNG"})
+		return
+	}
+	c.Data(http.StatusOK, "image/png", encoded.Bytes())
+}
+
+// challengeUpload handles GET /admin/api/challenges/:id/upload and sends the canvas the client uploaded as it was
+// uploaded.
+func (s *Server) challengeUpload(c *gin.Context) {
+	ctx, reqLogger := logging.WithChallenge(c.Request.Context(), c.Param("id"))
+	stored, err := s.admin.Challenge(ctx, c.Param("id"))
+	if errors.Is(err, admin.ErrNotFound) || err == nil && stored.Upload == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Canvas upload not found"})
+		return
+	}
+	if err != nil {
+		reqLogger.Error("failed to load challenge", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load challenge"})
+		return
+	}
+	c.Data(http.StatusOK, "image/png", stored.Upload.PNG)
+}
+
+// uploadDiff handles GET /admin/api/challenges/:id/upload/diff and sends the differences of the uploaded canvas to
+// the server rendering as PNG, see tasks.Diff. The scale query parameter enlarges every pixel, see parseRender.
+func (s *Server) uploadDiff(c *gin.Context) {
+	_, scale, err := s.parseRender(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx, reqLogger := logging.WithChallenge(c.Request.Context(), c.Param("id"))
+	stored, err := s.admin.Challenge(ctx, c.Param("id"))
+	if errors.Is(err, admin.ErrNotFound) || err == nil && stored.Upload == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Canvas upload not found"})
+		return
+	}
+	if err != nil {
+		reqLogger.Error("failed to load challenge", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load challenge"})
+		return
+	}
+	detail, err := admin.NewDetail(stored, s.cfg.Challenge.CanvasSize)
+	if err != nil {
+		reqLogger.Error("failed to render challenge", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render challenge"})
+		return
+	}
+	if detail.Upload.Diff == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "The canvas size changed since the upload"})
+		return
+	}
+	writePNG(c, detail.Upload.Diff, scale)
+}
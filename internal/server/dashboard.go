@@ -0,0 +1,91 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/admin"
+	"github.com/Litebrowsers/donatello/internal/logging"
+	"github.com/Litebrowsers/donatello/internal/web"
+	"github.com/gin-gonic/gin"
+)
+
+// Limits of the dashboard.
+const (
+	defaultDashboardHours = 24
+	maxDashboardHours     = 7 * 24
+	// dashboardRecent is the number of recent challenges listed.
+	dashboardRecent = 20
+	// dashboardCanvasPixels is the width the canvases are enlarged to on the challenge page.
+	dashboardCanvasPixels = 256
+)
+
+// dashboard handles GET /admin/dashboard and renders the statistics of the last hours, 24 by default.
+func (s *Server) dashboard(c *gin.Context) {
+	hours := defaultDashboardHours
+	if raw := c.Query("hours"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxDashboardHours {
+			c.String(http.StatusBadRequest, "hours must be between 1 and "+strconv.Itoa(maxDashboardHours))
+			return
+		}
+		hours = parsed
+	}
+
+	to := s.clock.Now().UTC()
+	d, err := s.admin.Dashboard(c.Request.Context(), to.Add(-time.Duration(hours)*time.Hour), to, dashboardRecent)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("failed to collect dashboard", "error", err)
+		c.String(http.StatusInternalServerError, "Failed to collect dashboard")
+		return
+	}
+	if s.cleanup != nil {
+		stats := s.cleanup()
+		d.Cleanup = &stats
+	}
+	s.renderPage(c, web.DashboardName, d)
+}
+
+// dashboardChallenge handles GET /admin/dashboard/challenges/:id and renders the challenge with its expected and
+// submitted canvases.
+func (s *Server) dashboardChallenge(c *gin.Context) {
+	ctx, reqLogger := logging.WithChallenge(c.Request.Context(), c.Param("id"))
+	stored, err := s.admin.Challenge(ctx, c.Param("id"))
+	if errors.Is(err, admin.ErrNotFound) {
+		c.String(http.StatusNotFound, "Challenge not found")
+		return
+	}
+	if err != nil {
+		reqLogger.Error("failed to load challenge", "error", err)
+		c.String(http.StatusInternalServerError, "Failed to load challenge")
+		return
+	}
+	detail, err := admin.NewDetail(stored, s.cfg.Challenge.CanvasSize)
+	if err != nil {
+		reqLogger.Error("failed to render challenge", "error", err)
+		c.String(http.StatusInternalServerError, "Failed to render challenge")
+		return
+	}
+	view, err := admin.NewChallengeView(detail, max(1, dashboardCanvasPixels/s.cfg.Challenge.CanvasSize))
+	if err != nil {
+		reqLogger.Error("failed to encode canvases", "error", err)
+		c.String(http.StatusInternalServerError, "Failed to render challenge")
+		return
+	}
+	s.renderPage(c, web.DashboardChallengeName, view)
+}
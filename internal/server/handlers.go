@@ -0,0 +1,246 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Litebrowsers/donatello/internal/logging"
+	"github.com/Litebrowsers/donatello/internal/models"
+	"github.com/Litebrowsers/donatello/internal/web"
+	"github.com/Litebrowsers/donatello/pkg/challenge"
+	"github.com/Litebrowsers/donatello/pkg/guard"
+	"github.com/gin-gonic/gin"
+)
+
+// poolLabel returns the metrics label for a second task pool entry.
+func poolLabel(secondTaskID uint) string {
+	return strconv.FormatUint(uint64(secondTaskID), 10)
+}
+
+// uploadLabel returns the metrics label for a stored canvas upload.
+func uploadLabel(hashMatches bool) string {
+	if hashMatches {
+		return "hash_match"
+	}
+	return "hash_mismatch"
+}
+
+// issueChallenge handles GET /challenge and sends the tasks of a challenge.
+func (s *Server) issueChallenge(c *gin.Context) {
+	id := c.Query("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id query parameter is required"})
+		return
+	}
+
+	ctx, reqLogger := logging.WithChallenge(c.Request.Context(), id)
+	c.Request = c.Request.WithContext(ctx)
+
+	issued, err := s.service.Issue(ctx, id)
+	if errors.Is(err, challenge.ErrNotFound) {
+		reqLogger.Warn("challenge not found")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
+		return
+	}
+	if errors.Is(err, challenge.ErrExpired) {
+		reqLogger.Warn("challenge expired")
+		c.JSON(http.StatusGone, gin.H{"error": "Challenge expired"})
+		return
+	}
+	if errors.Is(err, challenge.ErrAlreadyAnswered) {
+		reqLogger.Warn("challenge already answered")
+		c.JSON(http.StatusConflict, gin.H{"error": "Challenge already answered"})
+		return
+	}
+	if err != nil {
+		reqLogger.Error("failed to issue challenge", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue challenge"})
+		return
+	}
+	issuedChallenge := issued.Challenge
+	reqLogger.Info("challenge issued", "profile", issuedChallenge.Profile, "second_task_id", issuedChallenge.SecondTaskID,
+		"expected_hash", issuedChallenge.ExpectedHash)
+
+	s.metrics.ChallengesIssued.WithLabelValues(issuedChallenge.Profile, poolLabel(issuedChallenge.SecondTaskID)).Inc()
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":               id,
+		"first_task":       issued.FirstTask,
+		"second_task":      issued.SecondTask,
+		"canvas_size":      issued.CanvasSize,
+		"tile_grid":        issued.TileGrid,
+		"upload_max_bytes": issued.UploadMaxBytes,
+	})
+}
+
+// answerChallenge handles POST /challenge and responds with the verdict.
+func (s *Server) answerChallenge(c *gin.Context) {
+	var answer models.ChallengeAnswer
+
+	if err := c.ShouldBindJSON(&answer); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid JSON: " + err.Error(),
+		})
+		return
+	}
+
+	ctx, reqLogger := logging.WithChallenge(c.Request.Context(), answer.ID)
+	c.Request = c.Request.WithContext(ctx)
+	reqLogger.Debug("challenge answer received",
+		"first_task_hash", answer.FirstTaskHash, "second_task_hash", answer.SecondTaskHash)
+
+	result, err := s.service.Answer(ctx, answer)
+	if errors.Is(err, challenge.ErrNotFound) {
+		reqLogger.Warn("challenge not found")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
+		return
+	}
+	if errors.Is(err, challenge.ErrExpired) {
+		reqLogger.Warn("challenge expired")
+		c.JSON(http.StatusGone, gin.H{"error": "Challenge expired"})
+		return
+	}
+	if errors.Is(err, challenge.ErrAlreadyAnswered) {
+		reqLogger.Warn("challenge already answered")
+		c.JSON(http.StatusConflict, gin.H{"error": "Challenge already answered"})
+		return
+	}
+	if errors.Is(err, challenge.ErrNotIssued) {
+		reqLogger.Warn("challenge not issued")
+		c.JSON(http.StatusConflict, gin.H{"error": "Challenge not issued"})
+		return
+	}
+	if errors.Is(err, challenge.ErrInvalidAnswer) {
+		reqLogger.Warn("invalid answer", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		reqLogger.Error("failed to answer challenge", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update challenge in cache"})
+		return
+	}
+	answered := result.Challenge
+	reqLogger.Info("challenge answered", "noise_detected", answered.NoiseDetected, "risk_score", answered.RiskScore,
+		"processing_time_ms", answered.ProcessingTime, "timing_anomalies", answered.TimingAnomalies,
+		"inconsistencies", answered.Inconsistencies,
+		"mismatch_scope", answered.MismatchScope, "mismatch_channels", answered.MismatchChannels,
+		"mismatch_tiles", answered.MismatchTiles, "noise_class", answered.Noise.Class, "noise_seeded", answered.Noise.Seeded,
+		"visitor_sessions", answered.Stability.Sessions, "fingerprint_changes", answered.Stability.FingerprintChanges,
+		"fingerprint_randomized", answered.Stability.Randomized,
+		"actual_hash", answered.ActualHash, "fingerprint", answered.Fingerprint)
+
+	pool := poolLabel(answered.SecondTaskID)
+	s.metrics.ChallengesAnswered.WithLabelValues(answered.Profile, pool).Inc()
+	s.metrics.ProcessingTime.WithLabelValues(answered.Profile, pool).Observe(result.ProcessingTime.Seconds())
+	if answered.NoiseDetected {
+		s.metrics.NoiseDetected.WithLabelValues(answered.Profile, pool).Inc()
+	}
+	if result.HashMismatch {
+		s.metrics.HashMismatches.WithLabelValues(answered.Profile).Inc()
+	}
+	if result.Noise.Class != "" {
+		s.metrics.NoiseClasses.WithLabelValues(result.Noise.Class, strconv.FormatBool(result.Noise.Seeded)).Inc()
+	}
+	for _, reason := range result.TimingAnomalies {
+		s.metrics.TimingAnomalies.WithLabelValues(reason).Inc()
+	}
+	for _, reason := range result.Inconsistencies {
+		s.metrics.Inconsistencies.WithLabelValues(reason).Inc()
+	}
+	if stability := answered.Stability; stability.Sessions > 0 {
+		s.metrics.Stability.WithLabelValues(stabilityLabel(stability)).Inc()
+	}
+	if upload := answered.Upload; upload != nil {
+		reqLogger.Info("canvas uploaded", "bytes", len(upload.PNG), "hash_matches", upload.HashMatches,
+			"diff_pixels", upload.DiffPixels)
+		s.metrics.CanvasUploads.WithLabelValues(uploadLabel(upload.HashMatches)).Inc()
+	}
+	if result.UploadError != nil {
+		reqLogger.Warn("canvas upload not stored", "error", result.UploadError)
+		s.metrics.CanvasUploads.WithLabelValues("invalid").Inc()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":         "ok",
+		"noise_detected": answered.NoiseDetected,
+		"risk_score":     answered.RiskScore,
+		"token":          result.Token,
+	})
+}
+
+// newChallenge handles POST /challenge/new.
+func (s *Server) newChallenge(c *gin.Context) {
+	if created := s.createChallenge(c); created != nil {
+		c.JSON(http.StatusOK, gin.H{"id": created.ID})
+	}
+}
+
+// index handles GET / and renders the demo page.
+func (s *Server) index(c *gin.Context) {
+	if created := s.createChallenge(c); created != nil {
+		s.renderPage(c, web.IndexName, web.IndexData{ChallengeID: created.ID})
+	}
+}
+
+// verify handles GET /verify and renders the verify page.
+func (s *Server) verify(c *gin.Context) {
+	returnTo := c.Query(guard.ReturnToParam)
+	if !allowedReturnTo(returnTo, s.cfg.CORS.AllowedOrigins) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "return_to must belong to an allowed origin"})
+		return
+	}
+	if created := s.createChallenge(c); created != nil {
+		s.renderPage(c, web.VerifyName, web.VerifyData{ChallengeID: created.ID, ReturnTo: returnTo})
+	}
+}
+
+// createChallenge stores a new challenge for the request in c. It responds with an error and returns nil on failure.
+func (s *Server) createChallenge(c *gin.Context) *challenge.Challenge {
+	if s.cfg.Visitor.Cookie {
+		c.Request = c.Request.WithContext(challenge.WithVisitor(c.Request.Context(), s.visitorID(c)))
+	}
+	created, err := s.service.Create(c.Request.Context())
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("failed to create challenge", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create challenge"})
+		return nil
+	}
+	ctx, reqLogger := logging.WithChallenge(c.Request.Context(), created.ID)
+	c.Request = c.Request.WithContext(ctx)
+	s.metrics.ChallengesCreated.Inc()
+	reqLogger.Info("challenge created", "expires_at", created.ExpiresAt)
+	return created
+}
+
+// renderPage responds with the page template name. Pages embed a challenge ID and must never be cached.
+func (s *Server) renderPage(c *gin.Context, name string, data any) {
+	var page bytes.Buffer
+	if err := s.assets.Render(&page, name, data); err != nil {
+		logging.FromContext(c.Request.Context()).Error("failed to render page", "page", name, "error", err)
+		c.String(http.StatusInternalServerError, "Failed to render "+name)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
@@ -0,0 +1,126 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitMiddleware returns a gin.HandlerFunc that limits requests and counts rejections in rejected.
+func RateLimitMiddleware(limit rate.Limit, burst int, rejected prometheus.Counter) gin.HandlerFunc {
+	limiter := rate.NewLimiter(limit, burst)
+	return func(c *gin.Context) {
+		if !limiter.Allow() {
+			rejected.Inc()
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminAuthMiddleware returns a gin.HandlerFunc that rejects requests without the bearer token. Browsers may send the
+// token as the password of HTTP basic authentication instead; the user name is ignored.
+func AdminAuthMiddleware(token string) gin.HandlerFunc {
+	expected := []byte("Bearer " + token)
+	return func(c *gin.Context) {
+		authorized := subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), expected) == 1
+		if _, password, ok := c.Request.BasicAuth(); ok && !authorized {
+			authorized = subtle.ConstantTimeCompare([]byte(password), []byte(token)) == 1
+		}
+		if !authorized {
+			c.Writer.Header().Add("WWW-Authenticate", `Bearer realm="donatello"`)
+			c.Writer.Header().Add("WWW-Authenticate", `Basic realm="donatello", charset="UTF-8"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// MaxBodySizeMiddleware returns a gin.HandlerFunc that limits the size of request bodies.
+func MaxBodySizeMiddleware(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// CORSMiddleware returns a gin.HandlerFunc that allows the listed origins to call the API from the browser.
+// "*" allows any origin, but only the listed origins may send credentials such as the visitor cookie. Preflight
+// requests are answered directly.
+func CORSMiddleware(allowedOrigins []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[origin] = true
+	}
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Origin")
+		if !allowed[origin] && !allowed["*"] {
+			if c.Request.Method == http.MethodOptions {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if allowed[origin] {
+			// The SDK sends the visitor cookie
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Allow-Credentials", "true")
+		} else {
+			c.Header("Access-Control-Allow-Origin", "*")
+		}
+		if c.Request.Method == http.MethodOptions {
+			c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			c.Header("Access-Control-Allow-Headers", "Content-Type")
+			c.Header("Access-Control-Max-Age", "600")
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// allowedReturnTo reports whether the verify page may redirect to rawURL, which must belong to one of the
+// allowed origins.
+func allowedReturnTo(rawURL string, allowedOrigins []string) bool {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return false
+	}
+	origin := target.Scheme + "://" + target.Host
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
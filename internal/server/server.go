@@ -0,0 +1,166 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package server implements the Donatello HTTP API and pages.
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+
+	"github.com/Litebrowsers/donatello/internal/admin"
+	"github.com/Litebrowsers/donatello/internal/cleanup"
+	"github.com/Litebrowsers/donatello/internal/clock"
+	"github.com/Litebrowsers/donatello/internal/config"
+	"github.com/Litebrowsers/donatello/internal/health"
+	"github.com/Litebrowsers/donatello/internal/logging"
+	"github.com/Litebrowsers/donatello/internal/metrics"
+	"github.com/Litebrowsers/donatello/internal/similarity"
+	"github.com/Litebrowsers/donatello/internal/tracing"
+	"github.com/Litebrowsers/donatello/internal/version"
+	"github.com/Litebrowsers/donatello/internal/web"
+	"github.com/Litebrowsers/donatello/pkg/challenge"
+	"github.com/Litebrowsers/donatello/pkg/verdict"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// Dependencies are the collaborators of a Server.
+type Dependencies struct {
+	Store challenge.Store
+	// Clock defaults to the system clock.
+	Clock clock.Clock
+	// Generator defaults to challenge.RandomGenerator.
+	Generator challenge.Generator
+	Verdicts  *verdict.Key
+	Assets    *web.Assets
+	Metrics   *metrics.Metrics
+	Checker   *health.Checker
+	// Similarity serves the admin similarity search.
+	Similarity *similarity.Searcher
+	// Admin serves the admin API and the dashboard.
+	Admin *admin.Store
+	// Cleanup reports the cleanup worker statistics on the dashboard if set.
+	Cleanup func() cleanup.Stats
+	// Logger defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+// Server serves the challenge API, the pages and the operational endpoints.
+type Server struct {
+	cfg        *config.Config
+	clock      clock.Clock
+	service    *challenge.Service
+	assets     *web.Assets
+	metrics    *metrics.Metrics
+	checker    *health.Checker
+	similarity *similarity.Searcher
+	admin      *admin.Store
+	cleanup    func() cleanup.Stats
+	logger     *slog.Logger
+	// trustedProxies may set X-Forwarded-Proto, see config.ServerConfig.TrustedProxies
+	trustedProxies []netip.Prefix
+}
+
+// New creates a new Server. cfg must be valid, see config.Config.Validate.
+func New(cfg *config.Config, deps Dependencies) *Server {
+	logger := deps.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	clk := deps.Clock
+	if clk == nil {
+		clk = clock.Real{}
+	}
+	// Validated with the configuration
+	trustedProxies, _ := cfg.Server.TrustedProxyPrefixes()
+	return &Server{
+		cfg:   cfg,
+		clock: clk,
+		service: challenge.NewService(deps.Store, deps.Verdicts, challenge.Config{
+			Expiration:     cfg.Challenge.Expiration.Duration,
+			CanvasSize:     cfg.Challenge.CanvasSize,
+			VerdictTTL:     cfg.Verdict.TTL.Duration,
+			Generator:      deps.Generator,
+			Clock:          clk,
+			VisitorHistory: cfg.Visitor.History,
+			UploadMaxBytes: cfg.Challenge.UploadMaxBytes,
+		}),
+		assets:     deps.Assets,
+		metrics:    deps.Metrics,
+		checker:    deps.Checker,
+		similarity: deps.Similarity,
+		admin:      deps.Admin,
+		cleanup:    deps.Cleanup,
+		logger:     logger,
+
+		trustedProxies: trustedProxies,
+	}
+}
+
+// Service returns the challenge service behind the handlers.
+func (s *Server) Service() *challenge.Service {
+	return s.service
+}
+
+// Handler returns the router serving all endpoints.
+func (s *Server) Handler() http.Handler {
+	router := gin.New()
+	// Only trusted proxies may report the client IP, the list was validated with the configuration
+	_ = router.SetTrustedProxies(s.cfg.Server.TrustedProxies)
+	router.Use(tracing.Middleware(), logging.Middleware(s.logger), logging.Recovery())
+
+	// Metrics and probes are registered before the rate limiter so they are never rejected
+	router.GET("/metrics", gin.WrapH(s.metrics.Handler()))
+	router.GET("/healthz", health.Liveness())
+	router.GET("/readyz", s.checker.Readiness())
+	router.GET("/version", func(c *gin.Context) {
+		c.JSON(http.StatusOK, version.Get())
+	})
+
+	// The admin API is authenticated by its token instead of being rate limited
+	if s.cfg.Admin.Token != "" {
+		adminGroup := router.Group("/admin", AdminAuthMiddleware(s.cfg.Admin.Token))
+		adminGroup.GET("/fingerprints/:id/similar", s.similarFingerprints)
+		adminGroup.GET("/api/challenges", s.listChallenges)
+		adminGroup.GET("/api/challenges/:id", s.challengeDetail)
+		adminGroup.GET("/api/challenges/:id/render", s.renderChallenge)
+		adminGroup.GET("/api/challenges/:id/upload", s.challengeUpload)
+		adminGroup.GET("/api/challenges/:id/upload/diff", s.uploadDiff)
+		adminGroup.GET("/api/stats/hourly", s.hourlyStats)
+		adminGroup.GET("/api/render", s.renderTask)
+		adminGroup.GET("/dashboard", s.dashboard)
+		adminGroup.GET("/dashboard/challenges/:id", s.dashboardChallenge)
+	}
+
+	// CORS runs before the rate limiter so rejected requests still carry CORS headers
+	router.Use(CORSMiddleware(s.cfg.CORS.AllowedOrigins))
+
+	// Apply Rate Limiter Middleware
+	router.Use(RateLimitMiddleware(rate.Limit(s.cfg.RateLimit.RequestsPerSecond), s.cfg.RateLimit.Burst, s.metrics.RateLimited))
+	router.Use(MaxBodySizeMiddleware(s.cfg.Server.MaxBodyBytes))
+
+	router.GET("/challenge", s.issueChallenge)
+	router.POST("/challenge", s.answerChallenge)
+	// Creates a challenge for the JS SDK running on another page
+	router.POST("/challenge/new", s.newChallenge)
+	router.GET("/", s.index)
+	// Runs a challenge and redirects back to a page protected by pkg/guard with the verdict token
+	router.GET("/verify", s.verify)
+
+	router.GET("/predictor.worker.js", s.assets.Handler("predictor.worker.js", "application/javascript"))
+	router.GET("/sdk/v1/donatello.js", s.assets.Handler("donatello.js", "application/javascript"))
+
+	return router
+}
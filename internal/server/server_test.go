@@ -0,0 +1,729 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/admin"
+	"github.com/Litebrowsers/donatello/internal/clock"
+	"github.com/Litebrowsers/donatello/internal/config"
+	"github.com/Litebrowsers/donatello/internal/features"
+	"github.com/Litebrowsers/donatello/internal/health"
+	"github.com/Litebrowsers/donatello/internal/metrics"
+	"github.com/Litebrowsers/donatello/internal/models"
+	"github.com/Litebrowsers/donatello/internal/similarity"
+	"github.com/Litebrowsers/donatello/internal/tasks"
+	"github.com/Litebrowsers/donatello/internal/web"
+	"github.com/Litebrowsers/donatello/pkg/challenge"
+	"github.com/Litebrowsers/donatello/pkg/verdict"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fixedGenerator always generates the same tasks so expected hashes can be computed by the test.
+type fixedGenerator struct{}
+
+func (fixedGenerator) FirstTask(int) []challenge.Shape {
+	return []challenge.Shape{
+		tasks.Rectangle{Color: "FF0000", W: 4, H: 4, X: 0, Y: 0},
+		tasks.Line{Color: "00FF00", X1: 1, Y1: 1, X2: 1, Y2: 8, Thickness: 2},
+	}
+}
+
+func (fixedGenerator) SecondTask(int) []challenge.Shape {
+	return []challenge.Shape{tasks.Circle{Color: "0000FF", R: 4, X: 10, Y: 10}}
+}
+
+func newTestServer(t *testing.T, clk clock.Clock, configure func(*config.Config)) (http.Handler, *verdict.Key) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := challenge.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	key, err := verdict.NewKey([]byte(strings.Repeat("k", verdict.MinSecretLength)))
+	if err != nil {
+		t.Fatalf("NewKey() failed: %v", err)
+	}
+	assets, err := web.New("")
+	if err != nil {
+		t.Fatalf("web.New() failed: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.Challenge.CanvasSize = 20
+	cfg.CORS.AllowedOrigins = []string{"https://shop.example.com"}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	s := New(cfg, Dependencies{
+		Store:      challenge.NewGormStore(db),
+		Clock:      clk,
+		Generator:  fixedGenerator{},
+		Verdicts:   key,
+		Assets:     assets,
+		Metrics:    metrics.New(),
+		Checker:    health.NewChecker(time.Second),
+		Similarity: similarity.NewSearcher(db, cfg.Admin.SimilarityWindow),
+		Admin:      admin.NewStore(db),
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	return s.Handler(), key
+}
+
+func do(handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func expectedHash(t *testing.T, shapes []tasks.Shape) string {
+	t.Helper()
+	canvas := tasks.NewCanvas(20, 20)
+	if err := canvas.DrawShapes(shapes); err != nil {
+		t.Fatalf("DrawShapes() failed: %v", err)
+	}
+	hashes, err := canvas.CalculateHashes()
+	if err != nil {
+		t.Fatalf("CalculateHashes() failed: %v", err)
+	}
+	combined, err := canvas.CalculateCombinedHash(hashes)
+	if err != nil {
+		t.Fatalf("CalculateCombinedHash() failed: %v", err)
+	}
+	return combined
+}
+
+var challengeIDPattern = regexp.MustCompile(`const challenge_id = "([^"]+)"`)
+
+func TestServer_Flow(t *testing.T) {
+	handler, key := newTestServer(t, nil, nil)
+
+	// GET / creates a challenge and embeds its ID in the page
+	rec := do(handler, http.MethodGet, "/", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("GET / failed. Expected 200 with no-store, got %d %q", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+	match := challengeIDPattern.FindStringSubmatch(rec.Body.String())
+	if match == nil {
+		t.Fatal("Expected the page to contain the challenge ID")
+	}
+	id := match[1]
+
+	// GET /challenge issues the tasks
+	rec = do(handler, http.MethodGet, "/challenge?id="+id, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /challenge failed. Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var issued struct {
+		ID         string `json:"id"`
+		FirstTask  string `json:"first_task"`
+		SecondTask string `json:"second_task"`
+		CanvasSize int    `json:"canvas_size"`
+		TileGrid   int    `json:"tile_grid"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &issued); err != nil {
+		t.Fatalf("Failed to decode the issued challenge: %v", err)
+	}
+	firstTask := fixedGenerator{}.FirstTask(20)
+	if issued.ID != id || issued.CanvasSize != 20 || issued.TileGrid != challenge.TileGrid || issued.FirstTask != tasks.NewTaskGenerator(firstTask...).GenerateTask() {
+		t.Fatalf("Unexpected issued challenge %+v", issued)
+	}
+
+	// An answer without a valid feature vector is rejected
+	invalid := `{"id":"` + id + `","totalHash1":"a","totalHash2":"b","metrics2":"[]"}`
+	if rec := do(handler, http.MethodPost, "/challenge", invalid); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid feature vector to be rejected, got %d", rec.Code)
+	}
+
+	// POST /challenge with the expected hash is a clean answer
+	channel := features.Compute(make([]byte, 4), 2)
+	answer, _ := json.Marshal(map[string]any{
+		"id":         id,
+		"totalHash1": expectedHash(t, firstTask),
+		"totalHash2": "fingerprint",
+		"features2": models.FeatureVector{Version: features.Version, Channels: map[string]models.ChannelFeatures{
+			"r": channel, "g": channel, "b": channel, "a": channel,
+		}},
+		"copyMismatch": false,
+	})
+	rec = do(handler, http.MethodPost, "/challenge", string(answer))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /challenge failed. Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result struct {
+		NoiseDetected bool    `json:"noise_detected"`
+		RiskScore     float64 `json:"risk_score"`
+		Token         string  `json:"token"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode the result: %v", err)
+	}
+	if result.NoiseDetected || result.RiskScore != 0 {
+		t.Errorf("Expected a clean result, got %+v", result)
+	}
+	v, err := key.Verify(result.Token, time.Now())
+	if err != nil || v.ChallengeID != id {
+		t.Errorf("Expected a valid verdict for %s, got %+v (%v)", id, v, err)
+	}
+
+	// The answer can't be replayed for another verdict
+	if rec := do(handler, http.MethodPost, "/challenge", string(answer)); rec.Code != http.StatusConflict {
+		t.Errorf("Expected a replayed answer to conflict, got %d", rec.Code)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	handler, _ := newTestServer(t, nil, func(cfg *config.Config) {
+		cfg.Server.MaxBodyBytes = 256
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		expected int
+	}{
+		{name: "missing id", method: http.MethodGet, target: "/challenge", expected: http.StatusBadRequest},
+		{name: "unknown challenge", method: http.MethodGet, target: "/challenge?id=missing", expected: http.StatusNotFound},
+		{name: "invalid JSON", method: http.MethodPost, target: "/challenge", body: "{", expected: http.StatusBadRequest},
+		{name: "missing fields", method: http.MethodPost, target: "/challenge", body: `{"id":"missing"}`, expected: http.StatusBadRequest},
+		{
+			name: "unknown answer", method: http.MethodPost, target: "/challenge",
+			body:     `{"id":"missing","totalHash1":"a","totalHash2":"b","metrics2":"[]"}`,
+			expected: http.StatusNotFound,
+		},
+		{
+			name: "body too large", method: http.MethodPost, target: "/challenge",
+			body: `{"id":"` + strings.Repeat("a", 512) + `"}`, expected: http.StatusRequestEntityTooLarge,
+		},
+		{name: "foreign return_to", method: http.MethodGet, target: "/verify?return_to=https://evil.example.com/", expected: http.StatusBadRequest},
+		{name: "allowed return_to", method: http.MethodGet, target: "/verify?return_to=https://shop.example.com/cart", expected: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := do(handler, tt.method, tt.target, tt.body); rec.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestServer_Expired(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	handler, _ := newTestServer(t, fake, nil)
+
+	rec := do(handler, http.MethodPost, "/challenge/new", "")
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || created.ID == "" {
+		t.Fatalf("Failed to create a challenge: %d %s", rec.Code, rec.Body.String())
+	}
+
+	fake.Advance(config.Default().Challenge.Expiration.Duration + time.Nanosecond)
+	if rec := do(handler, http.MethodGet, "/challenge?id="+created.ID, ""); rec.Code != http.StatusGone {
+		t.Errorf("Expected an expired challenge to be gone, got %d", rec.Code)
+	}
+	body := `{"id":"` + created.ID + `","totalHash1":"a","totalHash2":"b","metrics2":"[]"}`
+	if rec := do(handler, http.MethodPost, "/challenge", body); rec.Code != http.StatusGone {
+		t.Errorf("Expected an answer to an expired challenge to be gone, got %d", rec.Code)
+	}
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	handler, _ := newTestServer(t, nil, func(cfg *config.Config) {
+		cfg.RateLimit.RequestsPerSecond = 0.001
+		cfg.RateLimit.Burst = 1
+	})
+
+	if rec := do(handler, http.MethodPost, "/challenge/new", ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the first request to pass, got %d", rec.Code)
+	}
+	if rec := do(handler, http.MethodPost, "/challenge/new", ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the second request to be limited, got %d", rec.Code)
+	}
+	// Probes are never limited
+	if rec := do(handler, http.MethodGet, "/healthz", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected /healthz to bypass the rate limiter, got %d", rec.Code)
+	}
+}
+
+func TestServer_CORS(t *testing.T) {
+	handler, _ := newTestServer(t, nil, nil)
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/challenge", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := preflight("https://shop.example.com")
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://shop.example.com" {
+		t.Errorf("Expected the allowed origin to pass the preflight, got %d %q", rec.Code, rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Error("Expected the listed origin to be allowed to send credentials")
+	}
+	if rec := preflight("https://evil.example.com"); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected a foreign origin to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestServer_CORSWildcard(t *testing.T) {
+	handler, _ := newTestServer(t, nil, func(cfg *config.Config) {
+		cfg.CORS.AllowedOrigins = []string{"*", "https://shop.example.com"}
+	})
+
+	tests := []struct {
+		origin      string
+		allow       string
+		credentials string
+	}{
+		{origin: "https://shop.example.com", allow: "https://shop.example.com", credentials: "true"},
+		{origin: "https://evil.example.com", allow: "*", credentials: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/challenge", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusNoContent {
+				t.Fatalf("Preflight failed. Expected 204, got %d", rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.allow {
+				t.Errorf("Access-Control-Allow-Origin failed. Expected %q, got %q", tt.allow, got)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != tt.credentials {
+				t.Errorf("Access-Control-Allow-Credentials failed. Expected %q, got %q", tt.credentials, got)
+			}
+		})
+	}
+}
+
+// answerTestChallenge creates, issues and answers a challenge with the first task hash firstHash and returns its ID.
+func answerTestChallenge(t *testing.T, handler http.Handler, firstHash string) string {
+	t.Helper()
+	rec := do(handler, http.MethodPost, "/challenge/new", "")
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || created.ID == "" {
+		t.Fatalf("Failed to create a challenge: %d %s", rec.Code, rec.Body.String())
+	}
+	do(handler, http.MethodGet, "/challenge?id="+created.ID, "")
+	channel := features.Compute(make([]byte, 4), 2)
+	answer, _ := json.Marshal(map[string]any{
+		"id":         created.ID,
+		"totalHash1": firstHash,
+		"totalHash2": "fingerprint",
+		"features2":  models.FeatureVector{Version: features.Version, Channels: map[string]models.ChannelFeatures{"a": channel}},
+	})
+	if rec := do(handler, http.MethodPost, "/challenge", string(answer)); rec.Code != http.StatusOK {
+		t.Fatalf("POST /challenge failed: %d %s", rec.Code, rec.Body.String())
+	}
+	return created.ID
+}
+
+// doAdmin sends a GET request with the Authorization header to handler.
+func doAdmin(handler http.Handler, target, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_Admin(t *testing.T) {
+	token := strings.Repeat("t", 32)
+	handler, _ := newTestServer(t, nil, func(cfg *config.Config) {
+		cfg.Admin.Token = token
+	})
+
+	id := answerTestChallenge(t, handler, "a")
+	admin := func(target, authorization string) *httptest.ResponseRecorder {
+		return doAdmin(handler, target, authorization)
+	}
+	similar := "/admin/fingerprints/" + id + "/similar"
+	tests := []struct {
+		name          string
+		target        string
+		authorization string
+		expected      int
+	}{
+		{name: "no token", target: similar, expected: http.StatusUnauthorized},
+		{name: "wrong token", target: similar, authorization: "Bearer " + strings.Repeat("x", 32), expected: http.StatusUnauthorized},
+		{name: "unknown challenge", target: "/admin/fingerprints/missing/similar", authorization: "Bearer " + token, expected: http.StatusNotFound},
+		{name: "invalid k", target: similar + "?k=0", authorization: "Bearer " + token, expected: http.StatusBadRequest},
+		{name: "similar", target: similar + "?k=5", authorization: "Bearer " + token, expected: http.StatusOK},
+		{name: "basic", target: similar, authorization: basicAuth("admin", token), expected: http.StatusOK},
+		{name: "wrong basic", target: similar, authorization: basicAuth(token, "wrong"), expected: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := admin(tt.target, tt.authorization); rec.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	var result similarity.Similar
+	if err := json.Unmarshal(admin(similar, "Bearer "+token).Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode the similarity result: %v", err)
+	}
+	if result.ChallengeID != id || result.Fingerprint != "fingerprint" || len(result.Nearest) != 0 {
+		t.Errorf("Unexpected similarity result %+v", result)
+	}
+
+	// Without a token the admin API does not exist
+	handler, _ = newTestServer(t, nil, nil)
+	if rec := admin(similar, "Bearer "+token); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected the admin API to be disabled, got %d", rec.Code)
+	}
+}
+
+// basicAuth returns the Authorization header of HTTP basic authentication.
+func basicAuth(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+func TestServer_Visitor(t *testing.T) {
+	handler, _ := newTestServer(t, nil, func(cfg *config.Config) {
+		cfg.Visitor.Cookie = true
+	})
+
+	create := func(cookie string) *http.Cookie {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/challenge/new", nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: visitorCookie, Value: cookie})
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == visitorCookie {
+				return c
+			}
+		}
+		return nil
+	}
+
+	first := create("")
+	if first == nil || !validVisitorID(first.Value) || !first.HttpOnly || first.MaxAge <= 0 {
+		t.Fatalf("Expected a new visitor cookie, got %+v", first)
+	}
+	if again := create(first.Value); again == nil || again.Value != first.Value {
+		t.Errorf("Expected the visitor ID %s to be kept, got %+v", first.Value, again)
+	}
+	if replaced := create("forged"); replaced == nil || replaced.Value == "forged" || !validVisitorID(replaced.Value) {
+		t.Errorf("Expected an invalid visitor ID to be replaced, got %+v", replaced)
+	}
+
+	handler, _ = newTestServer(t, nil, nil)
+	if cookie := create(""); cookie != nil {
+		t.Errorf("Expected no visitor cookie when disabled, got %+v", cookie)
+	}
+}
+
+func TestServer_VisitorForwardedProto(t *testing.T) {
+	// httptest requests come from 192.0.2.1
+	tests := []struct {
+		name    string
+		proxies []string
+		secure  bool
+	}{
+		{name: "untrusted", proxies: nil, secure: false},
+		{name: "other proxy", proxies: []string{"10.0.0.1"}, secure: false},
+		{name: "trusted proxy", proxies: []string{"192.0.2.0/24"}, secure: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _ := newTestServer(t, nil, func(cfg *config.Config) {
+				cfg.Visitor.Cookie = true
+				cfg.Server.TrustedProxies = tt.proxies
+			})
+			req := httptest.NewRequest(http.MethodPost, "/challenge/new", nil)
+			req.Header.Set("X-Forwarded-Proto", "https")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			cookies := rec.Result().Cookies()
+			if len(cookies) != 1 {
+				t.Fatalf("Expected a visitor cookie, got %v", cookies)
+			}
+			if cookies[0].Secure != tt.secure || (cookies[0].SameSite == http.SameSiteNoneMode) != tt.secure {
+				t.Errorf("Expected Secure %v, got %+v", tt.secure, cookies[0])
+			}
+		})
+	}
+}
+
+func TestServer_AdminAPI(t *testing.T) {
+	token := strings.Repeat("t", 32)
+	fake := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	handler, _ := newTestServer(t, fake, func(cfg *config.Config) {
+		cfg.Admin.Token = token
+	})
+	bearer := "Bearer " + token
+
+	clean := answerTestChallenge(t, handler, expectedHash(t, fixedGenerator{}.FirstTask(20)))
+	fake.Advance(time.Hour)
+	mismatched := answerTestChallenge(t, handler, "mismatch")
+	do(handler, http.MethodPost, "/challenge/new", "")
+	fake.Advance(time.Minute)
+
+	var list struct {
+		Challenges []admin.Summary `json:"challenges"`
+		Total      int64           `json:"total"`
+		Limit      int             `json:"limit"`
+	}
+	rec := doAdmin(handler, "/admin/api/challenges?javascript=true&min_risk=0.1&limit=10", bearer)
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to decode the challenge list: %d %s", rec.Code, rec.Body.String())
+	}
+	if list.Total != 1 || list.Limit != 10 || len(list.Challenges) != 1 || list.Challenges[0].ID != mismatched {
+		t.Errorf("Expected only the mismatched challenge, got %+v", list)
+	}
+
+	var detail admin.Detail
+	rec = doAdmin(handler, "/admin/api/challenges/"+clean, bearer)
+	if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("Failed to decode the challenge detail: %d %s", rec.Code, rec.Body.String())
+	}
+	if detail.ID != clean || detail.ExpectedCanvas == nil || !detail.ExpectedCanvas.HashMatches || len(detail.ExpectedCanvas.RGBA) != 4*20*20 {
+		t.Errorf("Expected the detail with a matching expected canvas, got %+v", detail.ExpectedCanvas)
+	}
+
+	var stats struct {
+		Hours []admin.HourStat `json:"hours"`
+	}
+	rec = doAdmin(handler, "/admin/api/stats/hourly", bearer)
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to decode the statistics: %d %s", rec.Code, rec.Body.String())
+	}
+	if len(stats.Hours) != 2 || stats.Hours[0].Created != 1 || stats.Hours[1].Created != 2 || stats.Hours[1].Answered != 1 {
+		t.Errorf("Expected one and two challenges in the last two hours, got %+v", stats.Hours)
+	}
+
+	for _, target := range []string{
+		"/admin/api/challenges?from=yesterday",
+		"/admin/api/challenges?noise=maybe",
+		"/admin/api/challenges?javascript=unknown",
+		"/admin/api/challenges?max_risk=2",
+		"/admin/api/challenges?limit=1000",
+		"/admin/api/challenges?offset=-1",
+		"/admin/api/stats/hourly?from=2025-01-02T00:00:00Z&to=2025-01-01T00:00:00Z",
+	} {
+		if rec := doAdmin(handler, target, bearer); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected, got %d", target, rec.Code)
+		}
+	}
+	if rec := doAdmin(handler, "/admin/api/challenges/missing", bearer); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown challenge to be not found, got %d", rec.Code)
+	}
+	if rec := doAdmin(handler, "/admin/api/challenges", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the admin API to require the token, got %d", rec.Code)
+	}
+}
+
+func TestServer_Dashboard(t *testing.T) {
+	token := strings.Repeat("t", 32)
+	handler, _ := newTestServer(t, nil, func(cfg *config.Config) {
+		cfg.Admin.Token = token
+	})
+	basic := basicAuth("admin", token)
+	id := answerTestChallenge(t, handler, expectedHash(t, fixedGenerator{}.FirstTask(20)))
+
+	rec := doAdmin(handler, "/admin/dashboard", "")
+	if rec.Code != http.StatusUnauthorized || !slices.Contains(rec.Header().Values("WWW-Authenticate"), `Basic realm="donatello", charset="UTF-8"`) {
+		t.Errorf("Expected the dashboard to ask the browser for the token, got %d %v", rec.Code, rec.Header())
+	}
+
+	rec = doAdmin(handler, "/admin/dashboard?hours=48", basic)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/admin/dashboard/challenges/"+id) ||
+		!strings.Contains(rec.Body.String(), "<strong>1 / 1</strong>") {
+		t.Errorf("Expected the dashboard to link the answered challenge, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := doAdmin(handler, "/admin/dashboard?hours=0", basic); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid period to be rejected, got %d", rec.Code)
+	}
+
+	rec = doAdmin(handler, "/admin/dashboard/challenges/"+id, basic)
+	if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, `src="data:image/png;base64,`) ||
+		!strings.Contains(body, "matches the expected hash") || !strings.Contains(body, "did not report its rendering") {
+		t.Errorf("Expected the challenge page with the expected canvas, got %d %s", rec.Code, body)
+	}
+	if rec := doAdmin(handler, "/admin/dashboard/challenges/missing", basic); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown challenge to be not found, got %d", rec.Code)
+	}
+}
+
+func TestServer_RenderPNG(t *testing.T) {
+	token := strings.Repeat("t", 32)
+	handler, _ := newTestServer(t, nil, func(cfg *config.Config) {
+		cfg.Admin.Token = token
+	})
+	bearer := "Bearer " + token
+	id := answerTestChallenge(t, handler, "a")
+
+	decode := func(target string) image.Image {
+		t.Helper()
+		rec := doAdmin(handler, target, bearer)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+			t.Fatalf("Expected a PNG from %s, got %d %s", target, rec.Code, rec.Body.String())
+		}
+		img, err := png.Decode(rec.Body)
+		if err != nil {
+			t.Fatalf("Failed to decode PNG from %s: %v", target, err)
+		}
+		return img
+	}
+
+	img := decode("/admin/api/render?task=" + url.QueryEscape("R:FF0000:2:2:0:0") + "&size=4&scale=3")
+	if img.Bounds().Dx() != 12 {
+		t.Errorf("Expected a 12 pixel wide image, got %v", img.Bounds())
+	}
+	if r, _, _, a := img.At(5, 5).RGBA(); r>>8 != 0xFF || a>>8 != 0xFF {
+		t.Errorf("Expected a red pixel at (5, 5), got %v", img.At(5, 5))
+	}
+	if img := decode("/admin/api/challenges/" + id + "/render?scale=2"); img.Bounds().Dx() != 40 {
+		t.Errorf("Expected the first task on a 40 pixel wide image, got %v", img.Bounds())
+	}
+	if img := decode("/admin/api/challenges/" + id + "/render?task=second"); img.Bounds().Dx() != 20 {
+		t.Errorf("Expected the second task on a 20 pixel wide image, got %v", img.Bounds())
+	}
+
+	for target, expected := range map[string]int{
+		"/admin/api/render":                                         http.StatusBadRequest,
+		"/admin/api/render?task=X:1":                                http.StatusBadRequest,
+		"/admin/api/render?task=X:100000:FF0000:00FF00":             http.StatusBadRequest,
+		"/admin/api/render?task=R:FF0000:2:2:0:0&scale=0":           http.StatusBadRequest,
+		"/admin/api/render?task=R:FF0000:2:2:0:0&size=513":          http.StatusBadRequest,
+		"/admin/api/render?task=R:FF0000:2:2:0:0&size=100&scale=64": http.StatusBadRequest,
+		"/admin/api/challenges/" + id + "/render?task=third":        http.StatusBadRequest,
+		"/admin/api/challenges/missing/render":                      http.StatusNotFound,
+	} {
+		if rec := doAdmin(handler, target, bearer); rec.Code != expected {
+			t.Errorf("Expected status %d from %s, got %d", expected, target, rec.Code)
+		}
+	}
+}
+
+func TestServer_CanvasUpload(t *testing.T) {
+	token := strings.Repeat("t", 32)
+	handler, _ := newTestServer(t, nil, func(cfg *config.Config) {
+		cfg.Admin.Token = token
+		cfg.Challenge.UploadMaxBytes = 4096
+	})
+	bearer := "Bearer " + token
+	withoutUpload := answerTestChallenge(t, handler, "a")
+
+	rec := do(handler, http.MethodPost, "/challenge/new", "")
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to create a challenge: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = do(handler, http.MethodGet, "/challenge?id="+created.ID, "")
+	var issued struct {
+		FirstTask      string `json:"first_task"`
+		UploadMaxBytes int    `json:"upload_max_bytes"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &issued); err != nil || issued.UploadMaxBytes != 4096 {
+		t.Fatalf("Expected the issued challenge to allow uploads, got %s", rec.Body.String())
+	}
+
+	canvas, err := tasks.Render(issued.FirstTask, 20, 20)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	var encoded bytes.Buffer
+	if err := canvas.EncodePNG(&encoded, 1); err != nil {
+		t.Fatalf("EncodePNG() failed: %v", err)
+	}
+	channel := features.Compute(make([]byte, 4), 2)
+	answer, _ := json.Marshal(map[string]any{
+		"id":         created.ID,
+		"totalHash1": expectedHash(t, fixedGenerator{}.FirstTask(20)),
+		"totalHash2": "fingerprint",
+		"features2":  models.FeatureVector{Version: features.Version, Channels: map[string]models.ChannelFeatures{"a": channel}},
+		"canvas1":    "data:image/png;base64," + base64.StdEncoding.EncodeToString(encoded.Bytes()),
+	})
+	if rec := do(handler, http.MethodPost, "/challenge", string(answer)); rec.Code != http.StatusOK {
+		t.Fatalf("POST /challenge failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doAdmin(handler, "/admin/api/challenges/"+created.ID+"/upload", bearer)
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), encoded.Bytes()) {
+		t.Errorf("Expected the uploaded PNG, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	rec = doAdmin(handler, "/admin/api/challenges/"+created.ID+"/upload/diff?scale=2", bearer)
+	if img, err := png.Decode(rec.Body); err != nil || img.Bounds().Dx() != 40 {
+		t.Errorf("Expected a 40 pixel wide difference image, got %d (%v)", rec.Code, err)
+	}
+	rec = doAdmin(handler, "/admin/api/challenges/"+created.ID, bearer)
+	if !strings.Contains(rec.Body.String(), `"upload":{"bytes":`) || !strings.Contains(rec.Body.String(), `"hash_matches":true,"diff_pixels":0`) {
+		t.Errorf("Expected the challenge detail to describe the upload, got %s", rec.Body.String())
+	}
+
+	for _, target := range []string{
+		"/admin/api/challenges/" + withoutUpload + "/upload",
+		"/admin/api/challenges/" + withoutUpload + "/upload/diff",
+		"/admin/api/challenges/missing/upload",
+	} {
+		if rec := doAdmin(handler, target, bearer); rec.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d from %s, got %d", http.StatusNotFound, target, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/netip"
+
+	"github.com/Litebrowsers/donatello/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// visitorCookie is the name of the cookie holding the visitor ID.
+const visitorCookie = "donatello_vid"
+
+// visitorIDLength is the length of a hex encoded visitor ID.
+const visitorIDLength = 32
+
+// visitorID returns the visitor ID of the request in c, assigning a new one if the request has none, and refreshes
+// the cookie. Over HTTPS the cookie is SameSite=None so the SDK can send it from other sites. Behind a TLS
+// terminating proxy, HTTPS is taken from X-Forwarded-Proto if the proxy is trusted.
+func (s *Server) visitorID(c *gin.Context) string {
+	id, err := c.Cookie(visitorCookie)
+	if err != nil || !validVisitorID(id) {
+		random := make([]byte, visitorIDLength/2)
+		_, _ = rand.Read(random)
+		id = hex.EncodeToString(random)
+	}
+
+	// A forged header over plain HTTP would make the browser drop the Secure cookie
+	secure := c.Request.TLS != nil || s.fromTrustedProxy(c) && c.GetHeader("X-Forwarded-Proto") == "https"
+	sameSite := http.SameSiteLaxMode
+	if secure {
+		sameSite = http.SameSiteNoneMode
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     visitorCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(s.cfg.Visitor.CookieMaxAge.Seconds()),
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	})
+	return id
+}
+
+// fromTrustedProxy reports whether the request in c was sent by one of the trusted proxies.
+func (s *Server) fromTrustedProxy(c *gin.Context) bool {
+	addr, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range s.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// validVisitorID reports whether id looks like an ID assigned by visitorID.
+func validVisitorID(id string) bool {
+	if len(id) != visitorIDLength {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// stabilityLabel returns the metrics label for the stability of a returning visitor's fingerprint.
+func stabilityLabel(s models.Stability) string {
+	switch {
+	case s.Randomized:
+		return "randomized"
+	case s.FingerprintChanges > 0:
+		return "changed"
+	default:
+		return "stable"
+	}
+}
@@ -0,0 +1,154 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package similarity
+
+import (
+	"cmp"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+// Cluster is a group of points that rendered alike, a renderer class.
+type Cluster struct {
+	// Centroid is the mean of the normalized members.
+	Centroid []float64
+	Members  []Point
+}
+
+// Fingerprints counts the fingerprints of the members.
+func (c Cluster) Fingerprints() map[string]int {
+	counts := make(map[string]int)
+	for _, p := range c.Members {
+		counts[p.Fingerprint]++
+	}
+	return counts
+}
+
+// Cluster groups the complete points of the index into at most k clusters with k-means, seeded with k-means++.
+// It stops after iterations rounds or when no point changes its cluster. Clusters are ordered by size, largest
+// first. Points missing channels, which older SDK versions did not send, are skipped.
+func (ix *Index) Cluster(k, iterations int, rng *rand.Rand) []Cluster {
+	var points []Point
+	var vectors [][]float64
+	for i, p := range ix.points {
+		if p.Complete() {
+			points = append(points, p)
+			vectors = append(vectors, ix.normalized[i])
+		}
+	}
+	if len(points) == 0 || k <= 0 {
+		return nil
+	}
+	k = min(k, len(points))
+
+	centroids := seed(vectors, k, rng)
+	assignment := make([]int, len(points))
+	for i := range assignment {
+		assignment[i] = -1
+	}
+	for range iterations {
+		changed := false
+		for i, v := range vectors {
+			if c := nearest(centroids, v); c != assignment[i] {
+				assignment[i] = c
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+		centroids = means(vectors, assignment, centroids)
+	}
+
+	clusters := make([]Cluster, len(centroids))
+	for i, c := range centroids {
+		clusters[i].Centroid = c
+	}
+	for i, c := range assignment {
+		clusters[c].Members = append(clusters[c].Members, points[i])
+	}
+	clusters = slices.DeleteFunc(clusters, func(c Cluster) bool { return len(c.Members) == 0 })
+	slices.SortStableFunc(clusters, func(a, b Cluster) int {
+		return cmp.Compare(len(b.Members), len(a.Members))
+	})
+	return clusters
+}
+
+// seed picks k initial centroids from vectors: the first at random, each further one with a probability proportional
+// to its squared distance to the closest centroid picked so far.
+func seed(vectors [][]float64, k int, rng *rand.Rand) [][]float64 {
+	centroids := [][]float64{slices.Clone(vectors[rng.IntN(len(vectors))])}
+	weights := make([]float64, len(vectors))
+	for len(centroids) < k {
+		var total float64
+		for i, v := range vectors {
+			d := distance(v, centroids[nearest(centroids, v)])
+			weights[i] = d * d
+			total += weights[i]
+		}
+		if total == 0 {
+			// All remaining points coincide with a centroid
+			break
+		}
+		r := rng.Float64() * total
+		next := len(vectors) - 1
+		for i, w := range weights {
+			if r < w {
+				next = i
+				break
+			}
+			r -= w
+		}
+		centroids = append(centroids, slices.Clone(vectors[next]))
+	}
+	return centroids
+}
+
+// nearest returns the index of the centroid closest to v.
+func nearest(centroids [][]float64, v []float64) int {
+	best, bestDistance := 0, math.Inf(1)
+	for i, c := range centroids {
+		if d := distance(v, c); d < bestDistance {
+			best, bestDistance = i, d
+		}
+	}
+	return best
+}
+
+// means returns the mean of the vectors assigned to each centroid. Centroids without members are kept.
+func means(vectors [][]float64, assignment []int, previous [][]float64) [][]float64 {
+	sums := make([][]float64, len(previous))
+	counts := make([]int, len(previous))
+	for i := range sums {
+		sums[i] = make([]float64, Dims)
+	}
+	for i, v := range vectors {
+		c := assignment[i]
+		counts[c]++
+		for d, x := range v {
+			sums[c][d] += x
+		}
+	}
+	for c := range sums {
+		if counts[c] == 0 {
+			sums[c] = previous[c]
+			continue
+		}
+		for d := range sums[c] {
+			sums[c][d] /= float64(counts[c])
+		}
+	}
+	return sums
+}
@@ -0,0 +1,53 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package similarity
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"testing"
+)
+
+func TestIndex_Cluster(t *testing.T) {
+	var points []Point
+	for i := range 10 {
+		points = append(points, testPoint("low"+strconv.Itoa(i), 0.2, 0.1+float64(i)/100))
+		points = append(points, testPoint("high"+strconv.Itoa(i), 0.8, 0.8+float64(i)/100))
+	}
+	// Legacy points miss channels and are skipped
+	points = append(points, Point{ChallengeID: "legacy", Vector: Vector(nil)})
+
+	clusters := NewIndex(points).Cluster(2, 20, rand.New(rand.NewPCG(1, 2)))
+	if len(clusters) != 2 {
+		t.Fatalf("Cluster() failed. Expected 2 clusters, got %d", len(clusters))
+	}
+	for _, c := range clusters {
+		if len(c.Members) != 10 {
+			t.Errorf("Cluster() failed. Expected 10 members, got %d", len(c.Members))
+		}
+		prefix := c.Members[0].ChallengeID[:3]
+		for _, p := range c.Members {
+			if p.ChallengeID[:3] != prefix {
+				t.Errorf("Cluster() failed. %s was grouped with %s", p.ChallengeID, c.Members[0].ChallengeID)
+			}
+		}
+		if len(c.Fingerprints()) != 10 {
+			t.Errorf("Fingerprints() failed. Expected 10 fingerprints, got %v", c.Fingerprints())
+		}
+	}
+
+	if clusters := NewIndex(points[:3]).Cluster(5, 20, rand.New(rand.NewPCG(1, 2))); len(clusters) > 3 {
+		t.Errorf("Cluster() failed. Expected at most one cluster per point, got %d", len(clusters))
+	}
+}
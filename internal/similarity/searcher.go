@@ -0,0 +1,149 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package similarity
+
+import (
+	"context"
+	"slices"
+
+	"github.com/Litebrowsers/donatello/internal/models"
+	"gorm.io/gorm"
+)
+
+// Similar is the result of a similarity search for one challenge.
+type Similar struct {
+	ChallengeID string `json:"id"`
+	Fingerprint string `json:"fingerprint"`
+	// Exact lists answered challenges with the same second task hash, most recent first.
+	Exact []string `json:"exact"`
+	// Nearest lists the challenges with the closest feature vectors.
+	Nearest []Match `json:"nearest"`
+}
+
+// Searcher searches the feature vectors stored in the database. The Challenge and ChallengeFeature tables must be
+// migrated.
+type Searcher struct {
+	db *gorm.DB
+	// window is the number of most recently answered challenges searched
+	window int
+}
+
+// NewSearcher creates a new Searcher over the window most recently answered challenges.
+func NewSearcher(db *gorm.DB, window int) *Searcher {
+	return &Searcher{db: db, window: window}
+}
+
+// featureRow is a feature row joined with the fingerprint of its challenge.
+type featureRow struct {
+	models.ChallengeFeature
+	Fingerprint string
+}
+
+// Points returns the points of the window most recently answered challenges with feature vectors, most recent first.
+func (s *Searcher) Points(ctx context.Context) ([]Point, error) {
+	db := s.db.WithContext(ctx)
+	recent := db.Model(&models.Challenge{}).
+		Select("id").
+		Where("answered_at IS NOT NULL AND id IN (?)", db.Model(&models.ChallengeFeature{}).Select("challenge_id")).
+		Order("answered_at DESC").
+		Limit(s.window)
+	var rows []featureRow
+	err := db.Table("challenge_features").
+		Select("challenge_features.*, challenges.fingerprint").
+		Joins("JOIN challenges ON challenges.id = challenge_features.challenge_id").
+		Where("challenge_features.challenge_id IN (?)", recent).
+		Order("challenges.answered_at DESC, challenge_features.challenge_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return points(rows), nil
+}
+
+// point returns the point of the challenge id or ErrNotFound.
+func (s *Searcher) point(ctx context.Context, id string) (Point, error) {
+	var rows []featureRow
+	err := s.db.WithContext(ctx).Table("challenge_features").
+		Select("challenge_features.*, challenges.fingerprint").
+		Joins("JOIN challenges ON challenges.id = challenge_features.challenge_id").
+		Where("challenge_features.challenge_id = ?", id).
+		Scan(&rows).Error
+	if err != nil {
+		return Point{}, err
+	}
+	if len(rows) == 0 {
+		return Point{}, ErrNotFound
+	}
+	return points(rows)[0], nil
+}
+
+// points groups rows, which are ordered by challenge, into points.
+func points(rows []featureRow) []Point {
+	var result []Point
+	for start := 0; start < len(rows); {
+		end := start
+		for end < len(rows) && rows[end].ChallengeID == rows[start].ChallengeID {
+			end++
+		}
+		group := make([]models.ChallengeFeature, 0, end-start)
+		for _, row := range rows[start:end] {
+			group = append(group, row.ChallengeFeature)
+		}
+		result = append(result, Point{
+			ChallengeID: rows[start].ChallengeID,
+			Fingerprint: rows[start].Fingerprint,
+			Vector:      Vector(group),
+		})
+		start = end
+	}
+	return result
+}
+
+// Similar returns up to k challenges with the same fingerprint and the k nearest challenges in the window to the
+// challenge id. It returns ErrNotFound if the challenge has no feature vector.
+func (s *Searcher) Similar(ctx context.Context, id string, k int) (*Similar, error) {
+	target, err := s.point(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	window, err := s.Points(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The challenge is compared with the window even if it was answered before it
+	if !slices.ContainsFunc(window, func(p Point) bool { return p.ChallengeID == id }) {
+		window = append(window, target)
+	}
+	nearest, err := NewIndex(window).Nearest(id, k)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Similar{ChallengeID: id, Fingerprint: target.Fingerprint, Exact: []string{}, Nearest: nearest}
+	if result.Nearest == nil {
+		result.Nearest = []Match{}
+	}
+	if target.Fingerprint == "" {
+		return result, nil
+	}
+	err = s.db.WithContext(ctx).Model(&models.Challenge{}).
+		Where("fingerprint = ? AND id <> ? AND answered_at IS NOT NULL", target.Fingerprint, id).
+		Order("answered_at DESC").
+		Limit(k).
+		Pluck("id", &result.Exact).Error
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,84 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package similarity
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestSearcher_Similar(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Challenge{}, &models.ChallengeFeature{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	answer := func(id, fingerprint string, mean float64, answered time.Time) {
+		challenge := models.Challenge{ID: id, Fingerprint: fingerprint, ExpiresAt: answered, AnsweredAt: &answered,
+			Features: []models.ChallengeFeature{
+				{Channel: "r", Mean: mean, Bin0: 1},
+				{Channel: "a", Mean: 1, Bin7: 1},
+			}}
+		if err := db.Create(&challenge).Error; err != nil {
+			t.Fatalf("Failed to create challenge: %v", err)
+		}
+	}
+	answer("old", "fp", 0.1, start)
+	answer("a", "fp", 0.2, start.Add(time.Minute))
+	answer("b", "other", 0.9, start.Add(2*time.Minute))
+	answer("c", "other", 0.3, start.Add(3*time.Minute))
+	// Issued but not answered challenges carry the expected fingerprint and are no matches
+	if err := db.Create(&models.Challenge{ID: "issued", Fingerprint: "fp", ExpiresAt: start}).Error; err != nil {
+		t.Fatalf("Failed to create challenge: %v", err)
+	}
+
+	// The window holds a, b and c; old is searched although it was answered before
+	searcher := NewSearcher(db, 3)
+	ctx := context.Background()
+	points, err := searcher.Points(ctx)
+	if err != nil {
+		t.Fatalf("Points() failed: %v", err)
+	}
+	if len(points) != 3 || points[0].ChallengeID != "c" || points[2].ChallengeID != "a" {
+		t.Fatalf("Points() failed. Expected c, b, a, got %+v", points)
+	}
+
+	similar, err := searcher.Similar(ctx, "old", 2)
+	if err != nil {
+		t.Fatalf("Similar() failed: %v", err)
+	}
+	if similar.Fingerprint != "fp" || len(similar.Exact) != 1 || similar.Exact[0] != "a" {
+		t.Errorf("Similar() failed. Expected the exact match a, got %+v", similar)
+	}
+	if len(similar.Nearest) != 2 || similar.Nearest[0].ChallengeID != "a" || similar.Nearest[1].ChallengeID != "c" {
+		t.Errorf("Similar() failed. Expected the nearest a and c, got %+v", similar.Nearest)
+	}
+
+	if _, err := searcher.Similar(ctx, "issued", 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Similar() failed. Expected %v, got %v", ErrNotFound, err)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package similarity finds challenges whose second task rendered alike. Feature vectors are normalized per dimension
+// to zero mean and unit variance, so histogram bins and gradients weigh the same as intensities.
+package similarity
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"slices"
+
+	"github.com/Litebrowsers/donatello/internal/features"
+	"github.com/Litebrowsers/donatello/internal/models"
+)
+
+// ErrNotFound is returned when a challenge has no stored feature vector.
+var ErrNotFound = errors.New("similarity: no feature vector")
+
+// channelDims is the number of features per channel.
+const channelDims = 5 + features.Bins + 2
+
+// Dims is the length of a vector: the features of the r, g, b and a channels in that order.
+var Dims = len(features.Channels) * channelDims
+
+// Point is the feature vector of an answered challenge. Features of channels the client did not send are NaN.
+type Point struct {
+	ChallengeID string
+	Fingerprint string
+	Vector      []float64
+}
+
+// Complete reports whether the point has the features of all channels.
+func (p Point) Complete() bool {
+	return !slices.ContainsFunc(p.Vector, math.IsNaN)
+}
+
+// Vector returns the vector of the feature rows of one challenge.
+func Vector(rows []models.ChallengeFeature) []float64 {
+	vector := make([]float64, Dims)
+	for i := range vector {
+		vector[i] = math.NaN()
+	}
+	for _, row := range rows {
+		channel := slices.Index(features.Channels, row.Channel)
+		if channel < 0 {
+			continue
+		}
+		copy(vector[channel*channelDims:], []float64{
+			row.Mean, row.Std, row.Min, row.Max, row.Median,
+			row.Bin0, row.Bin1, row.Bin2, row.Bin3, row.Bin4, row.Bin5, row.Bin6, row.Bin7,
+			row.GradientMean, row.GradientMax,
+		})
+	}
+	return vector
+}
+
+// Match is a challenge found by a similarity search.
+type Match struct {
+	ChallengeID string  `json:"id"`
+	Fingerprint string  `json:"fingerprint"`
+	Distance    float64 `json:"distance"`
+}
+
+// Index holds normalized points.
+type Index struct {
+	points []Point
+	// normalized holds the z-scores of the points, in the same order
+	normalized [][]float64
+	mean       []float64
+	std        []float64
+}
+
+// NewIndex normalizes points and returns an index over them.
+func NewIndex(points []Point) *Index {
+	ix := &Index{points: points, mean: make([]float64, Dims), std: make([]float64, Dims)}
+	for d := range Dims {
+		var sum, sumSq, n float64
+		for _, p := range points {
+			if v := p.Vector[d]; !math.IsNaN(v) {
+				sum += v
+				sumSq += v * v
+				n++
+			}
+		}
+		if n > 0 {
+			ix.mean[d] = sum / n
+			ix.std[d] = math.Sqrt(math.Max(0, sumSq/n-ix.mean[d]*ix.mean[d]))
+		}
+	}
+	ix.normalized = make([][]float64, len(points))
+	for i, p := range points {
+		ix.normalized[i] = ix.normalize(p.Vector)
+	}
+	return ix
+}
+
+// Len returns the number of points in the index.
+func (ix *Index) Len() int {
+	return len(ix.points)
+}
+
+// normalize returns the z-scores of vector. Dimensions without variance are 0.
+func (ix *Index) normalize(vector []float64) []float64 {
+	z := make([]float64, Dims)
+	for d, v := range vector {
+		switch {
+		case math.IsNaN(v):
+			z[d] = v
+		case ix.std[d] > 0:
+			z[d] = (v - ix.mean[d]) / ix.std[d]
+		}
+	}
+	return z
+}
+
+// Nearest returns the k points closest to the challenge id, closest first. It returns ErrNotFound if id is not in
+// the index.
+func (ix *Index) Nearest(id string, k int) ([]Match, error) {
+	target := slices.IndexFunc(ix.points, func(p Point) bool { return p.ChallengeID == id })
+	if target < 0 {
+		return nil, ErrNotFound
+	}
+
+	var matches []Match
+	for i, p := range ix.points {
+		if i == target {
+			continue
+		}
+		d := distance(ix.normalized[target], ix.normalized[i])
+		if math.IsInf(d, 1) {
+			continue
+		}
+		matches = append(matches, Match{ChallengeID: p.ChallengeID, Fingerprint: p.Fingerprint, Distance: d})
+	}
+	slices.SortStableFunc(matches, func(a, b Match) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+// distance returns the root mean square difference of a and b over the dimensions both have. Points without common
+// dimensions are infinitely far apart.
+func distance(a, b []float64) float64 {
+	var sum float64
+	var n int
+	for d := range a {
+		if math.IsNaN(a[d]) || math.IsNaN(b[d]) {
+			continue
+		}
+		diff := a[d] - b[d]
+		sum += diff * diff
+		n++
+	}
+	if n == 0 {
+		return math.Inf(1)
+	}
+	return math.Sqrt(sum / float64(n))
+}
@@ -0,0 +1,86 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package similarity
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/Litebrowsers/donatello/internal/models"
+)
+
+// testPoint returns a complete point whose features are all value, except the red mean which is redMean.
+func testPoint(id string, value, redMean float64) Point {
+	vector := make([]float64, Dims)
+	for i := range vector {
+		vector[i] = value
+	}
+	vector[0] = redMean
+	return Point{ChallengeID: id, Fingerprint: "fp-" + id, Vector: vector}
+}
+
+func TestVector(t *testing.T) {
+	vector := Vector([]models.ChallengeFeature{{Channel: "g", Mean: 0.5, Bin7: 0.25, GradientMax: 0.75}})
+	if len(vector) != Dims {
+		t.Fatalf("Vector() failed. Expected %d dimensions, got %d", Dims, len(vector))
+	}
+	g := channelDims
+	if vector[g] != 0.5 || vector[g+12] != 0.25 || vector[g+14] != 0.75 {
+		t.Errorf("Vector() failed. Unexpected green features %v", vector[g:g+channelDims])
+	}
+	if !math.IsNaN(vector[0]) || (Point{Vector: vector}).Complete() {
+		t.Errorf("Vector() failed. Expected missing channels to be NaN, got %v", vector[:channelDims])
+	}
+}
+
+func TestIndex_Nearest(t *testing.T) {
+	alphaOnly := Vector([]models.ChallengeFeature{{Channel: "a", Mean: 0.5}})
+	redOnly := Vector([]models.ChallengeFeature{{Channel: "r", Mean: 0.5}})
+	ix := NewIndex([]Point{
+		testPoint("target", 0.5, 0.1),
+		testPoint("far", 0.5, 0.9),
+		testPoint("near", 0.5, 0.2),
+		testPoint("same", 0.5, 0.1),
+		{ChallengeID: "alpha", Vector: alphaOnly},
+		{ChallengeID: "red", Vector: redOnly},
+	})
+
+	matches, err := ix.Nearest("target", 3)
+	if err != nil {
+		t.Fatalf("Nearest() failed: %v", err)
+	}
+	var ids []string
+	for _, m := range matches {
+		ids = append(ids, m.ChallengeID)
+	}
+	if len(ids) != 3 || ids[0] != "same" || ids[1] != "near" || ids[2] != "far" {
+		t.Errorf("Nearest() failed. Expected [same near far], got %v", ids)
+	}
+	if matches[0].Distance != 0 || matches[0].Fingerprint != "fp-same" {
+		t.Errorf("Nearest() failed. Unexpected exact match %+v", matches[0])
+	}
+
+	// Points without common channels are never matched
+	matches, _ = ix.Nearest("alpha", 10)
+	for _, m := range matches {
+		if m.ChallengeID == "red" {
+			t.Errorf("Nearest() failed. Expected no match between disjoint channels, got %+v", m)
+		}
+	}
+
+	if _, err := ix.Nearest("missing", 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Nearest() failed. Expected %v, got %v", ErrNotFound, err)
+	}
+}
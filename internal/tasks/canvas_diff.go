@@ -0,0 +1,77 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package tasks
+
+import "errors"
+
+// ErrInvalidDiff is returned by ApplyDiff for a diff that does not fit the canvas.
+var ErrInvalidDiff = errors.New("tasks: invalid diff")
+
+// Clone returns a copy of the canvas.
+func (c *Canvas) Clone() *Canvas {
+	clone := NewCanvas(c.R.Bounds().Dx(), c.R.Bounds().Dy())
+	copy(clone.R.Pix, c.R.Pix)
+	copy(clone.G.Pix, c.G.Pix)
+	copy(clone.B.Pix, c.B.Pix)
+	copy(clone.A.Pix, c.A.Pix)
+	return clone
+}
+
+// ApplyDiff adds the differences of a sparse diff to the canvas. The diff lists the index of every differing pixel
+// followed by its signed red, green, blue and alpha differences, like sparseDiff of the SDK. It returns
+// ErrInvalidDiff if an index is outside of the canvas or a value leaves 0 to 255, leaving the canvas partly changed.
+func (c *Canvas) ApplyDiff(diff []int) error {
+	if len(diff)%5 != 0 {
+		return ErrInvalidDiff
+	}
+	channels := [4][]uint8{c.R.Pix, c.G.Pix, c.B.Pix, c.A.Pix}
+	for i := 0; i < len(diff); i += 5 {
+		index := diff[i]
+		if index < 0 || index >= len(c.R.Pix) {
+			return ErrInvalidDiff
+		}
+		for channel, pix := range channels {
+			value := int(pix[index]) + diff[i+1+channel]
+			if value < 0 || value > 255 {
+				return ErrInvalidDiff
+			}
+			pix[index] = uint8(value)
+		}
+	}
+	return nil
+}
+
+// NoiseFingerprint ports calculateNoiseFingerprint of the SDK: the SHA256 hash of the absolute differences between
+// actual and expected, the red channel first, then green, blue and alpha.
+func NoiseFingerprint(expected, actual *Canvas) (string, error) {
+	if expected.R.Bounds() != actual.R.Bounds() {
+		return "", errors.New("canvas sizes differ")
+	}
+	size := len(expected.R.Pix)
+	combined := make([]byte, 0, 4*size)
+	for _, pair := range [4][2][]uint8{
+		{expected.R.Pix, actual.R.Pix}, {expected.G.Pix, actual.G.Pix},
+		{expected.B.Pix, actual.B.Pix}, {expected.A.Pix, actual.A.Pix},
+	} {
+		for i := range size {
+			combined = append(combined, absDiff(pair[0][i], pair[1][i]))
+		}
+	}
+	return calculateHash(combined)
+}
+
+// absDiff returns |a - b|.
+func absDiff(a, b uint8) uint8 {
+	return max(a, b) - min(a, b)
+}
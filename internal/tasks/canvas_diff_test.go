@@ -0,0 +1,68 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package tasks
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCanvas_ApplyDiff(t *testing.T) {
+	canvas, err := Render("R:FF0000:2:2:0:0", 3, 3)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	clone := canvas.Clone()
+	if err := clone.ApplyDiff([]int{0, -1, 0, 0, 0, 8, 30, 0, 0, 255}); err != nil {
+		t.Fatalf("ApplyDiff() failed: %v", err)
+	}
+	if clone.R.Pix[0] != 254 || clone.R.Pix[8] != 30 || clone.A.Pix[8] != 255 || clone.R.Pix[1] != 255 {
+		t.Errorf("ApplyDiff() failed. Unexpected pixels R %v A %v", clone.R.Pix, clone.A.Pix)
+	}
+	if canvas.R.Pix[0] != 255 {
+		t.Errorf("Clone() failed. Expected the original canvas to be unchanged, got R %v", canvas.R.Pix)
+	}
+
+	for name, diff := range map[string][]int{
+		"incomplete":     {0, 1, 0, 0},
+		"negative index": {-1, 0, 0, 0, 1},
+		"index outside":  {9, 0, 0, 0, 1},
+		"overflow":       {0, 1, 0, 0, 0},
+		"underflow":      {2, -1, 0, 0, 0},
+	} {
+		if err := canvas.Clone().ApplyDiff(diff); !errors.Is(err, ErrInvalidDiff) {
+			t.Errorf("ApplyDiff() failed for a diff %s. Expected %v, got %v", name, ErrInvalidDiff, err)
+		}
+	}
+}
+
+func TestNoiseFingerprint(t *testing.T) {
+	expected, err := Render("R:FF0000:2:2:0:0", 3, 3)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	actual := expected.Clone()
+	if err := actual.ApplyDiff([]int{0, -1, 0, 0, 0, 4, 0, 10, 20, 0, 8, 30, 0, 0, 255}); err != nil {
+		t.Fatalf("ApplyDiff() failed: %v", err)
+	}
+
+	// Computed by calculateNoiseFingerprint of the SDK for the same channels
+	const sdk = "0a3ea4b1c05261354f3efaa20c6a270d5689858bb641bc703d6cb1ced6608e87"
+	if fingerprint, err := NoiseFingerprint(expected, actual); err != nil || fingerprint != sdk {
+		t.Errorf("NoiseFingerprint() failed. Expected %s, got %s (%v)", sdk, fingerprint, err)
+	}
+	if _, err := NoiseFingerprint(expected, NewCanvas(4, 4)); err == nil {
+		t.Error("NoiseFingerprint() failed. Expected canvases of different sizes to be rejected")
+	}
+}
@@ -170,6 +170,36 @@ func (c *Canvas) CalculateCombinedHash(results map[string]string) (string, error
 	return calculateHash([]byte(combined))
 }
 
+// TileHashes splits the canvas into grid×grid tiles and returns the SHA256 hash of each tile's pixels in row-major
+// tile order. A tile is hashed as interleaved RGBA bytes row by row, like the data of CanvasRenderingContext2D's
+// getImageData for the tile. Tile edges are at floor(i*size/grid).
+func (c *Canvas) TileHashes(grid int) ([]string, error) {
+	if grid <= 0 {
+		return nil, fmt.Errorf("invalid tile grid: %d", grid)
+	}
+	width, height := c.R.Bounds().Dx(), c.R.Bounds().Dy()
+	hashes := make([]string, 0, grid*grid)
+	for ty := 0; ty < grid; ty++ {
+		y0, y1 := ty*height/grid, (ty+1)*height/grid
+		for tx := 0; tx < grid; tx++ {
+			x0, x1 := tx*width/grid, (tx+1)*width/grid
+			pixels := make([]byte, 0, (x1-x0)*(y1-y0)*4)
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					i := c.R.PixOffset(x, y)
+					pixels = append(pixels, c.R.Pix[i], c.G.Pix[i], c.B.Pix[i], c.A.Pix[i])
+				}
+			}
+			hash, err := calculateHash(pixels)
+			if err != nil {
+				return nil, fmt.Errorf("failed to hash tile %d: %w", len(hashes), err)
+			}
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes, nil
+}
+
 func calculateHash(data []byte) (string, error) {
 	hasher := sha256.New()
 	_, err := hasher.Write(data)
@@ -94,3 +94,41 @@ func Test_hexToRGBA(t *testing.T) {
 		t.Errorf("hexToRGBA('0000FF') failed. Expected (0, 0, 255, 255), got (%d, %d, %d, %d)", rgba.R, rgba.G, rgba.B, rgba.A)
 	}
 }
+
+func TestCanvas_TileHashes(t *testing.T) {
+	canvas := NewCanvas(20, 20)
+	if err := canvas.DrawShapes([]Shape{Rectangle{Color: "FF0000", W: 5, H: 5, X: 5, Y: 0}}); err != nil {
+		t.Fatalf("DrawShapes() failed: %v", err)
+	}
+
+	hashes, err := canvas.TileHashes(4)
+	if err != nil {
+		t.Fatalf("TileHashes() failed: %v", err)
+	}
+	if len(hashes) != 16 {
+		t.Fatalf("TileHashes() failed. Expected 16 tiles, got %d", len(hashes))
+	}
+	// Only the second tile of the first row is painted, all other tiles are empty and hash alike
+	for i, hash := range hashes {
+		if painted := hash != hashes[0]; painted != (i == 1) {
+			t.Errorf("Tile %d: expected painted=%v", i, i == 1)
+		}
+	}
+
+	expected, _ := calculateHash(repeatPixel(255, 0, 0, 255, 25))
+	if hashes[1] != expected {
+		t.Errorf("TileHashes() failed. Expected %s for the painted tile, got %s", expected, hashes[1])
+	}
+
+	if _, err := canvas.TileHashes(0); err == nil {
+		t.Error("Expected TileHashes() to fail for an empty grid")
+	}
+}
+
+func repeatPixel(r, g, b, a byte, n int) []byte {
+	pixels := make([]byte, 0, n*4)
+	for range n {
+		pixels = append(pixels, r, g, b, a)
+	}
+	return pixels
+}
@@ -0,0 +1,135 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+)
+
+// MaxScale is the largest scale factor of EncodePNG.
+const MaxScale = 64
+
+// Render parses task and draws it on a new width×height canvas.
+func Render(task string, width, height int) (*Canvas, error) {
+	shapes, err := ParseTask(task)
+	if err != nil {
+		return nil, err
+	}
+	canvas := NewCanvas(width, height)
+	if err := canvas.DrawShapes(shapes); err != nil {
+		return nil, err
+	}
+	return canvas, nil
+}
+
+// Image composes the channels into an image. The channels are not premultiplied by alpha, like the ImageData of a
+// browser canvas.
+func (c *Canvas) Image() *image.NRGBA {
+	img := image.NewNRGBA(c.R.Bounds())
+	for i := range c.R.Pix {
+		img.Pix[4*i] = c.R.Pix[i]
+		img.Pix[4*i+1] = c.G.Pix[i]
+		img.Pix[4*i+2] = c.B.Pix[i]
+		img.Pix[4*i+3] = c.A.Pix[i]
+	}
+	return img
+}
+
+// FromImage splits img into a canvas. Colors are converted to non-premultiplied RGBA.
+func FromImage(img image.Image) *Canvas {
+	nrgba, ok := img.(*image.NRGBA)
+	if !ok || nrgba.Rect.Min != (image.Point{}) || nrgba.Stride != 4*nrgba.Rect.Dx() {
+		nrgba = image.NewNRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+		draw.Draw(nrgba, nrgba.Rect, img, img.Bounds().Min, draw.Src)
+	}
+	canvas := NewCanvas(nrgba.Rect.Dx(), nrgba.Rect.Dy())
+	for i := range canvas.R.Pix {
+		canvas.R.Pix[i] = nrgba.Pix[4*i]
+		canvas.G.Pix[i] = nrgba.Pix[4*i+1]
+		canvas.B.Pix[i] = nrgba.Pix[4*i+2]
+		canvas.A.Pix[i] = nrgba.Pix[4*i+3]
+	}
+	return canvas
+}
+
+// diffChanged is the color of the changed pixels of a diff image.
+var diffChanged = color.NRGBA{R: 0xFF, A: 0xFF}
+
+// diffUnchangedAlpha scales the alpha of the unchanged pixels of a diff image, so the changed ones stand out.
+const diffUnchangedAlpha = 0x40
+
+// Diff compares actual with expected. It returns an image with the pixels that differ in red and the others faded,
+// and the number of differing pixels.
+func Diff(expected, actual *Canvas) (*image.NRGBA, int, error) {
+	if expected.R.Bounds() != actual.R.Bounds() {
+		return nil, 0, errors.New("canvas sizes differ")
+	}
+	img := expected.Image()
+	changed := 0
+	for i := range expected.R.Pix {
+		if expected.R.Pix[i] != actual.R.Pix[i] || expected.G.Pix[i] != actual.G.Pix[i] ||
+			expected.B.Pix[i] != actual.B.Pix[i] || expected.A.Pix[i] != actual.A.Pix[i] {
+			changed++
+			img.Pix[4*i], img.Pix[4*i+1], img.Pix[4*i+2], img.Pix[4*i+3] = diffChanged.R, diffChanged.G, diffChanged.B, diffChanged.A
+			continue
+		}
+		img.Pix[4*i+3] = uint8(int(img.Pix[4*i+3]) * diffUnchangedAlpha / 0xFF)
+	}
+	return img, changed, nil
+}
+
+// EncodePNG writes the canvas to w as a PNG image with every pixel enlarged to a scale×scale square.
+func (c *Canvas) EncodePNG(w io.Writer, scale int) error {
+	return EncodePNG(w, c.Image(), scale)
+}
+
+// EncodePNG writes img to w as a PNG image with every pixel enlarged to a scale×scale square.
+func EncodePNG(w io.Writer, img *image.NRGBA, scale int) error {
+	if scale < 1 || scale > MaxScale {
+		return fmt.Errorf("invalid scale %d, must be between 1 and %d", scale, MaxScale)
+	}
+	if err := png.Encode(w, Scale(img, scale)); err != nil {
+		return fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	return nil
+}
+
+// Scale enlarges img by factor without smoothing, so every pixel stays visible. A factor of 1 returns img.
+func Scale(img *image.NRGBA, factor int) *image.NRGBA {
+	if factor == 1 {
+		return img
+	}
+	bounds := img.Bounds()
+	scaled := image.NewNRGBA(image.Rect(0, 0, bounds.Dx()*factor, bounds.Dy()*factor))
+	for y := 0; y < bounds.Dy(); y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+4*bounds.Dx()]
+		line := scaled.Pix[y*factor*scaled.Stride : (y*factor+1)*scaled.Stride]
+		for x := 0; x < bounds.Dx(); x++ {
+			for i := 0; i < factor; i++ {
+				copy(line[4*(x*factor+i):], row[4*x:4*x+4])
+			}
+		}
+		// The other lines of the scaled row repeat the first
+		for i := 1; i < factor; i++ {
+			copy(scaled.Pix[(y*factor+i)*scaled.Stride:], line)
+		}
+	}
+	return scaled
+}
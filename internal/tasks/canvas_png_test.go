@@ -0,0 +1,131 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package tasks
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestCanvas_Image(t *testing.T) {
+	canvas := NewCanvas(4, 3)
+	if err := canvas.DrawShapes([]Shape{Rectangle{Color: "FF8000", W: 2, H: 1, X: 1, Y: 2}}); err != nil {
+		t.Fatalf("DrawShapes() failed: %v", err)
+	}
+
+	img := canvas.Image()
+	if img.Bounds().Dx() != 4 || img.Bounds().Dy() != 3 {
+		t.Fatalf("Image() failed. Expected a 4×3 image, got %v", img.Bounds())
+	}
+	if c := img.NRGBAAt(1, 2); c != (color.NRGBA{R: 0xFF, G: 0x80, A: 0xFF}) {
+		t.Errorf("Image() failed. Expected an orange pixel at (1, 2), got %v", c)
+	}
+	if c := img.NRGBAAt(0, 0); c != (color.NRGBA{}) {
+		t.Errorf("Image() failed. Expected a transparent pixel at (0, 0), got %v", c)
+	}
+}
+
+func TestCanvas_EncodePNG(t *testing.T) {
+	canvas := NewCanvas(4, 3)
+	if err := canvas.DrawShapes([]Shape{Rectangle{Color: "FF8000", W: 2, H: 1, X: 1, Y: 2}}); err != nil {
+		t.Fatalf("DrawShapes() failed: %v", err)
+	}
+
+	var encoded bytes.Buffer
+	if err := canvas.EncodePNG(&encoded, 3); err != nil {
+		t.Fatalf("EncodePNG() failed: %v", err)
+	}
+	decoded, err := png.Decode(&encoded)
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+	if decoded.Bounds().Dx() != 12 || decoded.Bounds().Dy() != 9 {
+		t.Fatalf("EncodePNG() failed. Expected a 12×9 image, got %v", decoded.Bounds())
+	}
+	// Pixel (1, 2) covers (3, 6) to (5, 8)
+	orange := color.NRGBAModel.Convert(color.NRGBA{R: 0xFF, G: 0x80, A: 0xFF})
+	for _, p := range [][2]int{{3, 6}, {5, 8}, {8, 7}} {
+		if c := color.NRGBAModel.Convert(decoded.At(p[0], p[1])); c != orange {
+			t.Errorf("EncodePNG() failed. Expected an orange pixel at %v, got %v", p, c)
+		}
+	}
+	for _, p := range [][2]int{{2, 6}, {3, 5}, {9, 8}} {
+		if _, _, _, a := decoded.At(p[0], p[1]).RGBA(); a != 0 {
+			t.Errorf("EncodePNG() failed. Expected a transparent pixel at %v", p)
+		}
+	}
+
+	for _, scale := range []int{0, MaxScale + 1} {
+		if err := canvas.EncodePNG(&encoded, scale); err == nil {
+			t.Errorf("EncodePNG() failed. Expected scale %d to be rejected", scale)
+		}
+	}
+}
+
+func TestFromImage(t *testing.T) {
+	canvas := NewCanvas(4, 3)
+	if err := canvas.DrawShapes([]Shape{Rectangle{Color: "FF8000", W: 2, H: 1, X: 1, Y: 2}}); err != nil {
+		t.Fatalf("DrawShapes() failed: %v", err)
+	}
+	var encoded bytes.Buffer
+	if err := canvas.EncodePNG(&encoded, 1); err != nil {
+		t.Fatalf("EncodePNG() failed: %v", err)
+	}
+	decoded, err := png.Decode(&encoded)
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+
+	// The PNG round trip keeps the pixels and so the hash
+	expected, _ := canvas.CalculateHashes()
+	actual, _ := FromImage(decoded).CalculateHashes()
+	for channel, hash := range expected {
+		if actual[channel] != hash {
+			t.Errorf("FromImage() failed. Channel %s hashes to %s, expected %s", channel, actual[channel], hash)
+		}
+	}
+
+	// Other color models are converted
+	gray := image.NewGray(image.Rect(0, 0, 2, 2))
+	gray.Pix[3] = 0x80
+	if c := FromImage(gray); c.R.Pix[3] != 0x80 || c.B.Pix[3] != 0x80 || c.A.Pix[3] != 0xFF {
+		t.Errorf("FromImage() failed. Expected an opaque gray pixel, got %v", c.Image().NRGBAAt(1, 1))
+	}
+}
+
+func TestDiff(t *testing.T) {
+	expected := NewCanvas(2, 2)
+	if err := expected.DrawShapes([]Shape{Rectangle{Color: "0000FF", W: 2, H: 2}}); err != nil {
+		t.Fatalf("DrawShapes() failed: %v", err)
+	}
+	actual := FromImage(expected.Image())
+	actual.G.Pix[3] = 1
+
+	img, changed, err := Diff(expected, actual)
+	if err != nil {
+		t.Fatalf("Diff() failed: %v", err)
+	}
+	if changed != 1 || img.NRGBAAt(1, 1) != diffChanged {
+		t.Errorf("Diff() failed. Expected pixel (1, 1) to be marked, got %d changes and %v", changed, img.NRGBAAt(1, 1))
+	}
+	if c := img.NRGBAAt(0, 0); c != (color.NRGBA{B: 0xFF, A: diffUnchangedAlpha}) {
+		t.Errorf("Diff() failed. Expected a faded blue pixel at (0, 0), got %v", c)
+	}
+	if _, _, err := Diff(expected, NewCanvas(3, 2)); err == nil {
+		t.Errorf("Diff() failed. Expected canvases of different sizes to be rejected")
+	}
+}
@@ -15,11 +15,17 @@ package tasks
 
 import (
 	"fmt"
+	"log/slog"
 	"math"
 	"math/rand"
+	"sort"
 	"strings"
 )
 
+// FormatVersion is the version of the task encoding format. It changes whenever the encoding or rendering of
+// tasks changes in a way that alters their hashes.
+const FormatVersion = 1
+
 // Shape interface defines the common behavior for all shapes.
 type Shape interface {
 	Encode() string
@@ -158,9 +164,38 @@ func (tg *TaskGenerator) GenerateTask() string {
 	return strings.Join(encodedShapes, ";")
 }
 
-// GenerateRandomColor generates a random 6-digit hexadecimal color string.
-func GenerateRandomColor() string {
-	return fmt.Sprintf("%06X", rand.Intn(0xFFFFFF+1))
+// Profile returns a compact description of the shape types used by shapes, such as "X4+L+R".
+// Chessboards come first and include their grid size, other shape types are sorted and listed once.
+func Profile(shapes []Shape) string {
+	var boards []string
+	seen := make(map[string]bool)
+	var types []string
+	for _, s := range shapes {
+		if board, ok := s.(Chessboard); ok {
+			boards = append(boards, fmt.Sprintf("X%d", board.GridSize))
+			continue
+		}
+		shapeType, _, _ := strings.Cut(s.Encode(), ":")
+		if !seen[shapeType] {
+			seen[shapeType] = true
+			types = append(types, shapeType)
+		}
+	}
+	sort.Strings(types)
+	return strings.Join(append(boards, types...), "+")
+}
+
+// Intn returns a random number in [0, n) from rng, or from the global source of math/rand when rng is nil.
+func Intn(rng *rand.Rand, n int) int {
+	if rng == nil {
+		return rand.Intn(n)
+	}
+	return rng.Intn(n)
+}
+
+// GenerateRandomColor generates a random 6-digit hexadecimal color string from rng, see Intn.
+func GenerateRandomColor(rng *rand.Rand) string {
+	return fmt.Sprintf("%06X", Intn(rng, 0xFFFFFF+1))
 }
 
 // Overlaps checks if two Rectangles overlap.
@@ -260,63 +295,64 @@ func interpolateColor(color1, color2 string, progress float64) string {
 	return fmt.Sprintf("%02X%02X%02X", r, g, b)
 }
 
-// GenerateRandomShapes generates a slice of random shapes.
-func GenerateRandomShapes(canvasSize int, count int) []Shape {
+// GenerateRandomShapes generates a slice of random shapes from rng, see Intn.
+func GenerateRandomShapes(rng *rand.Rand, canvasSize int, count int) []Shape {
 	if count <= 0 {
 		return nil
 	}
 
 	shapes := make([]Shape, count)
 	for i := 0; i < count; i++ {
-		switch rand.Intn(5) { // 0: Rectangle, 1: Circle, 2: Triangle, 3: Line, 4: Ellipse
+		switch Intn(rng, 5) { // 0: Rectangle, 1: Circle, 2: Triangle, 3: Line, 4: Ellipse
 		case 0:
 			shapes[i] = Rectangle{
-				Color: GenerateRandomColor(),
-				W:     rand.Intn(canvasSize/2) + 1,
-				H:     rand.Intn(canvasSize/2) + 1,
-				X:     rand.Intn(canvasSize),
-				Y:     rand.Intn(canvasSize),
+				Color: GenerateRandomColor(rng),
+				W:     Intn(rng, canvasSize/2) + 1,
+				H:     Intn(rng, canvasSize/2) + 1,
+				X:     Intn(rng, canvasSize),
+				Y:     Intn(rng, canvasSize),
 			}
 		case 1:
 			shapes[i] = Circle{
-				Color: GenerateRandomColor(),
-				R:     rand.Intn(canvasSize/4) + 1,
-				X:     rand.Intn(canvasSize),
-				Y:     rand.Intn(canvasSize),
+				Color: GenerateRandomColor(rng),
+				R:     Intn(rng, canvasSize/4) + 1,
+				X:     Intn(rng, canvasSize),
+				Y:     Intn(rng, canvasSize),
 			}
 		case 2:
 			shapes[i] = Triangle{
-				Color: GenerateRandomColor(),
-				X1:    rand.Intn(canvasSize),
-				Y1:    rand.Intn(canvasSize),
-				X2:    rand.Intn(canvasSize),
-				Y2:    rand.Intn(canvasSize),
-				X3:    rand.Intn(canvasSize),
-				Y3:    rand.Intn(canvasSize),
+				Color: GenerateRandomColor(rng),
+				X1:    Intn(rng, canvasSize),
+				Y1:    Intn(rng, canvasSize),
+				X2:    Intn(rng, canvasSize),
+				Y2:    Intn(rng, canvasSize),
+				X3:    Intn(rng, canvasSize),
+				Y3:    Intn(rng, canvasSize),
 			}
 		case 3:
 			shapes[i] = Line{
-				Color: GenerateRandomColor(),
-				X1:    rand.Intn(canvasSize),
-				Y1:    rand.Intn(canvasSize),
-				X2:    rand.Intn(canvasSize),
-				Y2:    rand.Intn(canvasSize),
+				Color: GenerateRandomColor(rng),
+				X1:    Intn(rng, canvasSize),
+				Y1:    Intn(rng, canvasSize),
+				X2:    Intn(rng, canvasSize),
+				Y2:    Intn(rng, canvasSize),
 			}
 		case 4:
 			shapes[i] = Ellipse{
-				Color: GenerateRandomColor(),
-				RX:    rand.Intn(canvasSize/2) + 1,
-				RY:    rand.Intn(canvasSize/2) + 1,
-				X:     rand.Intn(canvasSize),
-				Y:     rand.Intn(canvasSize),
+				Color: GenerateRandomColor(rng),
+				RX:    Intn(rng, canvasSize/2) + 1,
+				RY:    Intn(rng, canvasSize/2) + 1,
+				X:     Intn(rng, canvasSize),
+				Y:     Intn(rng, canvasSize),
 			}
 		}
 	}
 	return shapes
 }
 
-// GenerateRandomEvenSizedPrimitives generates a slice of random square shapes with even side lengths.
-func GenerateRandomEvenSizedPrimitives(canvasSize int, count int) []Shape {
+// GenerateRandomEvenSizedPrimitives generates a slice of random square shapes with even side lengths from rng, see
+// Intn.
+func GenerateRandomEvenSizedPrimitives(rng *rand.Rand, canvasSize int, count int) []Shape {
 	if count <= 0 {
 		return nil
 	}
@@ -328,35 +364,35 @@ func GenerateRandomEvenSizedPrimitives(canvasSize int, count int) []Shape {
 		retries := 0
 		for retries < maxRetries {
 			var newShape Shape
-			switch rand.Intn(2) { // 0: Even-sided Square, 1: Line
+			switch Intn(rng, 2) { // 0: Even-sided Square, 1: Line
 			case 0:
-				side := (rand.Intn(5) + 1) * 2
+				side := (Intn(rng, 5) + 1) * 2
 				newShape = Rectangle{
-					Color: GenerateRandomColor(),
+					Color: GenerateRandomColor(rng),
 					W:     side,
 					H:     side,
-					X:     rand.Intn(canvasSize - side),
-					Y:     rand.Intn(canvasSize - side),
+					X:     Intn(rng, canvasSize-side),
+					Y:     Intn(rng, canvasSize-side),
 				}
 			case 1:
-				thickness := (rand.Intn(2) + 1) * 2 // 2 or 4
-				if rand.Intn(2) == 0 {
-					x := rand.Intn(canvasSize - thickness)
-					y1 := rand.Intn(canvasSize)
-					y2 := rand.Intn(canvasSize)
+				thickness := (Intn(rng, 2) + 1) * 2 // 2 or 4
+				if Intn(rng, 2) == 0 {
+					x := Intn(rng, canvasSize-thickness)
+					y1 := Intn(rng, canvasSize)
+					y2 := Intn(rng, canvasSize)
 					newShape = Line{
-						Color: GenerateRandomColor(),
+						Color: GenerateRandomColor(rng),
 						X1:    x, Y1: y1,
 						X2:        x,
 						Y2:        y2,
 						Thickness: thickness,
 					}
 				} else {
-					y := rand.Intn(canvasSize - thickness)
-					x1 := rand.Intn(canvasSize)
-					x2 := rand.Intn(canvasSize)
+					y := Intn(rng, canvasSize-thickness)
+					x1 := Intn(rng, canvasSize)
+					x2 := Intn(rng, canvasSize)
 					newShape = Line{
-						Color:     GenerateRandomColor(),
+						Color:     GenerateRandomColor(rng),
 						X1:        x1,
 						Y1:        y,
 						X2:        x2,
@@ -373,7 +409,7 @@ func GenerateRandomEvenSizedPrimitives(canvasSize int, count int) []Shape {
 			retries++
 		}
 		if retries == maxRetries {
-			fmt.Printf("Warning: Could not place shape %d after %d retries. Canvas might be full or shapes too large.\n", i, maxRetries)
+			slog.Warn("could not place shape, canvas might be full or shapes too large", "shape", i, "retries", maxRetries)
 		}
 	}
 	return primitives
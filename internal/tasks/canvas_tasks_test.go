@@ -14,6 +14,7 @@ thor betson at gmail dot com
 package tasks
 
 import (
+	"math/rand"
 	"testing"
 )
 
@@ -82,9 +83,26 @@ func TestTaskGenerator_GenerateTask(t *testing.T) {
 	}
 }
 
+func TestProfile(t *testing.T) {
+	shapes := []Shape{
+		Chessboard{GridSize: 4, Color1: "FF0000", Color2: "0000FF"},
+		Rectangle{Color: "FF0000", W: 4, H: 4, X: 0, Y: 0},
+		Line{Color: "00FF00", X1: 1, Y1: 1, X2: 1, Y2: 8, Thickness: 2},
+		Rectangle{Color: "00FF00", W: 2, H: 2, X: 8, Y: 8},
+	}
+	expected := "X4+L+R"
+	if profile := Profile(shapes); profile != expected {
+		t.Errorf("Profile() failed. Expected %s, got %s", expected, profile)
+	}
+
+	if profile := Profile(nil); profile != "" {
+		t.Errorf("Profile(nil) should be empty, got %s", profile)
+	}
+}
+
 func TestGenerateRandomShapes(t *testing.T) {
 	count := 5
-	shapes := GenerateRandomShapes(CanvasSize, count)
+	shapes := GenerateRandomShapes(nil, CanvasSize, count)
 
 	if len(shapes) != count {
 		t.Errorf("GenerateRandomShapes() returned %d shapes, expected %d", len(shapes), count)
@@ -97,15 +115,29 @@ func TestGenerateRandomShapes(t *testing.T) {
 	}
 
 	// Test with count = 0
-	shapesZero := GenerateRandomShapes(CanvasSize, 0)
+	shapesZero := GenerateRandomShapes(nil, CanvasSize, 0)
 	if shapesZero != nil {
 		t.Errorf("GenerateRandomShapes(0) should return nil, got %v", shapesZero)
 	}
 }
 
+func TestGenerateRandomShapes_Seed(t *testing.T) {
+	generate := func() string {
+		rng := rand.New(rand.NewSource(42))
+		shapes := GenerateRandomEvenSizedPrimitives(rng, CanvasSize, 3)
+		shapes = append(shapes, GenerateRandomShapes(rng, CanvasSize, 3)...)
+		return NewTaskGenerator(shapes...).GenerateTask()
+	}
+
+	first, second := generate(), generate()
+	if first != second {
+		t.Errorf("Expected the same seed to generate the same task, got %s and %s", first, second)
+	}
+}
+
 func TestGenerateRandomEvenSizedPrimitives(t *testing.T) {
 	count := 10 // Increase count to have a higher chance of generating both types
-	primitives := GenerateRandomEvenSizedPrimitives(CanvasSize, count)
+	primitives := GenerateRandomEvenSizedPrimitives(nil, CanvasSize, count)
 
 	if len(primitives) != count {
 		t.Errorf("GenerateRandomEvenSizedPrimitives() returned %d primitives, expected %d", len(primitives), count)
@@ -134,7 +166,7 @@ func TestGenerateRandomEvenSizedPrimitives(t *testing.T) {
 	}
 
 	// Test with count = 0
-	primitivesZero := GenerateRandomEvenSizedPrimitives(CanvasSize, 0)
+	primitivesZero := GenerateRandomEvenSizedPrimitives(nil, CanvasSize, 0)
 	if primitivesZero != nil {
 		t.Errorf("GenerateRandomEvenSizedPrimitives(0) should return nil, got %v", primitivesZero)
 	}
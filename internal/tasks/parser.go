@@ -19,6 +19,10 @@ import (
 	"strings"
 )
 
+// MaxChessboardGrid is the largest chessboard grid a task may have. Every cell is drawn as a rectangle, so the grid
+// is bounded to keep tasks from user input cheap to draw. Generated tasks use grids of at most 10.
+const MaxChessboardGrid = 64
+
 // ParseTask parses an encoded task string and returns a slice of shapes.
 func ParseTask(task string) ([]Shape, error) {
 	encodedShapes := strings.Split(task, ";")
@@ -62,6 +66,12 @@ func ParseTask(task string) ([]Shape, error) {
 				return nil, err
 			}
 			shapes = append(shapes, shape)
+		case "X":
+			shape, err := parseChessboard(parts)
+			if err != nil {
+				return nil, err
+			}
+			shapes = append(shapes, shape)
 		default:
 			return nil, fmt.Errorf("unknown shape type: %s", shapeType)
 		}
@@ -192,3 +202,17 @@ func parseEllipse(parts []string) (Ellipse, error) {
 	}
 	return Ellipse{Color: parts[1], RX: rx, RY: ry, X: x, Y: y}, nil
 }
+
+func parseChessboard(parts []string) (Chessboard, error) {
+	if len(parts) != 4 {
+		return Chessboard{}, fmt.Errorf("invalid chessboard format: %v", parts)
+	}
+	gridSize, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return Chessboard{}, err
+	}
+	if gridSize < 1 || gridSize > MaxChessboardGrid {
+		return Chessboard{}, fmt.Errorf("chessboard grid must be between 1 and %d, got %d", MaxChessboardGrid, gridSize)
+	}
+	return Chessboard{GridSize: gridSize, Color1: parts[2], Color2: parts[3]}, nil
+}
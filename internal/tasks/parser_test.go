@@ -0,0 +1,46 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package tasks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTask(t *testing.T) {
+	shapes := []Shape{
+		Chessboard{GridSize: 4, Color1: "000000", Color2: "FFFFFF"},
+		Rectangle{Color: "FF0000", W: 5, H: 3, X: 10, Y: 5},
+		Circle{Color: "00FF00", R: 4, X: 15, Y: 15},
+		Triangle{Color: "0000FF", X1: 2, Y1: 2, X2: 6, Y2: 2, X3: 4, Y3: 6},
+		Line{Color: "FF00FF", X1: 5, Y1: 5, X2: 12, Y2: 8, Thickness: 2},
+		Ellipse{Color: "FFFF00", RX: 6, RY: 3, X: 20, Y: 10},
+	}
+	parsed, err := ParseTask(NewTaskGenerator(shapes...).GenerateTask())
+	if err != nil {
+		t.Fatalf("ParseTask() failed: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, shapes) {
+		t.Errorf("ParseTask() failed. Expected %v, got %v", shapes, parsed)
+	}
+
+	invalid := []string{"", "Q:1:2", "R:FF0000:5:3", "X:four:000000:FFFFFF", "X:4:000000",
+		// Every chessboard cell is a rectangle, so huge grids would take forever to draw
+		"X:0:000000:FFFFFF", "X:-4:000000:FFFFFF", "X:65:000000:FFFFFF", "X:100000:FF0000:00FF00"}
+	for _, task := range invalid {
+		if _, err := ParseTask(task); err == nil {
+			t.Errorf("ParseTask(%q) failed. Expected an error", task)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package tasks
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"testing"
+
+	"github.com/Litebrowsers/donatello/resources"
+	"github.com/dop251/goja"
+)
+
+// predictor runs the CanvasPredictor of predictor.worker.js in goja, with the parts of the worker API it uses.
+type predictor struct {
+	vm        *goja.Runtime
+	onMessage goja.Callable
+	posted    goja.Value
+	// rejected is the reason of the last promise rejected without a handler, such as an error in onmessage
+	rejected goja.Value
+}
+
+func newPredictor(t *testing.T) *predictor {
+	t.Helper()
+	script, err := fs.ReadFile(resources.FS, "predictor.worker.js")
+	if err != nil {
+		t.Fatalf("Failed to read predictor.worker.js: %v", err)
+	}
+
+	p := &predictor{vm: goja.New()}
+	p.vm.SetPromiseRejectionTracker(func(promise *goja.Promise, operation goja.PromiseRejectionOperation) {
+		if operation == goja.PromiseRejectionReject {
+			p.rejected = promise.Result()
+		}
+	})
+	self := p.vm.NewObject()
+	_ = self.Set("postMessage", func(v goja.Value) { p.posted = v })
+	subtle := p.vm.NewObject()
+	_ = subtle.Set("digest", func(algorithm string, data []byte) *goja.Promise {
+		promise, resolve, reject := p.vm.NewPromise()
+		if algorithm != "SHA-256" {
+			_ = reject(p.vm.NewTypeError("unsupported algorithm %s", algorithm))
+			return promise
+		}
+		sum := sha256.Sum256(data)
+		_ = resolve(p.vm.NewArrayBuffer(sum[:]))
+		return promise
+	})
+	crypto := p.vm.NewObject()
+	_ = crypto.Set("subtle", subtle)
+	_ = p.vm.Set("self", self)
+	_ = p.vm.Set("crypto", crypto)
+	// The predictor only encodes hex digests, so ASCII is enough
+	if _, err := p.vm.RunString(`class TextEncoder {
+		encode(s) { return new Uint8Array(Array.from(s, c => c.charCodeAt(0))); }
+	}`); err != nil {
+		t.Fatalf("Failed to define TextEncoder: %v", err)
+	}
+
+	if _, err := p.vm.RunScript("predictor.worker.js", string(script)); err != nil {
+		t.Fatalf("Failed to run predictor.worker.js: %v", err)
+	}
+	var ok bool
+	if p.onMessage, ok = goja.AssertFunction(self.Get("onmessage")); !ok {
+		t.Fatal("predictor.worker.js did not set self.onmessage")
+	}
+	return p
+}
+
+// prediction is the message the predictor posts for a task.
+type prediction struct {
+	CombinedHash string
+	Hashes       map[string]string
+	Channels     map[string][]byte
+}
+
+// predict sends task to the predictor and returns its prediction.
+func (p *predictor) predict(task string, width, height int) (*prediction, error) {
+	p.posted, p.rejected = nil, nil
+	message := p.vm.NewObject()
+	_ = message.Set("data", map[string]any{"taskString": task, "width": width, "height": height})
+	// The promise jobs run before the call returns, so the message is posted by then
+	if _, err := p.onMessage(goja.Undefined(), message); err != nil {
+		return nil, err
+	}
+	if p.rejected != nil {
+		return nil, fmt.Errorf("onmessage failed: %v", p.rejected)
+	}
+	if p.posted == nil {
+		return nil, fmt.Errorf("no message posted")
+	}
+
+	result := p.posted.ToObject(p.vm)
+	hashes := result.Get("hashes").ToObject(p.vm)
+	channels := result.Get("channels").ToObject(p.vm)
+	predicted := &prediction{
+		CombinedHash: result.Get("combinedHash").String(),
+		Hashes:       make(map[string]string),
+		Channels:     make(map[string][]byte),
+	}
+	for _, channel := range []string{"r", "g", "b", "a"} {
+		predicted.Hashes[channel] = hashes.Get(channel).String()
+		pix, ok := channels.Get(channel).Export().([]byte)
+		if !ok {
+			return nil, fmt.Errorf("channel %s is not a Uint8Array", channel)
+		}
+		predicted.Channels[channel] = pix
+	}
+	return predicted, nil
+}
+
+// conformanceTask returns a random task of the shape types the predictor draws. Every other task is built like
+// the first tasks of challenges, the others also cover odd sizes and shapes crossing the canvas edges.
+func conformanceTask(rng *rand.Rand, i, size int) string {
+	if i%2 == 0 {
+		grids := []int{2, 4, 10}
+		shapes := []Shape{Chessboard{GridSize: grids[rng.Intn(len(grids))], Color1: GenerateRandomColor(rng), Color2: GenerateRandomColor(rng)}}
+		shapes = append(shapes, GenerateRandomEvenSizedPrimitives(rng, size, rng.Intn(6)+1)...)
+		return NewTaskGenerator(shapes...).GenerateTask()
+	}
+
+	// coordinate returns a position up to a quarter of the canvas outside of it
+	coordinate := func() int { return rng.Intn(size+size/2) - size/4 }
+	var shapes []Shape
+	if rng.Intn(2) == 0 {
+		shapes = append(shapes, Chessboard{GridSize: rng.Intn(11) + 2, Color1: GenerateRandomColor(rng), Color2: GenerateRandomColor(rng)})
+	}
+	for range rng.Intn(8) + 1 {
+		switch rng.Intn(3) {
+		case 0:
+			shapes = append(shapes, Rectangle{Color: GenerateRandomColor(rng), W: rng.Intn(size), H: rng.Intn(size), X: coordinate(), Y: coordinate()})
+		case 1:
+			x, y1, y2 := coordinate(), coordinate(), coordinate()
+			shapes = append(shapes, Line{Color: GenerateRandomColor(rng), X1: x, Y1: y1, X2: x, Y2: y2, Thickness: rng.Intn(6)})
+		case 2:
+			y, x1, x2 := coordinate(), coordinate(), coordinate()
+			shapes = append(shapes, Line{Color: GenerateRandomColor(rng), X1: x1, Y1: y, X2: x2, Y2: y, Thickness: rng.Intn(6)})
+		}
+	}
+	return NewTaskGenerator(shapes...).GenerateTask()
+}
+
+// TestPredictorConformance checks that the predictor of the SDK draws the same pixels as Canvas and hashes them the
+// same way. A failure prints the task to reproduce it with "donatello task render".
+func TestPredictorConformance(t *testing.T) {
+	count := 2000
+	if testing.Short() {
+		count = 200
+	}
+	p := newPredictor(t)
+	rng := rand.New(rand.NewSource(1))
+	sizes := []int{20, 11, 32, 64}
+	channels := map[string]string{"r": "red", "g": "green", "b": "blue", "a": "alpha"}
+
+	for i := range count {
+		size := sizes[i%len(sizes)]
+		task := conformanceTask(rng, i, size)
+
+		canvas, err := Render(task, size, size)
+		if err != nil {
+			t.Fatalf("Render(%q) failed: %v", task, err)
+		}
+		hashes, err := canvas.CalculateHashes()
+		if err != nil {
+			t.Fatalf("CalculateHashes() failed: %v", err)
+		}
+		combined, err := canvas.CalculateCombinedHash(hashes)
+		if err != nil {
+			t.Fatalf("CalculateCombinedHash() failed: %v", err)
+		}
+		predicted, err := p.predict(task, size, size)
+		if err != nil {
+			t.Fatalf("predict(%q) failed: %v", task, err)
+		}
+
+		pix := map[string][]byte{"r": canvas.R.Pix, "g": canvas.G.Pix, "b": canvas.B.Pix, "a": canvas.A.Pix}
+		for channel, name := range channels {
+			if index := firstDifference(pix[channel], predicted.Channels[channel]); index >= 0 {
+				t.Fatalf("Predictor failed for %q on %d×%d. Channel %s differs at pixel (%d, %d)",
+					task, size, size, name, index%size, index/size)
+			}
+			if predicted.Hashes[channel] != hashes[name] {
+				t.Fatalf("Predictor failed for %q on %d×%d. Expected %s hash %s, got %s",
+					task, size, size, name, hashes[name], predicted.Hashes[channel])
+			}
+		}
+		if predicted.CombinedHash != combined {
+			t.Fatalf("Predictor failed for %q on %d×%d. Expected combined hash %s, got %s",
+				task, size, size, combined, predicted.CombinedHash)
+		}
+	}
+}
+
+// firstDifference returns the first index at which a and b differ, or -1 if they are equal.
+func firstDifference(a, b []byte) int {
+	for i := range max(len(a), len(b)) {
+		if i >= len(a) || i >= len(b) || a[i] != b[i] {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,100 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Litebrowsers/donatello/internal/config"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupRecorder(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return exporter
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	cfg := config.Default().Tracing
+	cfg.Exporter = "jaeger"
+	if _, err := Setup(context.Background(), cfg); err == nil {
+		t.Error("Expected Setup to fail for an unknown exporter")
+	}
+}
+
+func TestMiddleware_LinksChallengeSpans(t *testing.T) {
+	exporter := setupRecorder(t)
+	gin.SetMode(gin.TestMode)
+
+	var traceParent string
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/", func(c *gin.Context) {
+		traceParent = TraceParent(c.Request.Context())
+		LinkChallenge(c.Request.Context(), "challenge-1", "")
+		c.Status(http.StatusOK)
+	})
+	router.GET("/challenge", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		LinkChallenge(ctx, "challenge-1", traceParent)
+		_, span := Start(ctx, "canvas.draw")
+		span.End()
+		c.Status(http.StatusOK)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/challenge?id=challenge-1", nil))
+
+	if traceParent == "" {
+		t.Fatal("Expected a traceparent for the GET / span")
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+	index, draw, challenge := spans[0], spans[1], spans[2]
+
+	if index.Name != "GET /" || challenge.Name != "GET /challenge" {
+		t.Errorf("Unexpected span names %q and %q", index.Name, challenge.Name)
+	}
+	if draw.Parent.SpanID() != challenge.SpanContext.SpanID() {
+		t.Error("Expected canvas.draw to be a child of the GET /challenge span")
+	}
+	if len(challenge.Links) != 1 || challenge.Links[0].SpanContext.SpanID() != index.SpanContext.SpanID() {
+		t.Errorf("Expected GET /challenge to link to the GET / span, got %v", challenge.Links)
+	}
+	for _, span := range []tracetest.SpanStub{index, challenge} {
+		found := false
+		for _, attr := range span.Attributes {
+			if attr.Key == ChallengeIDKey && attr.Value.AsString() == "challenge-1" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected %q to carry the challenge ID", span.Name)
+		}
+	}
+}
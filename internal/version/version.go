@@ -0,0 +1,75 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package version reports build information about the running binary.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/Litebrowsers/donatello/internal/tasks"
+)
+
+// Commit and BuildTime are set at build time:
+//
+//	go build -ldflags "-X github.com/Litebrowsers/donatello/internal/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/Litebrowsers/donatello/internal/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// When they are not set, the VCS revision and commit time embedded by the Go toolchain are used.
+var (
+	Commit    string
+	BuildTime string
+)
+
+// Info describes the running binary.
+type Info struct {
+	Commit            string `json:"commit"`
+	BuildTime         string `json:"build_time"`
+	Modified          bool   `json:"modified,omitempty"`
+	GoVersion         string `json:"go_version"`
+	TaskFormatVersion int    `json:"task_format_version"`
+}
+
+// Get returns the build information of the running binary.
+func Get() Info {
+	info := Info{
+		Commit:            Commit,
+		BuildTime:         BuildTime,
+		GoVersion:         runtime.Version(),
+		TaskFormatVersion: tasks.FormatVersion,
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+}
@@ -0,0 +1,192 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package web serves the embedded web resources.
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Litebrowsers/donatello/resources"
+	"github.com/gin-gonic/gin"
+)
+
+// Page templates.
+const (
+	IndexName  = "index.html"
+	VerifyName = "verify.html"
+	// DashboardName and DashboardChallengeName are the admin dashboard pages, see admin.Dashboard and
+	// admin.ChallengeView.
+	DashboardName          = "dashboard.html"
+	DashboardChallengeName = "dashboard_challenge.html"
+)
+
+// templatePattern matches the files parsed as html/template. All other files are served as they are.
+const templatePattern = "*.html"
+
+// Cache-Control values for static files. Files from an override directory are always revalidated.
+const (
+	cacheControlStatic   = "public, max-age=3600"
+	cacheControlOverride = "no-cache"
+)
+
+// IndexData is passed to the index page template.
+type IndexData struct {
+	ChallengeID string
+}
+
+// VerifyData is passed to the verify page template.
+type VerifyData struct {
+	ChallengeID string
+	// ReturnTo is the page the visitor is redirected to with the verdict token.
+	ReturnTo string
+}
+
+// asset is a static file and its entity tag.
+type asset struct {
+	content []byte
+	etag    string
+}
+
+// Assets serves the web resources. By default the embedded resources are parsed and hashed once. With an
+// override directory, files are read from disk on every request so changes show up without a restart.
+type Assets struct {
+	files     fs.FS
+	reload    bool
+	templates *template.Template
+	static    map[string]asset
+}
+
+// New creates Assets from the embedded resources, or from overrideDir if it is not empty.
+func New(overrideDir string) (*Assets, error) {
+	if overrideDir != "" {
+		a := &Assets{files: os.DirFS(overrideDir), reload: true}
+		// Fail early on a broken override directory
+		if _, err := a.parse(); err != nil {
+			return nil, err
+		}
+		return a, nil
+	}
+
+	a := &Assets{files: resources.FS, static: make(map[string]asset)}
+	templates, err := a.parse()
+	if err != nil {
+		return nil, err
+	}
+	a.templates = templates
+
+	err = fs.WalkDir(a.files, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if ok, _ := path.Match(templatePattern, name); ok {
+			return nil
+		}
+		a.static[name], err = a.read(name)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load resources: %w", err)
+	}
+	return a, nil
+}
+
+// Render writes the page template name for data to w.
+func (a *Assets) Render(w io.Writer, name string, data any) error {
+	templates := a.templates
+	if a.reload {
+		var err error
+		if templates, err = a.parse(); err != nil {
+			return err
+		}
+	}
+	return templates.ExecuteTemplate(w, name, data)
+}
+
+// Handler returns a gin.HandlerFunc that serves the static file name with an ETag and answers
+// conditional requests with 304.
+func (a *Assets) Handler(name, contentType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		file, err := a.file(name)
+		if err != nil {
+			c.String(http.StatusNotFound, "Not found")
+			return
+		}
+
+		c.Header("ETag", file.etag)
+		if a.reload {
+			c.Header("Cache-Control", cacheControlOverride)
+		} else {
+			c.Header("Cache-Control", cacheControlStatic)
+		}
+		if etagMatches(c.GetHeader("If-None-Match"), file.etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		c.Data(http.StatusOK, contentType, file.content)
+	}
+}
+
+// parse parses the page templates.
+func (a *Assets) parse() (*template.Template, error) {
+	templates, err := template.ParseFS(a.files, templatePattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse templates: %w", err)
+	}
+	if templates.Lookup(IndexName) == nil {
+		return nil, fmt.Errorf("%s is missing", IndexName)
+	}
+	return templates, nil
+}
+
+// file returns the static file name.
+func (a *Assets) file(name string) (asset, error) {
+	if a.reload {
+		return a.read(name)
+	}
+	file, ok := a.static[name]
+	if !ok {
+		return asset{}, fs.ErrNotExist
+	}
+	return file, nil
+}
+
+// read reads name and computes its entity tag.
+func (a *Assets) read(name string) (asset, error) {
+	content, err := fs.ReadFile(a.files, name)
+	if err != nil {
+		return asset{}, err
+	}
+	sum := sha256.Sum256(content)
+	return asset{content: content, etag: `"` + hex.EncodeToString(sum[:16]) + `"`}, nil
+}
+
+// etagMatches reports whether the If-None-Match header value matches etag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package web
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serve(handler gin.HandlerFunc, ifNoneMatch string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/file", handler)
+	req := httptest.NewRequest(http.MethodGet, "/file", nil)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAssets_Embedded(t *testing.T) {
+	assets, err := New("")
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	var page bytes.Buffer
+	if err := assets.Render(&page, IndexName, IndexData{ChallengeID: "challenge-1"}); err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	if !strings.Contains(page.String(), `"challenge-1"`) {
+		t.Error("Expected the rendered index page to contain the challenge ID")
+	}
+
+	rec := serve(assets.Handler("predictor.worker.js", "application/javascript"), "")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" || rec.Body.Len() == 0 {
+		t.Fatalf("Serving the worker failed. Expected 200 with an ETag, got %d %q", rec.Code, etag)
+	}
+	if rec.Header().Get("Cache-Control") != cacheControlStatic {
+		t.Errorf("Cache-Control failed. Expected %s, got %s", cacheControlStatic, rec.Header().Get("Cache-Control"))
+	}
+
+	rec = serve(assets.Handler("predictor.worker.js", "application/javascript"), `W/"other", `+etag)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("Conditional request failed. Expected %d, got %d", http.StatusNotModified, rec.Code)
+	}
+
+	if rec := serve(assets.Handler("missing.js", "application/javascript"), ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Serving a missing file failed. Expected %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestAssets_OverrideDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	write(IndexName, "<p>{{.ChallengeID}}</p>")
+	write("predictor.worker.js", "v1")
+
+	assets, err := New(dir)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	handler := assets.Handler("predictor.worker.js", "application/javascript")
+	first := serve(handler, "")
+
+	write(IndexName, "<div>{{.ChallengeID}}</div>")
+	write("predictor.worker.js", "v2")
+
+	second := serve(handler, first.Header().Get("ETag"))
+	if second.Code != http.StatusOK || second.Body.String() != "v2" {
+		t.Errorf("Hot reload failed. Expected 200 with v2, got %d %q", second.Code, second.Body.String())
+	}
+	if second.Header().Get("Cache-Control") != cacheControlOverride {
+		t.Errorf("Cache-Control failed. Expected %s, got %s", cacheControlOverride, second.Header().Get("Cache-Control"))
+	}
+
+	var page bytes.Buffer
+	if err := assets.Render(&page, IndexName, IndexData{ChallengeID: "<id>"}); err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	if page.String() != "<div>&lt;id&gt;</div>" {
+		t.Errorf("Render() failed. Expected the reloaded, escaped page, got %q", page.String())
+	}
+
+	if _, err := New(t.TempDir()); err == nil {
+		t.Error("Expected New() to fail for a directory without index.html")
+	}
+}
@@ -0,0 +1,58 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package challenge
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/Litebrowsers/donatello/internal/analysis"
+	"github.com/Litebrowsers/donatello/internal/tasks"
+)
+
+// checkConsistency renders the first task of c again and checks the valid diff of answer against it, see
+// analysis.CheckConsistency. It also returns the client's canvas rebuilt from the diff, or nil if the diff does not
+// reproduce the reported actual hash.
+func (s *Service) checkConsistency(c *Challenge, answer Answer, copyMismatch bool) ([]string, *tasks.Canvas, error) {
+	expected, err := tasks.Render(c.Task, s.cfg.CanvasSize, s.cfg.CanvasSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to render first task: %w", err)
+	}
+	reasons, err := analysis.CheckConsistency(expected, answer.Diff, answer.FirstTaskHash, answer.DiffTaskHash, copyMismatch)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check answer consistency: %w", err)
+	}
+	if slices.Contains(reasons, analysis.InconsistentActualHash) {
+		return reasons, nil, nil
+	}
+	rebuilt := expected.Clone()
+	if err := rebuilt.ApplyDiff(answer.Diff); err != nil {
+		return nil, nil, fmt.Errorf("failed to rebuild canvas: %w", err)
+	}
+	return reasons, rebuilt, nil
+}
+
+// localizeRebuilt locates the differences of the client's canvas rebuilt from its diff to the expected rendering of
+// the first task of c. Unlike the tile hashes reported by the client, the result is derived from the server's data.
+func localizeRebuilt(c *Challenge, rebuilt *tasks.Canvas) (analysis.Mismatch, error) {
+	channels, err := rebuilt.CalculateHashes()
+	if err != nil {
+		return analysis.Mismatch{}, fmt.Errorf("failed to hash rebuilt canvas: %w", err)
+	}
+	tiles, err := rebuilt.TileHashes(TileGrid)
+	if err != nil {
+		return analysis.Mismatch{}, fmt.Errorf("failed to hash rebuilt canvas: %w", err)
+	}
+	return analysis.LocalizeMismatch(splitChannels(c.ExpectedChannelHashes), channels, split(c.ExpectedTileHashes), tiles), nil
+}
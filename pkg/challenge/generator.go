@@ -0,0 +1,59 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package challenge
+
+import (
+	"math/rand"
+
+	"github.com/Litebrowsers/donatello/internal/tasks"
+)
+
+// Shape is a shape of a task.
+type Shape = tasks.Shape
+
+// Generator generates the shapes of challenge tasks.
+type Generator interface {
+	// FirstTask returns the shapes of a first task. It is drawn server-side to compute the expected hash.
+	FirstTask(canvasSize int) []Shape
+	// SecondTask returns the shapes of a new second task pool entry.
+	SecondTask(canvasSize int) []Shape
+}
+
+// RandomGenerator generates random tasks: a chessboard background with even sized primitives as first task and
+// arbitrary shapes as second task.
+type RandomGenerator struct {
+	// Rand is the source of the tasks. It is not safe for concurrent use, so the zero value uses the global source
+	// of math/rand instead.
+	Rand *rand.Rand
+}
+
+// FirstTask implements Generator.
+func (g RandomGenerator) FirstTask(canvasSize int) []Shape {
+	gridOptions := []int{2, 4, 10}
+	chessboardTask := tasks.Chessboard{
+		GridSize: gridOptions[tasks.Intn(g.Rand, len(gridOptions))],
+		Color1:   tasks.GenerateRandomColor(g.Rand),
+		Color2:   tasks.GenerateRandomColor(g.Rand),
+	}
+
+	numShapesFirstTask := tasks.Intn(g.Rand, 6) + 1
+	randomShapesFirstTask := tasks.GenerateRandomEvenSizedPrimitives(g.Rand, canvasSize, numShapesFirstTask)
+	return append([]Shape{chessboardTask}, randomShapesFirstTask...)
+}
+
+// SecondTask implements Generator.
+func (g RandomGenerator) SecondTask(canvasSize int) []Shape {
+	numShapesSecondTask := tasks.Intn(g.Rand, 6) + 1
+	return tasks.GenerateRandomShapes(g.Rand, canvasSize, numShapesSecondTask)
+}
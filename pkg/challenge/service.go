@@ -0,0 +1,492 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package challenge implements the Donatello challenge flow: a challenge is created, issued as two canvas tasks,
+// and answered with the hashes of the client's renderings, which yields a signed verdict.
+package challenge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/analysis"
+	"github.com/Litebrowsers/donatello/internal/clock"
+	"github.com/Litebrowsers/donatello/internal/features"
+	"github.com/Litebrowsers/donatello/internal/models"
+	"github.com/Litebrowsers/donatello/internal/risk"
+	"github.com/Litebrowsers/donatello/internal/tasks"
+	"github.com/Litebrowsers/donatello/internal/tracing"
+	"github.com/Litebrowsers/donatello/pkg/verdict"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// TileGrid is the number of rows and columns of tiles the first task is hashed in, see tasks.Canvas.TileHashes.
+const TileGrid = 4
+
+type (
+	// Challenge is a stored challenge.
+	Challenge = models.Challenge
+	// Task is a second task pool entry.
+	Task = models.Task
+	// Answer is a client's answer to a challenge.
+	Answer = models.ChallengeAnswer
+	// Clock tells the current time.
+	Clock = clock.Clock
+)
+
+// Config configures a Service.
+type Config struct {
+	// Expiration is the time a client has to answer a challenge.
+	Expiration time.Duration
+	// CanvasSize is the width and height of the challenge canvas.
+	CanvasSize int
+	// VerdictTTL is the validity of verdict tokens.
+	VerdictTTL time.Duration
+	// Generator generates the tasks and defaults to RandomGenerator.
+	Generator Generator
+	// Clock defaults to the system clock.
+	Clock Clock
+	// VisitorHistory is the number of previous sessions of a visitor the fingerprint is compared with and
+	// defaults to 10.
+	VisitorHistory int
+	// UploadMaxBytes is the largest canvas upload accepted with an answer. Uploads are ignored when zero.
+	UploadMaxBytes int
+}
+
+// Issued is a challenge with the tasks the client must draw.
+type Issued struct {
+	Challenge  *Challenge
+	FirstTask  string
+	SecondTask string
+	CanvasSize int
+	TileGrid   int
+	// UploadMaxBytes is the largest canvas upload the client may send, zero if uploads are disabled.
+	UploadMaxBytes int
+}
+
+// Result is the outcome of an answered challenge.
+type Result struct {
+	Challenge      *Challenge
+	HashMismatch   bool
+	ProcessingTime time.Duration
+	// TimingAnomalies are the reasons the client's timings look automated, see analysis.CheckTimings.
+	TimingAnomalies []string
+	// Mismatch locates the differences to the expected rendering of the first task.
+	Mismatch analysis.Mismatch
+	// UploadError is why the canvas upload was not stored, nil if it was stored or none was sent.
+	UploadError error
+	// Inconsistencies are the reasons the reported diff contradicts the rest of the answer, see
+	// analysis.CheckConsistency.
+	Inconsistencies []string
+	// Noise characterizes the reported diff. Its class is empty if the client did not report one.
+	Noise   models.NoiseProfile
+	Verdict verdict.Verdict
+	Token   string
+}
+
+// Service runs the challenge flow.
+type Service struct {
+	store Store
+	key   *verdict.Key
+	cfg   Config
+}
+
+// NewService creates a new Service. Verdicts are signed with key.
+func NewService(store Store, key *verdict.Key, cfg Config) *Service {
+	if cfg.Generator == nil {
+		cfg.Generator = RandomGenerator{}
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Real{}
+	}
+	if cfg.VisitorHistory <= 0 {
+		cfg.VisitorHistory = 10
+	}
+	return &Service{store: store, key: key, cfg: cfg}
+}
+
+// Create stores a new challenge. The trace context of ctx is recorded so later requests can link to it, as is the
+// visitor added with WithVisitor.
+func (s *Service) Create(ctx context.Context) (*Challenge, error) {
+	now := s.cfg.Clock.Now()
+	challenge := &Challenge{
+		ID:        uuid.NewString(),
+		ExpiresAt: now.Add(s.cfg.Expiration),
+	}
+	// CreatedAt is set explicitly so the processing time is measured on the same clock
+	challenge.CreatedAt = now
+	tracing.LinkChallenge(ctx, challenge.ID, "")
+	challenge.TraceParent = tracing.TraceParent(ctx)
+	challenge.VisitorID = VisitorFromContext(ctx)
+
+	if err := s.store.CreateChallenge(ctx, challenge); err != nil {
+		return nil, fmt.Errorf("failed to create challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// Issue generates the tasks for challenge id, stores their expected hashes and returns them. It returns ErrExpired
+// if the challenge expired and ErrAlreadyAnswered if it was answered.
+func (s *Service) Issue(ctx context.Context, id string) (*Issued, error) {
+	challenge, err := s.store.GetChallenge(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	tracing.LinkChallenge(ctx, challenge.ID, challenge.TraceParent)
+	issuedAt := s.cfg.Clock.Now()
+	if challenge.Expired(issuedAt) {
+		return nil, ErrExpired
+	}
+	if challenge.AnsweredAt != nil {
+		return nil, ErrAlreadyAnswered
+	}
+
+	_, generateSpan := tracing.Start(ctx, "tasks.generate")
+	allShapes := s.cfg.Generator.FirstTask(s.cfg.CanvasSize)
+	firstTask := tasks.NewTaskGenerator(allShapes...).GenerateTask()
+	profile := tasks.Profile(allShapes)
+	generateSpan.SetAttributes(attribute.String("donatello.profile", profile))
+	generateSpan.End()
+
+	// Server-side drawing
+	first, err := s.render(ctx, allShapes, "first")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render first task: %w", err)
+	}
+
+	secondTask, err := s.EnsureSecondTask(ctx)
+	if err != nil {
+		return nil, err
+	}
+	secondTaskShapes, err := tasks.ParseTask(secondTask.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse second task: %w", err)
+	}
+	second, err := s.render(ctx, secondTaskShapes, "second")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render second task: %w", err)
+	}
+
+	challenge.Task = firstTask
+	challenge.Profile = profile
+	challenge.SecondTaskID = secondTask.ID
+	challenge.ExpectedHash = first.combined
+	challenge.ExpectedChannelHashes = joinChannels(first.channels)
+	challenge.ExpectedTileHashes = strings.Join(first.tiles, ",")
+	challenge.Fingerprint = second.combined
+	challenge.IssuedAt = &issuedAt
+
+	saveCtx, saveSpan := tracing.Start(ctx, "db.save")
+	err = s.store.SaveIssued(saveCtx, challenge)
+	if err != nil {
+		saveSpan.SetStatus(codes.Error, err.Error())
+	}
+	saveSpan.End()
+	if err != nil {
+		return nil, fmt.Errorf("failed to save challenge: %w", err)
+	}
+
+	return &Issued{
+		Challenge:      challenge,
+		FirstTask:      firstTask,
+		SecondTask:     secondTask.Value,
+		CanvasSize:     s.cfg.CanvasSize,
+		TileGrid:       TileGrid,
+		UploadMaxBytes: s.cfg.UploadMaxBytes,
+	}, nil
+}
+
+// Answer checks answer against its challenge, stores it and signs the verdict. It returns ErrExpired if the
+// challenge expired, ErrNotIssued if its tasks were never issued, ErrAlreadyAnswered if it was answered before and
+// ErrInvalidAnswer if the feature vector is missing or invalid. An invalid canvas upload is reported in the result
+// instead.
+func (s *Service) Answer(ctx context.Context, answer Answer) (*Result, error) {
+	challenge, err := s.store.GetChallenge(ctx, answer.ID)
+	if err != nil {
+		return nil, err
+	}
+	tracing.LinkChallenge(ctx, challenge.ID, challenge.TraceParent)
+
+	now := s.cfg.Clock.Now()
+	if challenge.Expired(now) {
+		return nil, ErrExpired
+	}
+	// A replayed answer would get a fresh verdict
+	if challenge.AnsweredAt != nil {
+		return nil, ErrAlreadyAnswered
+	}
+	if challenge.IssuedAt == nil {
+		return nil, ErrNotIssued
+	}
+	vector, err := answerFeatures(answer)
+	if err != nil {
+		return nil, err
+	}
+	processingTime := now.Sub(challenge.CreatedAt)
+	hashMismatch := challenge.ExpectedHash != answer.FirstTaskHash
+	copyMismatch := answer.CopyMismatch != nil && *answer.CopyMismatch
+	noiseDetect := hashMismatch && copyMismatch
+	javaScript := true
+
+	// Answers without timings come from older SDK versions and are not penalized
+	var timingAnomalies []string
+	if answer.Timings != nil {
+		timingAnomalies = analysis.CheckTimings(*answer.Timings, *challenge.IssuedAt, now)
+	}
+
+	challenge.NoiseDetected = noiseDetect
+	challenge.ActualHash = answer.FirstTaskHash
+	challenge.Fingerprint = answer.SecondTaskHash
+	challenge.Metrics = answer.SecondTaskMetrics
+	challenge.Features = features.Rows(challenge.ID, vector)
+	challenge.ProcessingTime = processingTime.Milliseconds()
+	challenge.CopyMismatch = answer.CopyMismatch
+	challenge.JavaScript = &javaScript
+	challenge.NoiseHash = answer.DiffTaskHash
+	challenge.AnsweredAt = &now
+	if answer.Timings != nil {
+		challenge.Timings = *answer.Timings
+	}
+	challenge.TimingAnomalies = strings.Join(timingAnomalies, ",")
+
+	var (
+		inconsistencies []string
+		rebuilt         *tasks.Canvas
+	)
+	diffChecked := false
+	if answer.Diff != nil {
+		challenge.Noise = analysis.AnalyzeNoise(answer.Diff, s.cfg.CanvasSize, s.cfg.CanvasSize, TileGrid)
+		// The diff is checked against the server's rendering of the first task
+		if challenge.Noise.Class != analysis.NoiseInvalid {
+			challenge.Diff = joinInts(answer.Diff)
+			if inconsistencies, rebuilt, err = s.checkConsistency(challenge, answer, copyMismatch); err != nil {
+				return nil, err
+			}
+			diffChecked = true
+		}
+		if challenge.Noise.Pattern != "" {
+			seeded, err := s.store.NoisePatternSeen(ctx, challenge.Noise.Pattern, challenge.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up noise pattern: %w", err)
+			}
+			challenge.Noise.Seeded = seeded
+		}
+	}
+
+	// Omitting the diff must not evade the checks of the values it would contradict
+	if hashMismatch && !diffChecked && (answer.DiffTaskHash != nil || copyMismatch) {
+		inconsistencies = analysis.CheckMissingDiff(answer.Diff, answer.DiffTruncated, s.cfg.CanvasSize*s.cfg.CanvasSize)
+	}
+
+	// The client's tile hashes are not checked, so a localized mismatch only counts once a consistent diff confirms it
+	mismatch := analysis.Mismatch{Scope: analysis.ScopeNone}
+	corroborated := false
+	if hashMismatch && rebuilt != nil {
+		if mismatch, err = localizeRebuilt(challenge, rebuilt); err != nil {
+			return nil, err
+		}
+		corroborated = true
+	} else if hashMismatch {
+		mismatch = analysis.LocalizeMismatch(splitChannels(challenge.ExpectedChannelHashes),
+			answerChannels(answer.ChannelHashes), split(challenge.ExpectedTileHashes), answer.TileHashes)
+	}
+	challenge.MismatchScope = mismatch.Scope
+	challenge.MismatchChannels = strings.Join(mismatch.Channels, ",")
+	challenge.MismatchTiles = joinInts(mismatch.Tiles)
+
+	var uploadErr error
+	if answer.Canvas != "" && s.cfg.UploadMaxBytes > 0 {
+		challenge.Upload, err = s.checkUpload(challenge, answer.Canvas, answer.FirstTaskHash)
+		if errors.Is(err, errInvalidUpload) {
+			uploadErr = err
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	if challenge.VisitorID != "" {
+		previous, err := s.store.VisitorHistory(ctx, challenge.VisitorID, challenge.ID, s.cfg.VisitorHistory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load visitor history: %w", err)
+		}
+		challenge.Stability = analysis.CheckStability(challenge, previous)
+	}
+
+	challenge.Inconsistencies = strings.Join(inconsistencies, ",")
+
+	challenge.RiskScore = risk.Score(risk.Signals{
+		// A mismatch confined to a few tiles points to a rendering bug rather than tampering
+		HashMismatch:          hashMismatch && !(corroborated && mismatch.Scope == analysis.ScopeLocalized),
+		NoiseDetected:         noiseDetect,
+		CopyMismatch:          copyMismatch,
+		TimingAnomaly:         len(timingAnomalies) > 0,
+		FingerprintRandomized: challenge.Stability.Randomized,
+		Inconsistent:          len(inconsistencies) > 0,
+	})
+
+	if err := s.store.SaveAnswer(ctx, challenge); err != nil {
+		return nil, fmt.Errorf("failed to save answer: %w", err)
+	}
+
+	v := verdict.New(challenge.ID, noiseDetect, challenge.RiskScore, now, s.cfg.VerdictTTL)
+	token, err := s.key.Sign(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign verdict: %w", err)
+	}
+
+	return &Result{
+		Challenge:       challenge,
+		HashMismatch:    hashMismatch,
+		ProcessingTime:  processingTime,
+		TimingAnomalies: timingAnomalies,
+		Mismatch:        mismatch,
+		UploadError:     uploadErr,
+		Inconsistencies: inconsistencies,
+		Noise:           challenge.Noise,
+		Verdict:         v,
+		Token:           token,
+	}, nil
+}
+
+// answerFeatures returns the validated feature vector of answer. Older SDK versions only send the legacy metrics2
+// array, which is converted to the alpha channel.
+func answerFeatures(answer Answer) (models.FeatureVector, error) {
+	var (
+		vector models.FeatureVector
+		err    error
+	)
+	switch {
+	case answer.Features != nil:
+		vector, err = *answer.Features, features.Validate(*answer.Features)
+	case answer.SecondTaskMetrics != "":
+		vector, err = features.FromLegacy(answer.SecondTaskMetrics)
+	default:
+		return vector, fmt.Errorf("%w: missing feature vector", ErrInvalidAnswer)
+	}
+	if err != nil {
+		return vector, fmt.Errorf("%w: %w", ErrInvalidAnswer, err)
+	}
+	return vector, nil
+}
+
+// EnsureSecondTask returns the second task, creating it if the pool is empty.
+func (s *Service) EnsureSecondTask(ctx context.Context) (*Task, error) {
+	secondTask, err := s.store.SecondTask(ctx)
+	if err == nil {
+		return secondTask, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("failed to load second task: %w", err)
+	}
+
+	secondTaskShapes := s.cfg.Generator.SecondTask(s.cfg.CanvasSize)
+	secondTask = &Task{Value: tasks.NewTaskGenerator(secondTaskShapes...).GenerateTask()}
+	if err := s.store.CreateSecondTask(ctx, secondTask); err != nil {
+		return nil, fmt.Errorf("failed to create second task: %w", err)
+	}
+	return secondTask, nil
+}
+
+// rendering holds the hashes of a server-side rendering.
+type rendering struct {
+	combined string
+	channels map[string]string
+	tiles    []string
+}
+
+// render draws shapes on a new canvas and hashes it, tracing both steps.
+func (s *Service) render(ctx context.Context, shapes []tasks.Shape, task string) (*rendering, error) {
+	taskAttr := attribute.String("donatello.task", task)
+
+	_, drawSpan := tracing.Start(ctx, "canvas.draw", taskAttr, attribute.Int("donatello.shapes", len(shapes)))
+	canvas := tasks.NewCanvas(s.cfg.CanvasSize, s.cfg.CanvasSize)
+	err := canvas.DrawShapes(shapes)
+	if err != nil {
+		drawSpan.SetStatus(codes.Error, err.Error())
+	}
+	drawSpan.End()
+	if err != nil {
+		return nil, err
+	}
+
+	_, hashSpan := tracing.Start(ctx, "canvas.hash", taskAttr)
+	defer hashSpan.End()
+	hashes, err := canvas.CalculateHashes()
+	if err != nil {
+		hashSpan.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	combinedHash, err := canvas.CalculateCombinedHash(hashes)
+	if err != nil {
+		hashSpan.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	tiles, err := canvas.TileHashes(TileGrid)
+	if err != nil {
+		hashSpan.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return &rendering{combined: combinedHash, channels: hashes, tiles: tiles}, nil
+}
+
+// joinChannels joins channel hashes in the order of analysis.Channels.
+func joinChannels(hashes map[string]string) string {
+	values := make([]string, len(analysis.Channels))
+	for i, channel := range analysis.Channels {
+		values[i] = hashes[channel]
+	}
+	return strings.Join(values, ",")
+}
+
+// splitChannels reverses joinChannels.
+func splitChannels(joined string) map[string]string {
+	values := split(joined)
+	hashes := make(map[string]string, len(values))
+	for i, value := range values {
+		if i < len(analysis.Channels) {
+			hashes[analysis.Channels[i]] = value
+		}
+	}
+	return hashes
+}
+
+// answerChannels returns the channel hashes reported by the client keyed like analysis.Channels.
+func answerChannels(hashes *models.ChannelHashes) map[string]string {
+	if hashes == nil {
+		return nil
+	}
+	return map[string]string{"red": hashes.R, "green": hashes.G, "blue": hashes.B, "alpha": hashes.A}
+}
+
+// split splits a comma separated list, returning nil for an empty one.
+func split(joined string) []string {
+	if joined == "" {
+		return nil
+	}
+	return strings.Split(joined, ",")
+}
+
+// joinInts joins values with commas.
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
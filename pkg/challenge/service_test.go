@@ -0,0 +1,740 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package challenge
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Litebrowsers/donatello/internal/analysis"
+	"github.com/Litebrowsers/donatello/internal/clock"
+	"github.com/Litebrowsers/donatello/internal/features"
+	"github.com/Litebrowsers/donatello/internal/models"
+	"github.com/Litebrowsers/donatello/internal/tasks"
+	"github.com/Litebrowsers/donatello/pkg/verdict"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestService(t *testing.T, clk Clock) (*Service, *verdict.Key, *GormStore) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	key, err := verdict.NewKey([]byte(strings.Repeat("k", verdict.MinSecretLength)))
+	if err != nil {
+		t.Fatalf("NewKey() failed: %v", err)
+	}
+	store := NewGormStore(db)
+	return NewService(store, key, Config{
+		Expiration: time.Minute,
+		CanvasSize: 20,
+		VerdictTTL: time.Hour,
+		Clock:      clk,
+	}), key, store
+}
+
+// testFeatures returns a valid feature vector of a uniform canvas.
+func testFeatures() *models.FeatureVector {
+	channel := features.Compute(make([]byte, 4), 2)
+	return &models.FeatureVector{Version: features.Version, Channels: map[string]models.ChannelFeatures{
+		"r": channel, "g": channel, "b": channel, "a": channel,
+	}}
+}
+
+func TestService_Flow(t *testing.T) {
+	service, key, store := newTestService(t, nil)
+	ctx := context.Background()
+
+	created, err := service.Create(ctx)
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	issued, err := service.Issue(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Issue() failed: %v", err)
+	}
+	if issued.FirstTask == "" || issued.SecondTask == "" || issued.CanvasSize != 20 {
+		t.Fatalf("Issue() returned incomplete tasks: %+v", issued)
+	}
+	stored, err := store.GetChallenge(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetChallenge() failed: %v", err)
+	}
+	if stored.Task != issued.FirstTask || stored.ExpectedHash == "" || stored.Fingerprint == "" {
+		t.Errorf("Issue() did not store the expected hashes: %+v", stored)
+	}
+
+	// The answer reproduces the expected hash, so the client is clean
+	copyMismatch := false
+	result, err := service.Answer(ctx, Answer{
+		ID:             created.ID,
+		FirstTaskHash:  stored.ExpectedHash,
+		SecondTaskHash: "fingerprint",
+		Features:       testFeatures(),
+		CopyMismatch:   &copyMismatch,
+	})
+	if err != nil {
+		t.Fatalf("Answer() failed: %v", err)
+	}
+	if result.HashMismatch || result.Challenge.NoiseDetected || result.Challenge.RiskScore != 0 {
+		t.Errorf("Answer() failed. Expected a clean result, got %+v", result.Challenge)
+	}
+
+	v, err := key.Verify(result.Token, time.Now())
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if v.ChallengeID != created.ID || v.NoiseDetected {
+		t.Errorf("Unexpected verdict %+v", v)
+	}
+
+	stored, _ = store.GetChallenge(ctx, created.ID)
+	if stored.JavaScript == nil || !*stored.JavaScript || stored.Fingerprint != "fingerprint" {
+		t.Errorf("Answer() did not store the answer: %+v", stored)
+	}
+}
+
+func TestService_Noise(t *testing.T) {
+	service, _, _ := newTestService(t, nil)
+	ctx := context.Background()
+
+	created, _ := service.Create(ctx)
+	if _, err := service.Issue(ctx, created.ID); err != nil {
+		t.Fatalf("Issue() failed: %v", err)
+	}
+	copyMismatch := true
+	result, err := service.Answer(ctx, Answer{
+		ID:             created.ID,
+		FirstTaskHash:  "noisy",
+		SecondTaskHash: "fingerprint",
+		Features:       testFeatures(),
+		CopyMismatch:   &copyMismatch,
+	})
+	if err != nil {
+		t.Fatalf("Answer() failed: %v", err)
+	}
+	if !result.Challenge.NoiseDetected || !result.Verdict.NoiseDetected || result.Verdict.RiskScore != 1 {
+		t.Errorf("Answer() failed. Expected noise with risk 1, got %+v", result.Verdict)
+	}
+}
+
+func TestService_NotFound(t *testing.T) {
+	service, _, _ := newTestService(t, nil)
+	if _, err := service.Issue(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Issue() failed. Expected %v, got %v", ErrNotFound, err)
+	}
+	if _, err := service.Answer(context.Background(), Answer{ID: "missing"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Answer() failed. Expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func TestService_Replay(t *testing.T) {
+	service, _, store := newTestService(t, nil)
+	ctx := context.Background()
+
+	created, _ := service.Create(ctx)
+	answer := Answer{ID: created.ID, FirstTaskHash: "hash", SecondTaskHash: "fingerprint", Features: testFeatures()}
+
+	// A challenge that was never issued has nothing to compare the answer with
+	if _, err := service.Answer(ctx, answer); !errors.Is(err, ErrNotIssued) {
+		t.Errorf("Answer() before Issue() failed. Expected %v, got %v", ErrNotIssued, err)
+	}
+
+	issued, err := service.Issue(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Issue() failed: %v", err)
+	}
+	first, err := service.Answer(ctx, answer)
+	if err != nil {
+		t.Fatalf("Answer() failed: %v", err)
+	}
+
+	// Replaying the same answer must not yield another verdict
+	if _, err := service.Answer(ctx, answer); !errors.Is(err, ErrAlreadyAnswered) {
+		t.Errorf("Replayed Answer() failed. Expected %v, got %v", ErrAlreadyAnswered, err)
+	}
+	if _, err := service.Issue(ctx, created.ID); !errors.Is(err, ErrAlreadyAnswered) {
+		t.Errorf("Issue() after Answer() failed. Expected %v, got %v", ErrAlreadyAnswered, err)
+	}
+
+	// An answer that loaded the challenge before the first one was saved loses the race
+	if err := store.SaveAnswer(ctx, first.Challenge); !errors.Is(err, ErrAlreadyAnswered) {
+		t.Errorf("SaveAnswer() of a concurrent answer failed. Expected %v, got %v", ErrAlreadyAnswered, err)
+	}
+
+	// An issue that loaded the challenge before the answer was saved must not overwrite it
+	if err := store.SaveIssued(ctx, issued.Challenge); !errors.Is(err, ErrAlreadyAnswered) {
+		t.Errorf("SaveIssued() of a concurrent issue failed. Expected %v, got %v", ErrAlreadyAnswered, err)
+	}
+	stored, err := store.GetChallenge(ctx, created.ID)
+	if err != nil || stored.AnsweredAt == nil || stored.ActualHash != "hash" {
+		t.Errorf("Expected the answer to survive a concurrent issue, got %+v, %v", stored, err)
+	}
+}
+
+func TestService_Expiry(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	service, _, _ := newTestService(t, fake)
+	ctx := context.Background()
+
+	answer := func(id string) (*Result, error) {
+		return service.Answer(ctx, Answer{ID: id, FirstTaskHash: "hash", SecondTaskHash: "fingerprint", Features: testFeatures()})
+	}
+
+	created, err := service.Create(ctx)
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if !created.ExpiresAt.Equal(start.Add(time.Minute)) {
+		t.Errorf("Create() failed. Expected expiry %v, got %v", start.Add(time.Minute), created.ExpiresAt)
+	}
+
+	// Exactly at ExpiresAt the challenge is still valid
+	fake.Set(created.ExpiresAt)
+	if _, err := service.Issue(ctx, created.ID); err != nil {
+		t.Fatalf("Issue() at ExpiresAt failed: %v", err)
+	}
+	result, err := answer(created.ID)
+	if err != nil {
+		t.Fatalf("Answer() at ExpiresAt failed: %v", err)
+	}
+	if result.ProcessingTime != time.Minute || result.Challenge.ProcessingTime != time.Minute.Milliseconds() {
+		t.Errorf("ProcessingTime failed. Expected %v, got %v", time.Minute, result.ProcessingTime)
+	}
+	if result.Verdict.ExpiresAt != fake.Now().Add(time.Hour).Unix() {
+		t.Errorf("Verdict expiry failed. Expected %v, got %v", fake.Now().Add(time.Hour).Unix(), result.Verdict.ExpiresAt)
+	}
+
+	// One nanosecond later it is expired
+	expired, _ := service.Create(ctx)
+	fake.Set(expired.ExpiresAt.Add(time.Nanosecond))
+	if _, err := service.Issue(ctx, expired.ID); !errors.Is(err, ErrExpired) {
+		t.Errorf("Issue() failed. Expected %v, got %v", ErrExpired, err)
+	}
+	if _, err := answer(expired.ID); !errors.Is(err, ErrExpired) {
+		t.Errorf("Answer() failed. Expected %v, got %v", ErrExpired, err)
+	}
+}
+
+func TestService_Timings(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	service, _, store := newTestService(t, fake)
+	ctx := context.Background()
+
+	answerAfter := func(window time.Duration) *Result {
+		t.Helper()
+		created, _ := service.Create(ctx)
+		issued, err := service.Issue(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Issue() failed: %v", err)
+		}
+		fake.Advance(window)
+		copyMismatch := false
+		result, err := service.Answer(ctx, Answer{
+			ID:             created.ID,
+			FirstTaskHash:  issued.Challenge.ExpectedHash,
+			SecondTaskHash: "fingerprint",
+			Features:       testFeatures(),
+			CopyMismatch:   &copyMismatch,
+			Timings:        &models.Timings{Fetch: 42.3, Draw: 3.1, GetImageData: 0.8, Hash: 2.4, CopyTest: 11.7},
+		})
+		if err != nil {
+			t.Fatalf("Answer() failed: %v", err)
+		}
+		return result
+	}
+
+	result := answerAfter(60 * time.Millisecond)
+	if len(result.TimingAnomalies) != 0 || result.Challenge.RiskScore != 0 {
+		t.Errorf("Expected plausible timings to be clean, got %v with risk %v", result.TimingAnomalies, result.Challenge.RiskScore)
+	}
+	stored, _ := store.GetChallenge(ctx, result.Challenge.ID)
+	if stored.Timings.CopyTest != 11.7 || stored.IssuedAt == nil || stored.AnsweredAt == nil ||
+		stored.AnsweredAt.Sub(*stored.IssuedAt) != 60*time.Millisecond {
+		t.Errorf("Answer() did not store the timings: %+v", stored)
+	}
+
+	result = answerAfter(2 * time.Millisecond)
+	if stored, _ := store.GetChallenge(ctx, result.Challenge.ID); stored.TimingAnomalies != "too_fast,inconsistent" {
+		t.Errorf("Expected the anomalies to be stored, got %q", stored.TimingAnomalies)
+	}
+	if result.Verdict.RiskScore != 0.3 {
+		t.Errorf("Expected the timing anomaly to raise the risk to 0.3, got %v", result.Verdict.RiskScore)
+	}
+}
+
+func TestService_MismatchLocalization(t *testing.T) {
+	service, _, store := newTestService(t, nil)
+	ctx := context.Background()
+
+	answerWithTiles := func(alter func(tiles []string)) *Result {
+		t.Helper()
+		created, _ := service.Create(ctx)
+		issued, err := service.Issue(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Issue() failed: %v", err)
+		}
+		if issued.TileGrid != TileGrid {
+			t.Errorf("Issue() failed. Expected tile grid %d, got %d", TileGrid, issued.TileGrid)
+		}
+		tiles := strings.Split(issued.Challenge.ExpectedTileHashes, ",")
+		if len(tiles) != TileGrid*TileGrid {
+			t.Fatalf("Expected %d tile hashes, got %d", TileGrid*TileGrid, len(tiles))
+		}
+		alter(tiles)
+		channels := strings.Split(issued.Challenge.ExpectedChannelHashes, ",")
+		result, err := service.Answer(ctx, Answer{
+			ID:             created.ID,
+			FirstTaskHash:  "mismatch",
+			SecondTaskHash: "fingerprint",
+			Features:       testFeatures(),
+			ChannelHashes:  &models.ChannelHashes{R: "changed", G: channels[1], B: channels[2], A: channels[3]},
+			TileHashes:     tiles,
+		})
+		if err != nil {
+			t.Fatalf("Answer() failed: %v", err)
+		}
+		return result
+	}
+
+	// The client's tile hashes alone can't lower the risk, they are not checked
+	result := answerWithTiles(func(tiles []string) { tiles[5] = "changed" })
+	if result.Mismatch.Scope != "localized" || result.Challenge.RiskScore != 0.2 {
+		t.Errorf("Expected an uncorroborated localized mismatch with risk 0.2, got %+v with risk %v", result.Mismatch, result.Challenge.RiskScore)
+	}
+	stored, _ := store.GetChallenge(ctx, result.Challenge.ID)
+	if stored.MismatchScope != "localized" || stored.MismatchChannels != "red" || stored.MismatchTiles != "5" {
+		t.Errorf("Answer() did not store the mismatch: %q %q %q", stored.MismatchScope, stored.MismatchChannels, stored.MismatchTiles)
+	}
+
+	// A consistent diff confined to one tile confirms the localization, whatever tile hashes the client reports
+	created, _ := service.Create(ctx)
+	issued, err := service.Issue(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Issue() failed: %v", err)
+	}
+	diff := []int{0, 0, 0, 0, -1}
+	actual, _ := tasks.Render(issued.FirstTask, 20, 20)
+	if err := actual.ApplyDiff(diff); err != nil {
+		t.Fatalf("ApplyDiff() failed: %v", err)
+	}
+	hashes, _ := actual.CalculateHashes()
+	hash, _ := actual.CalculateCombinedHash(hashes)
+	result, err = service.Answer(ctx, Answer{
+		ID:             created.ID,
+		FirstTaskHash:  hash,
+		SecondTaskHash: "fingerprint",
+		Features:       testFeatures(),
+		TileHashes:     []string{"changed"},
+		Diff:           diff,
+	})
+	if err != nil {
+		t.Fatalf("Answer() failed: %v", err)
+	}
+	if result.Mismatch.Scope != "localized" || !slices.Equal(result.Mismatch.Tiles, []int{0}) || result.Challenge.RiskScore != 0 {
+		t.Errorf("Expected a corroborated localized mismatch without risk, got %+v with risk %v", result.Mismatch, result.Challenge.RiskScore)
+	}
+
+	result = answerWithTiles(func(tiles []string) {
+		for i := range tiles {
+			tiles[i] = "changed"
+		}
+	})
+	if result.Mismatch.Scope != "global" || result.Challenge.RiskScore != 0.2 {
+		t.Errorf("Expected a global mismatch with risk 0.2, got %+v with risk %v", result.Mismatch, result.Challenge.RiskScore)
+	}
+}
+
+func TestService_Noise_Seeded(t *testing.T) {
+	service, _, store := newTestService(t, nil)
+	ctx := context.Background()
+
+	// Farbling with the same seed changes the same pixels in the same direction in every challenge
+	answer := func() *Result {
+		t.Helper()
+		created, _ := service.Create(ctx)
+		if _, err := service.Issue(ctx, created.ID); err != nil {
+			t.Fatalf("Issue() failed: %v", err)
+		}
+		result, err := service.Answer(ctx, Answer{
+			ID:             created.ID,
+			FirstTaskHash:  "farbled",
+			SecondTaskHash: "fingerprint",
+			Features:       testFeatures(),
+			Diff:           []int{3, 1, 0, 0, 0, 57, 0, -1, 0, 0, 210, 0, 0, 1, 0},
+		})
+		if err != nil {
+			t.Fatalf("Answer() failed: %v", err)
+		}
+		return result
+	}
+
+	first := answer()
+	if first.Noise.Class != "farbling" || first.Noise.Seeded {
+		t.Errorf("Expected unseeded farbling on the first answer, got %+v", first.Noise)
+	}
+	second := answer()
+	if !second.Noise.Seeded {
+		t.Errorf("Expected the repeated pattern to be seeded, got %+v", second.Noise)
+	}
+	stored, _ := store.GetChallenge(ctx, second.Challenge.ID)
+	if stored.Noise.Class != "farbling" || stored.Noise.Pixels != 3 || !stored.Noise.Seeded || stored.Noise.Pattern != first.Noise.Pattern {
+		t.Errorf("Answer() did not store the noise profile: %+v", stored.Noise)
+	}
+	if stored.Diff != "3,1,0,0,0,57,0,-1,0,0,210,0,0,1,0" {
+		t.Errorf("Answer() did not store the diff: %q", stored.Diff)
+	}
+}
+
+func TestService_Consistency(t *testing.T) {
+	service, _, store := newTestService(t, nil)
+	ctx := context.Background()
+
+	// answer answers a new challenge with diff and returns the result and the client's canvas
+	answer := func(diff []int, diffHash func(expected, actual *tasks.Canvas) string, copyMismatch bool) *Result {
+		t.Helper()
+		created, _ := service.Create(ctx)
+		issued, err := service.Issue(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Issue() failed: %v", err)
+		}
+		expected, err := tasks.Render(issued.FirstTask, 20, 20)
+		if err != nil {
+			t.Fatalf("Render() failed: %v", err)
+		}
+		actual := expected.Clone()
+		if err := actual.ApplyDiff(diff); err != nil {
+			t.Fatalf("ApplyDiff() failed: %v", err)
+		}
+		hashes, _ := actual.CalculateHashes()
+		hash, _ := actual.CalculateCombinedHash(hashes)
+		reported := diffHash(expected, actual)
+		result, err := service.Answer(ctx, Answer{
+			ID:             created.ID,
+			FirstTaskHash:  hash,
+			SecondTaskHash: "fingerprint",
+			Features:       testFeatures(),
+			Diff:           diff,
+			DiffTaskHash:   &reported,
+			CopyMismatch:   &copyMismatch,
+		})
+		if err != nil {
+			t.Fatalf("Answer() failed: %v", err)
+		}
+		return result
+	}
+	fingerprint := func(expected, actual *tasks.Canvas) string {
+		hash, _ := tasks.NoiseFingerprint(expected, actual)
+		return hash
+	}
+	forged := func(*tasks.Canvas, *tasks.Canvas) string { return "forged" }
+
+	// The chessboard covers the canvas, so every alpha value is 255
+	diff := []int{0, 0, 0, 0, -1, 21, 0, 0, 0, -2}
+	if result := answer(diff, fingerprint, true); len(result.Inconsistencies) != 0 {
+		t.Errorf("Expected a rendered diff to be consistent, got %v", result.Inconsistencies)
+	}
+
+	result := answer(diff, forged, true)
+	if !slices.Equal(result.Inconsistencies, []string{analysis.InconsistentDiffHash}) || result.Challenge.RiskScore < 0.5 {
+		t.Errorf("Expected a forged diff hash to be detected, got %v with risk %v", result.Inconsistencies, result.Challenge.RiskScore)
+	}
+	if stored, _ := store.GetChallenge(ctx, result.Challenge.ID); stored.Inconsistencies != analysis.InconsistentDiffHash {
+		t.Errorf("Expected the inconsistencies to be stored, got %q", stored.Inconsistencies)
+	}
+
+	result = answer([]int{}, fingerprint, true)
+	if !slices.Equal(result.Inconsistencies, []string{analysis.InconsistentCopy}) {
+		t.Errorf("Expected a copy mismatch of an exact rendering to be detected, got %v", result.Inconsistencies)
+	}
+}
+
+func TestService_Consistency_MissingDiff(t *testing.T) {
+	service, _, _ := newTestService(t, nil)
+	ctx := context.Background()
+
+	// answer answers a new challenge with a differing rendering, a forged diff hash and a copy mismatch
+	answer := func(diff []int, truncated bool) *Result {
+		t.Helper()
+		created, _ := service.Create(ctx)
+		if _, err := service.Issue(ctx, created.ID); err != nil {
+			t.Fatalf("Issue() failed: %v", err)
+		}
+		forged, copyMismatch := "forged", true
+		result, err := service.Answer(ctx, Answer{
+			ID:             created.ID,
+			FirstTaskHash:  "differs",
+			SecondTaskHash: "fingerprint",
+			Features:       testFeatures(),
+			Diff:           diff,
+			DiffTruncated:  truncated,
+			DiffTaskHash:   &forged,
+			CopyMismatch:   &copyMismatch,
+		})
+		if err != nil {
+			t.Fatalf("Answer() failed: %v", err)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name      string
+		diff      []int
+		truncated bool
+		expected  []string
+	}{
+		{name: "omitted diff", expected: []string{analysis.InconsistentMissingDiff}},
+		{name: "invalid diff", diff: []int{1}, expected: []string{analysis.InconsistentInvalidDiff}},
+		// A 20×20 canvas can't have more differing pixels than a diff may list
+		{name: "truncated diff", truncated: true, expected: []string{analysis.InconsistentMissingDiff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := answer(tt.diff, tt.truncated)
+			if !slices.Equal(result.Inconsistencies, tt.expected) {
+				t.Errorf("Answer() failed. Expected %v, got %v", tt.expected, result.Inconsistencies)
+			}
+		})
+	}
+
+	// On a large canvas the SDK legitimately sends no diff when too many pixels differ
+	service.cfg.CanvasSize = 65
+	if result := answer(nil, true); len(result.Inconsistencies) != 0 {
+		t.Errorf("Expected a truncated diff of a large canvas to be accepted, got %v", result.Inconsistencies)
+	}
+}
+
+func TestService_Features(t *testing.T) {
+	service, _, store := newTestService(t, nil)
+	ctx := context.Background()
+
+	answer := func(answer Answer) (*Result, error) {
+		created, err := service.Create(ctx)
+		if err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+		if _, err := service.Issue(ctx, created.ID); err != nil {
+			t.Fatalf("Issue() failed: %v", err)
+		}
+		answer.ID, answer.FirstTaskHash, answer.SecondTaskHash = created.ID, "hash", "fingerprint"
+		return service.Answer(ctx, answer)
+	}
+	countRows := func(id string) int64 {
+		var count int64
+		store.db.Model(&models.ChallengeFeature{}).Where("challenge_id = ?", id).Count(&count)
+		return count
+	}
+
+	result, err := answer(Answer{Features: testFeatures()})
+	if err != nil {
+		t.Fatalf("Answer() failed: %v", err)
+	}
+	if count := countRows(result.Challenge.ID); count != 4 {
+		t.Errorf("Answer() failed. Expected 4 feature rows, got %d", count)
+	}
+
+	// Older SDK versions only send the alpha channel as a JSON array
+	legacy := "[0.5,0.1,0.2,0.8,0.5,0,0,0.5,0.5,0,0,0,0,0.05,0.3]"
+	result, err = answer(Answer{SecondTaskMetrics: legacy})
+	if err != nil {
+		t.Fatalf("Answer() failed for legacy metrics: %v", err)
+	}
+	var row models.ChallengeFeature
+	store.db.Where("challenge_id = ?", result.Challenge.ID).First(&row)
+	if row.Channel != "a" || row.Mean != 0.5 || row.Bin2 != 0.5 || result.Challenge.Metrics != legacy {
+		t.Errorf("Answer() failed. Unexpected legacy row %+v", row)
+	}
+
+	invalid := testFeatures()
+	invalid.Version = 0
+	for name, a := range map[string]Answer{
+		"missing":        {},
+		"invalid":        {Features: invalid},
+		"invalid legacy": {SecondTaskMetrics: "[]"},
+	} {
+		if _, err := answer(a); !errors.Is(err, ErrInvalidAnswer) {
+			t.Errorf("Answer() failed for %s features. Expected %v, got %v", name, ErrInvalidAnswer, err)
+		}
+	}
+}
+
+func TestService_Stability(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	service, _, store := newTestService(t, fake)
+	visitor := WithVisitor(context.Background(), "visitor")
+
+	answer := func(ctx context.Context, fingerprint string) *Result {
+		t.Helper()
+		created, err := service.Create(ctx)
+		if err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+		if _, err := service.Issue(ctx, created.ID); err != nil {
+			t.Fatalf("Issue() failed: %v", err)
+		}
+		fake.Advance(time.Second)
+		result, err := service.Answer(ctx, Answer{
+			ID:             created.ID,
+			FirstTaskHash:  created.ID,
+			SecondTaskHash: fingerprint,
+			Features:       testFeatures(),
+		})
+		if err != nil {
+			t.Fatalf("Answer() failed: %v", err)
+		}
+		return result
+	}
+
+	first := answer(visitor, "a")
+	if first.Challenge.VisitorID != "visitor" || first.Challenge.Stability.Sessions != 0 {
+		t.Errorf("Expected a first visit of the visitor, got %q %+v", first.Challenge.VisitorID, first.Challenge.Stability)
+	}
+	// Answers without a visitor are never compared
+	answer(context.Background(), "b")
+	second := answer(visitor, "b")
+	if s := second.Challenge.Stability; s.Sessions != 1 || s.FingerprintChanges != 1 || s.Randomized {
+		t.Errorf("Expected one fingerprint change, got %+v", s)
+	}
+
+	third := answer(visitor, "c")
+	if s := third.Challenge.Stability; s.Sessions != 2 || s.Fingerprints != 3 || !s.Randomized {
+		t.Errorf("Expected a randomized fingerprint, got %+v", s)
+	}
+	if math.Abs(third.Challenge.RiskScore-0.6) > 1e-9 {
+		t.Errorf("Expected risk 0.6 for a mismatch with a randomized fingerprint, got %v", third.Challenge.RiskScore)
+	}
+	stored, _ := store.GetChallenge(context.Background(), third.Challenge.ID)
+	if stored.VisitorID != "visitor" || !stored.Stability.Randomized || stored.Stability.FingerprintChanges != 2 {
+		t.Errorf("Answer() did not store the stability: %q %+v", stored.VisitorID, stored.Stability)
+	}
+}
+
+func TestService_Upload(t *testing.T) {
+	service, _, store := newTestService(t, nil)
+	service.cfg.UploadMaxBytes = 4096
+	ctx := context.Background()
+
+	issue := func() *Issued {
+		t.Helper()
+		created, _ := service.Create(ctx)
+		issued, err := service.Issue(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Issue() failed: %v", err)
+		}
+		return issued
+	}
+	// upload renders the first task of issued with one changed pixel and returns it as a data URL and its hash
+	upload := func(issued *Issued, size int) (string, string) {
+		t.Helper()
+		canvas, err := tasks.Render(issued.FirstTask, size, size)
+		if err != nil {
+			t.Fatalf("Render() failed: %v", err)
+		}
+		canvas.A.Pix[0] ^= 1
+		hashes, _ := canvas.CalculateHashes()
+		hash, _ := canvas.CalculateCombinedHash(hashes)
+		var encoded bytes.Buffer
+		if err := canvas.EncodePNG(&encoded, 1); err != nil {
+			t.Fatalf("EncodePNG() failed: %v", err)
+		}
+		return "data:image/png;base64," + base64.StdEncoding.EncodeToString(encoded.Bytes()), hash
+	}
+	answer := func(issued *Issued, canvas, hash string) (*Result, error) {
+		return service.Answer(ctx, Answer{
+			ID:             issued.Challenge.ID,
+			FirstTaskHash:  hash,
+			SecondTaskHash: "fingerprint",
+			Features:       testFeatures(),
+			Canvas:         canvas,
+		})
+	}
+
+	issued := issue()
+	if issued.UploadMaxBytes != 4096 {
+		t.Errorf("Issue() failed. Expected the upload limit 4096, got %d", issued.UploadMaxBytes)
+	}
+	dataURL, hash := upload(issued, 20)
+	if _, err := answer(issued, dataURL, hash); err != nil {
+		t.Fatalf("Answer() failed: %v", err)
+	}
+	var stored models.CanvasUpload
+	if err := store.db.First(&stored, "challenge_id = ?", issued.Challenge.ID).Error; err != nil {
+		t.Fatalf("Answer() did not store the upload: %v", err)
+	}
+	if stored.Hash != hash || !stored.HashMatches || stored.DiffPixels != 1 || len(stored.PNG) == 0 {
+		t.Errorf("Answer() failed. Unexpected upload %+v", stored)
+	}
+
+	// A rendering that does not hash to the reported hash is stored for investigation
+	issued = issue()
+	dataURL, _ = upload(issued, 20)
+	result, err := answer(issued, dataURL, "forged")
+	if err != nil || result.Challenge.Upload == nil || result.Challenge.Upload.HashMatches {
+		t.Errorf("Answer() failed. Expected a mismatching upload, got %+v (%v)", result, err)
+	}
+
+	wrongSize, _ := upload(issue(), 21)
+	for name, canvas := range map[string]string{
+		"too large":  "data:image/png;base64," + strings.Repeat("A", 4096),
+		"not a PNG":  "data:image/jpeg;base64,AAAA",
+		"not base64": "data:image/png;base64,!!!!",
+		"broken PNG": "data:image/png;base64,AAAA",
+		"wrong size": wrongSize,
+	} {
+		// Browsers blocking canvas readout upload such canvases, which must not cost them the verdict
+		issued := issue()
+		result, err := answer(issued, canvas, "hash")
+		if err != nil {
+			t.Errorf("Answer() failed for an upload %s: %v", name, err)
+			continue
+		}
+		if !errors.Is(result.UploadError, errInvalidUpload) || result.Challenge.Upload != nil || result.Token == "" {
+			t.Errorf("Answer() failed for an upload %s. Expected a verdict without the upload, got %v", name, result.UploadError)
+		}
+		var count int64
+		store.db.Model(&models.CanvasUpload{}).Where("challenge_id = ?", issued.Challenge.ID).Count(&count)
+		if count != 0 {
+			t.Errorf("Answer() failed for an upload %s. Expected it not to be stored", name)
+		}
+	}
+	// The SDK of a browser blocking readout uploads an empty data URL
+	if result, err := answer(issue(), "data:,", "hash"); err != nil || result.UploadError == nil {
+		t.Errorf("Answer() failed for an empty upload. Expected an upload error, got %v", err)
+	}
+
+	// Uploads are ignored when disabled
+	service.cfg.UploadMaxBytes = 0
+	issued = issue()
+	dataURL, hash = upload(issued, 20)
+	if result, err := answer(issued, dataURL, hash); err != nil || result.Challenge.Upload != nil {
+		t.Errorf("Answer() failed. Expected the upload to be ignored, got %+v (%v)", result, err)
+	}
+}
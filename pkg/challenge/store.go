@@ -0,0 +1,235 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package challenge
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Litebrowsers/donatello/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotFound is returned when a challenge or task does not exist.
+var ErrNotFound = errors.New("challenge: not found")
+
+// ErrExpired is returned when a challenge is issued or answered after it expired.
+var ErrExpired = errors.New("challenge: expired")
+
+// ErrAlreadyAnswered is returned when a challenge is issued or answered after it was answered.
+var ErrAlreadyAnswered = errors.New("challenge: already answered")
+
+// ErrNotIssued is returned when a challenge is answered before its tasks were issued.
+var ErrNotIssued = errors.New("challenge: not issued")
+
+// ErrInvalidAnswer is returned when an answer is malformed, e.g. its feature vector fails validation.
+var ErrInvalidAnswer = errors.New("challenge: invalid answer")
+
+// Store persists challenges and the second task pool.
+type Store interface {
+	// CreateChallenge stores a new challenge.
+	CreateChallenge(ctx context.Context, challenge *Challenge) error
+	// GetChallenge returns the challenge with the given ID or ErrNotFound.
+	GetChallenge(ctx context.Context, id string) (*Challenge, error)
+	// SaveIssued stores the tasks and expected hashes of an issued challenge. It returns ErrAlreadyAnswered if the
+	// challenge was answered in the meantime.
+	SaveIssued(ctx context.Context, challenge *Challenge) error
+	// SaveAnswer stores the answer fields, feature rows and canvas upload of an answered challenge. It returns
+	// ErrAlreadyAnswered if the challenge was answered in the meantime.
+	SaveAnswer(ctx context.Context, challenge *Challenge) error
+	// NoisePatternSeen reports whether a challenge other than exceptID showed the noise pattern.
+	NoisePatternSeen(ctx context.Context, pattern, exceptID string) (bool, error)
+	// VisitorHistory returns up to limit answered challenges of the visitor other than exceptID, most recent first.
+	VisitorHistory(ctx context.Context, visitorID, exceptID string, limit int) ([]Challenge, error)
+	// SecondTask returns the current second task or ErrNotFound if the pool is empty.
+	SecondTask(ctx context.Context) (*Task, error)
+	// CreateSecondTask adds task to the second task pool.
+	CreateSecondTask(ctx context.Context, task *Task) error
+}
+
+// secondTaskName is the name of second task pool entries in the tasks table.
+const secondTaskName = "secondTask"
+
+// Models returns the models whose tables GormStore uses: tasks, challenges, their feature rows and canvas uploads.
+func Models() []any {
+	return []any{&Task{}, &Challenge{}, &models.ChallengeFeature{}, &models.CanvasUpload{}}
+}
+
+// Migrate creates or updates the tables of Models.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(Models()...)
+}
+
+// GormStore is a Store backed by gorm. The tables of Models must be migrated, see Migrate.
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore creates a new GormStore.
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// CreateChallenge implements Store.
+func (s *GormStore) CreateChallenge(ctx context.Context, challenge *Challenge) error {
+	return s.db.WithContext(ctx).Create(challenge).Error
+}
+
+// GetChallenge implements Store.
+func (s *GormStore) GetChallenge(ctx context.Context, id string) (*Challenge, error) {
+	var challenge Challenge
+	err := s.db.WithContext(ctx).First(&challenge, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// SaveIssued implements Store. Only the issue fields are written and only if the challenge is unanswered, so an
+// issue that loaded the challenge before a concurrent answer was saved cannot overwrite it.
+func (s *GormStore) SaveIssued(ctx context.Context, challenge *Challenge) error {
+	result := s.db.WithContext(ctx).Model(&Challenge{}).
+		Where("id = ? AND answered_at IS NULL", challenge.ID).
+		Updates(map[string]interface{}{
+			"Task":                  challenge.Task,
+			"Profile":               challenge.Profile,
+			"SecondTaskID":          challenge.SecondTaskID,
+			"ExpectedHash":          challenge.ExpectedHash,
+			"ExpectedChannelHashes": challenge.ExpectedChannelHashes,
+			"ExpectedTileHashes":    challenge.ExpectedTileHashes,
+			"Fingerprint":           challenge.Fingerprint,
+			"IssuedAt":              challenge.IssuedAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAlreadyAnswered
+	}
+	return nil
+}
+
+// SaveAnswer implements Store. Only the answer fields are written, so a concurrent cleanup run is not overwritten
+// with stale values, and only if the challenge is unanswered, so of two concurrent answers only one succeeds. The
+// feature rows and the canvas upload of the challenge are replaced in the same transaction.
+func (s *GormStore) SaveAnswer(ctx context.Context, challenge *Challenge) error {
+	updates := map[string]interface{}{
+		"NoiseDetected":  challenge.NoiseDetected,
+		"ActualHash":     challenge.ActualHash,
+		"Fingerprint":    challenge.Fingerprint,
+		"Metrics":        challenge.Metrics,
+		"ProcessingTime": challenge.ProcessingTime,
+		"CopyMismatch":   challenge.CopyMismatch,
+		"JavaScript":     challenge.JavaScript,
+		"RiskScore":      challenge.RiskScore,
+		"AnsweredAt":     challenge.AnsweredAt,
+		// Embedded fields are addressed by column name
+		"timing_fetch":                  challenge.Timings.Fetch,
+		"timing_draw":                   challenge.Timings.Draw,
+		"timing_get_image_data":         challenge.Timings.GetImageData,
+		"timing_hash":                   challenge.Timings.Hash,
+		"timing_copy_test":              challenge.Timings.CopyTest,
+		"TimingAnomalies":               challenge.TimingAnomalies,
+		"MismatchScope":                 challenge.MismatchScope,
+		"MismatchChannels":              challenge.MismatchChannels,
+		"MismatchTiles":                 challenge.MismatchTiles,
+		"noise_class":                   challenge.Noise.Class,
+		"noise_pixels":                  challenge.Noise.Pixels,
+		"noise_channels":                challenge.Noise.Channels,
+		"noise_max_magnitude":           challenge.Noise.MaxMagnitude,
+		"noise_mean_magnitude":          challenge.Noise.MeanMagnitude,
+		"noise_magnitudes":              challenge.Noise.Magnitudes,
+		"noise_tiles":                   challenge.Noise.Tiles,
+		"noise_pattern":                 challenge.Noise.Pattern,
+		"noise_seeded":                  challenge.Noise.Seeded,
+		"Diff":                          challenge.Diff,
+		"Inconsistencies":               challenge.Inconsistencies,
+		"stability_sessions":            challenge.Stability.Sessions,
+		"stability_fingerprint_changes": challenge.Stability.FingerprintChanges,
+		"stability_noise_hash_changes":  challenge.Stability.NoiseHashChanges,
+		"stability_fingerprints":        challenge.Stability.Fingerprints,
+		"stability_randomized":          challenge.Stability.Randomized,
+	}
+	if challenge.NoiseHash != nil {
+		updates["NoiseHash"] = *challenge.NoiseHash
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(challenge).Where("answered_at IS NULL").Omit(clause.Associations).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAlreadyAnswered
+		}
+		if err := tx.Where("challenge_id = ?", challenge.ID).Delete(&models.ChallengeFeature{}).Error; err != nil {
+			return err
+		}
+		if len(challenge.Features) > 0 {
+			if err := tx.Omit(clause.Associations).Create(&challenge.Features).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("challenge_id = ?", challenge.ID).Delete(&models.CanvasUpload{}).Error; err != nil {
+			return err
+		}
+		if challenge.Upload == nil {
+			return nil
+		}
+		return tx.Create(challenge.Upload).Error
+	})
+}
+
+// NoisePatternSeen implements Store.
+func (s *GormStore) NoisePatternSeen(ctx context.Context, pattern, exceptID string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&Challenge{}).
+		Where("noise_pattern = ? AND id <> ?", pattern, exceptID).
+		Limit(1).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// VisitorHistory implements Store.
+func (s *GormStore) VisitorHistory(ctx context.Context, visitorID, exceptID string, limit int) ([]Challenge, error) {
+	var challenges []Challenge
+	err := s.db.WithContext(ctx).
+		Where("visitor_id = ? AND id <> ? AND answered_at IS NOT NULL", visitorID, exceptID).
+		Order("answered_at DESC").
+		Limit(limit).
+		Find(&challenges).Error
+	return challenges, err
+}
+
+// SecondTask implements Store.
+func (s *GormStore) SecondTask(ctx context.Context) (*Task, error) {
+	var task Task
+	err := s.db.WithContext(ctx).Where("name = ?", secondTaskName).First(&task).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// CreateSecondTask implements Store.
+func (s *GormStore) CreateSecondTask(ctx context.Context, task *Task) error {
+	task.Name = secondTaskName
+	return s.db.WithContext(ctx).Create(task).Error
+}
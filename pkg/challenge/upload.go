@@ -0,0 +1,88 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package challenge
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image/png"
+	"strings"
+
+	"github.com/Litebrowsers/donatello/internal/models"
+	"github.com/Litebrowsers/donatello/internal/tasks"
+)
+
+// errInvalidUpload is returned by checkUpload for uploads that can't be compared with the first task. Browsers that
+// block canvas readout upload such canvases, so they do not fail the answer.
+var errInvalidUpload = errors.New("invalid canvas upload")
+
+// uploadPrefix starts the PNG data URLs of canvas uploads, as returned by HTMLCanvasElement.toDataURL.
+const uploadPrefix = "data:image/png;base64,"
+
+// checkUpload decodes the canvas uploaded with an answer, hashes it and compares it with the first task of c. It
+// returns an error wrapping errInvalidUpload if the upload is too large, malformed or not a CanvasSize×CanvasSize
+// PNG.
+func (s *Service) checkUpload(c *Challenge, dataURL, actualHash string) (*models.CanvasUpload, error) {
+	if len(dataURL) > s.cfg.UploadMaxBytes {
+		return nil, fmt.Errorf("%w: larger than %d bytes", errInvalidUpload, s.cfg.UploadMaxBytes)
+	}
+	encoded, ok := strings.CutPrefix(dataURL, uploadPrefix)
+	if !ok {
+		return nil, fmt.Errorf("%w: not a PNG data URL", errInvalidUpload)
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidUpload, err)
+	}
+	// Check the size before decoding the pixels
+	config, err := png.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidUpload, err)
+	}
+	if config.Width != s.cfg.CanvasSize || config.Height != s.cfg.CanvasSize {
+		return nil, fmt.Errorf("%w: %d×%d, expected %d×%d", errInvalidUpload,
+			config.Width, config.Height, s.cfg.CanvasSize, s.cfg.CanvasSize)
+	}
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidUpload, err)
+	}
+
+	uploaded := tasks.FromImage(img)
+	hashes, err := uploaded.CalculateHashes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash canvas upload: %w", err)
+	}
+	hash, err := uploaded.CalculateCombinedHash(hashes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash canvas upload: %w", err)
+	}
+	expected, err := tasks.Render(c.Task, s.cfg.CanvasSize, s.cfg.CanvasSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render first task: %w", err)
+	}
+	_, changed, err := tasks.Diff(expected, uploaded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare canvas upload: %w", err)
+	}
+	return &models.CanvasUpload{
+		ChallengeID: c.ID,
+		PNG:         raw,
+		Hash:        hash,
+		HashMatches: hash == actualHash,
+		DiffPixels:  changed,
+	}, nil
+}
@@ -0,0 +1,30 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package challenge
+
+import "context"
+
+type visitorKey struct{}
+
+// WithVisitor returns a copy of ctx carrying visitorID. Challenges created with it are linked to the visitor, so
+// their fingerprints are compared with the visitor's previous sessions.
+func WithVisitor(ctx context.Context, visitorID string) context.Context {
+	return context.WithValue(ctx, visitorKey{}, visitorID)
+}
+
+// VisitorFromContext returns the visitor ID added with WithVisitor, or an empty string.
+func VisitorFromContext(ctx context.Context) string {
+	visitorID, _ := ctx.Value(visitorKey{}).(string)
+	return visitorID
+}
@@ -0,0 +1,247 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package guard protects net/http and gin services with Donatello verdicts.
+//
+// Visitors without a valid verdict are redirected to the Donatello verify page, which runs a challenge and
+// redirects back with the verdict token in the VerdictParam query parameter. The guard moves the token into a
+// cookie and decides per route, based on the verdict's risk score, whether to allow, step up or block the request.
+package guard
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"time"
+
+	"github.com/Litebrowsers/donatello/pkg/verdict"
+	"github.com/gin-gonic/gin"
+)
+
+// Defaults for Config.
+const (
+	DefaultCookieName = "donatello_verdict"
+	DefaultHeaderName = "X-Donatello-Verdict"
+	// VerdictParam is the query parameter carrying the token back from the verify page.
+	VerdictParam = "donatello_verdict"
+	// ReturnToParam is the query parameter telling the verify page where to redirect to.
+	ReturnToParam = "return_to"
+)
+
+// Action is what happens to a request.
+type Action int
+
+// Actions returned by a Policy.
+const (
+	Allow Action = iota
+	StepUp
+	Block
+)
+
+// Policy decides what happens to a request with a valid verdict.
+type Policy func(v verdict.Verdict) Action
+
+// Threshold returns a Policy that steps up requests with a risk score of at least stepUp and blocks those with
+// at least block.
+func Threshold(stepUp, block float64) Policy {
+	return func(v verdict.Verdict) Action {
+		switch {
+		case v.RiskScore >= block:
+			return Block
+		case v.RiskScore >= stepUp:
+			return StepUp
+		default:
+			return Allow
+		}
+	}
+}
+
+// Config configures a Guard.
+type Config struct {
+	// Key verifies verdict tokens. It must use the Donatello server's verdict secret.
+	Key *verdict.Key
+	// VerifyURL is the Donatello verify page, e.g. https://donatello.example.com/verify.
+	VerifyURL string
+	// CookieName defaults to DefaultCookieName.
+	CookieName string
+	// HeaderName defaults to DefaultHeaderName. Clients that can't use cookies send the token in this header.
+	HeaderName string
+	// StepUp handles requests a Policy steps up, e.g. with a second factor. They are blocked when it is nil.
+	StepUp http.Handler
+	// TrustedProxies are the reverse proxies whose X-Forwarded-Proto header is believed. Behind a TLS terminating
+	// proxy it must be listed, or the guard sees plain HTTP and neither marks its cookie Secure nor returns to https.
+	TrustedProxies []netip.Prefix
+	// Now returns the current time and defaults to time.Now.
+	Now func() time.Time
+}
+
+// Guard checks verdicts on incoming requests.
+type Guard struct {
+	cfg       Config
+	verifyURL *url.URL
+}
+
+// New creates a new Guard.
+func New(cfg Config) (*Guard, error) {
+	if cfg.Key == nil {
+		return nil, errors.New("guard: key is required")
+	}
+	verifyURL, err := url.Parse(cfg.VerifyURL)
+	if err != nil || !verifyURL.IsAbs() {
+		return nil, errors.New("guard: verify URL must be absolute")
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = DefaultCookieName
+	}
+	if cfg.HeaderName == "" {
+		cfg.HeaderName = DefaultHeaderName
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Guard{cfg: cfg, verifyURL: verifyURL}, nil
+}
+
+type contextKey struct{}
+
+// FromContext returns the verdict of a request that passed the guard.
+func FromContext(ctx context.Context) (verdict.Verdict, bool) {
+	v, ok := ctx.Value(contextKey{}).(verdict.Verdict)
+	return v, ok
+}
+
+// Handler returns an http.Handler that applies policy before calling next.
+func (g *Guard) Handler(policy Policy, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r, ok := g.check(w, r, policy); ok {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// Gin returns a gin.HandlerFunc that applies policy.
+func (g *Guard) Gin(policy Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, ok := g.check(c.Writer, c.Request, policy)
+		if !ok {
+			c.Abort()
+			return
+		}
+		c.Request = r
+		c.Next()
+	}
+}
+
+// check applies policy to r. It returns the request with the verdict in its context and true when the request
+// may proceed, or responds itself and returns false.
+func (g *Guard) check(w http.ResponseWriter, r *http.Request, policy Policy) (*http.Request, bool) {
+	// Coming back from the verify page: keep the token in a cookie and drop it from the URL
+	if token := r.URL.Query().Get(VerdictParam); token != "" && r.Method == http.MethodGet {
+		if v, err := g.cfg.Key.Verify(token, g.cfg.Now()); err == nil {
+			http.SetCookie(w, &http.Cookie{
+				Name:     g.cfg.CookieName,
+				Value:    token,
+				Path:     "/",
+				Expires:  time.Unix(v.ExpiresAt, 0),
+				HttpOnly: true,
+				Secure:   g.isHTTPS(r),
+				SameSite: http.SameSiteLaxMode,
+			})
+			clean := *r.URL
+			query := clean.Query()
+			query.Del(VerdictParam)
+			clean.RawQuery = query.Encode()
+			http.Redirect(w, r, clean.RequestURI(), http.StatusSeeOther)
+			return r, false
+		}
+	}
+
+	v, err := g.verdict(r)
+	if err != nil {
+		g.challenge(w, r)
+		return r, false
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), contextKey{}, v))
+	switch policy(v) {
+	case Allow:
+		return r, true
+	case StepUp:
+		if g.cfg.StepUp != nil {
+			g.cfg.StepUp.ServeHTTP(w, r)
+			return r, false
+		}
+	}
+	http.Error(w, "Forbidden", http.StatusForbidden)
+	return r, false
+}
+
+// verdict returns the verified verdict from the request header or cookie.
+func (g *Guard) verdict(r *http.Request) (verdict.Verdict, error) {
+	token := r.Header.Get(g.cfg.HeaderName)
+	if token == "" {
+		cookie, err := r.Cookie(g.cfg.CookieName)
+		if err != nil {
+			return verdict.Verdict{}, err
+		}
+		token = cookie.Value
+	}
+	return g.cfg.Key.Verify(token, g.cfg.Now())
+}
+
+// challenge redirects navigations to the verify page and rejects other requests with 401.
+func (g *Guard) challenge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Verification required", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	query.Del(VerdictParam)
+	returnTo := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path, RawQuery: query.Encode()}
+	if g.isHTTPS(r) {
+		returnTo.Scheme = "https"
+	}
+	target := *g.verifyURL
+	targetQuery := target.Query()
+	targetQuery.Set(ReturnToParam, returnTo.String())
+	target.RawQuery = targetQuery.Encode()
+	http.Redirect(w, r, target.String(), http.StatusSeeOther)
+}
+
+// isHTTPS reports whether r reached the service over HTTPS, directly or through a trusted TLS terminating proxy.
+func (g *Guard) isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || g.fromTrustedProxy(r) && r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// fromTrustedProxy reports whether r was sent by one of the trusted proxies.
+func (g *Guard) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range g.cfg.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
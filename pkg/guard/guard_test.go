@@ -0,0 +1,205 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package guard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Litebrowsers/donatello/pkg/verdict"
+	"github.com/gin-gonic/gin"
+)
+
+var now = time.Unix(1_700_000_000, 0)
+
+func newTestGuard(t *testing.T) (*Guard, *verdict.Key) {
+	t.Helper()
+	key, err := verdict.NewKey([]byte(strings.Repeat("k", verdict.MinSecretLength)))
+	if err != nil {
+		t.Fatalf("NewKey() failed: %v", err)
+	}
+	g, err := New(Config{
+		Key:       key,
+		VerifyURL: "https://donatello.example.com/verify",
+		StepUp: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}),
+		Now: func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	return g, key
+}
+
+func token(t *testing.T, key *verdict.Key, risk float64) string {
+	t.Helper()
+	signed, err := key.Sign(verdict.New("challenge-1", false, risk, now, time.Hour))
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	return signed
+}
+
+func TestGuard_Handler(t *testing.T) {
+	g, key := newTestGuard(t)
+	handler := g.Handler(Threshold(0.5, 0.8), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := FromContext(r.Context()); !ok {
+			t.Error("Expected the verdict in the request context")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		cookie   string
+		header   string
+		expected int
+	}{
+		{name: "no verdict", method: http.MethodGet, expected: http.StatusSeeOther},
+		{name: "no verdict on POST", method: http.MethodPost, expected: http.StatusUnauthorized},
+		{name: "forged cookie", method: http.MethodGet, cookie: "forged.token", expected: http.StatusSeeOther},
+		{name: "low risk cookie", method: http.MethodGet, cookie: token(t, key, 0.2), expected: http.StatusOK},
+		{name: "low risk header", method: http.MethodPost, header: token(t, key, 0.2), expected: http.StatusOK},
+		{name: "step up", method: http.MethodGet, cookie: token(t, key, 0.6), expected: http.StatusUnauthorized},
+		{name: "block", method: http.MethodGet, cookie: token(t, key, 0.9), expected: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "https://shop.example.com/cart?item=1", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				req.Header.Set(DefaultHeaderName, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+}
+
+func TestGuard_RedirectFlow(t *testing.T) {
+	g, key := newTestGuard(t)
+	handler := g.Handler(Threshold(0.5, 0.8), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Unverified visitors are sent to the verify page with the page to return to
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://shop.example.com/cart?item=1", nil))
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil || location.Host != "donatello.example.com" {
+		t.Fatalf("Expected a redirect to the verify page, got %q", rec.Header().Get("Location"))
+	}
+	returnTo := location.Query().Get(ReturnToParam)
+	if returnTo != "https://shop.example.com/cart?item=1" {
+		t.Errorf("Expected return_to to be the original page, got %q", returnTo)
+	}
+
+	// The verify page redirects back with the token, which is moved into a cookie
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, returnTo+"&"+VerdictParam+"="+token(t, key, 0.1), nil))
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/cart?item=1" {
+		t.Errorf("Expected a redirect to the clean URL, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != DefaultCookieName || !cookies[0].HttpOnly {
+		t.Errorf("Expected the verdict cookie to be set, got %v", cookies)
+	}
+}
+
+func TestGuard_ForwardedProto(t *testing.T) {
+	_, key := newTestGuard(t)
+	g, err := New(Config{
+		Key:            key,
+		VerifyURL:      "https://donatello.example.com/verify",
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		Now:            func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	handler := g.Handler(Threshold(0.5, 0.8), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		secure     bool
+	}{
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:4567", secure: true},
+		{name: "untrusted client", remoteAddr: "192.0.2.1:4567", secure: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			send := func(target string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, target, nil)
+				req.RemoteAddr = tt.remoteAddr
+				req.Header.Set("X-Forwarded-Proto", "https")
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				return rec
+			}
+
+			location, err := url.Parse(send("http://shop.example.com/cart").Header().Get("Location"))
+			if err != nil {
+				t.Fatalf("Expected a redirect to the verify page: %v", err)
+			}
+			returnTo, _ := url.Parse(location.Query().Get(ReturnToParam))
+			if (returnTo.Scheme == "https") != tt.secure {
+				t.Errorf("Expected return_to over https to be %v, got %q", tt.secure, returnTo)
+			}
+
+			cookies := send("http://shop.example.com/cart?" + VerdictParam + "=" + token(t, key, 0.1)).Result().Cookies()
+			if len(cookies) != 1 || cookies[0].Secure != tt.secure {
+				t.Errorf("Expected a verdict cookie with Secure %v, got %v", tt.secure, cookies)
+			}
+		})
+	}
+}
+
+func TestGuard_Gin(t *testing.T) {
+	g, key := newTestGuard(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/cart", g.Gin(Threshold(0.5, 0.8)), func(c *gin.Context) {
+		v, _ := FromContext(c.Request.Context())
+		c.String(http.StatusOK, v.ChallengeID)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/cart", nil)
+	req.Header.Set(DefaultHeaderName, token(t, key, 0))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "challenge-1" {
+		t.Errorf("Expected the handler to see the verdict, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cart", nil))
+	if rec.Code != http.StatusSeeOther {
+		t.Errorf("Expected unverified requests to be redirected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package verdict issues and verifies signed verdict tokens for answered challenges.
+//
+// A token is the base64url encoded JSON verdict followed by a dot and the base64url encoded HMAC-SHA256 of the
+// encoded verdict. Services sharing the secret with the Donatello server can verify tokens without calling it.
+package verdict
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Version is the version of the token format.
+const Version = 1
+
+// MinSecretLength is the minimum length of a signing secret in bytes.
+const MinSecretLength = 32
+
+// Errors returned by Verify.
+var (
+	ErrMalformed = errors.New("verdict: malformed token")
+	ErrSignature = errors.New("verdict: invalid signature")
+	ErrExpired   = errors.New("verdict: token expired")
+	ErrVersion   = errors.New("verdict: unsupported token version")
+)
+
+// Verdict is the outcome of an answered challenge.
+type Verdict struct {
+	Version       int     `json:"v"`
+	ChallengeID   string  `json:"cid"`
+	NoiseDetected bool    `json:"noise"`
+	RiskScore     float64 `json:"risk"`
+	IssuedAt      int64   `json:"iat"`
+	ExpiresAt     int64   `json:"exp"`
+}
+
+// New creates a Verdict issued at now and valid for ttl.
+func New(challengeID string, noiseDetected bool, riskScore float64, now time.Time, ttl time.Duration) Verdict {
+	return Verdict{
+		Version:       Version,
+		ChallengeID:   challengeID,
+		NoiseDetected: noiseDetected,
+		RiskScore:     riskScore,
+		IssuedAt:      now.Unix(),
+		ExpiresAt:     now.Add(ttl).Unix(),
+	}
+}
+
+// Expired reports whether v is expired at now.
+func (v Verdict) Expired(now time.Time) bool {
+	return now.Unix() >= v.ExpiresAt
+}
+
+// Key signs and verifies tokens.
+type Key struct {
+	secret []byte
+}
+
+// NewKey creates a Key from secret, which must be at least MinSecretLength bytes long.
+func NewKey(secret []byte) (*Key, error) {
+	if len(secret) < MinSecretLength {
+		return nil, fmt.Errorf("verdict: secret must be at least %d bytes, got %d", MinSecretLength, len(secret))
+	}
+	return &Key{secret: append([]byte(nil), secret...)}, nil
+}
+
+// Sign returns the token for v.
+func (k *Key) Sign(v Verdict) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(k.mac(encoded)), nil
+}
+
+// Verify checks the signature and expiry of token and returns its verdict.
+func (k *Key) Verify(token string, now time.Time) (Verdict, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return Verdict{}, ErrMalformed
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return Verdict{}, ErrMalformed
+	}
+	if !hmac.Equal(mac, k.mac(encoded)) {
+		return Verdict{}, ErrSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Verdict{}, ErrMalformed
+	}
+	var v Verdict
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return Verdict{}, ErrMalformed
+	}
+	if v.Version != Version {
+		return Verdict{}, ErrVersion
+	}
+	if v.Expired(now) {
+		return v, ErrExpired
+	}
+	return v, nil
+}
+
+// mac returns the HMAC-SHA256 of the encoded payload.
+func (k *Key) mac(encoded string) []byte {
+	h := hmac.New(sha256.New, k.secret)
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
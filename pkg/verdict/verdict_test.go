@@ -0,0 +1,71 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+package verdict
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte(strings.Repeat("s", MinSecretLength))
+
+func TestKey_SignVerify(t *testing.T) {
+	key, err := NewKey(testSecret)
+	if err != nil {
+		t.Fatalf("NewKey() failed: %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	v := New("challenge-1", true, 0.8, now, time.Minute)
+
+	token, err := key.Sign(v)
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	got, err := key.Verify(token, now.Add(30*time.Second))
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if got != v {
+		t.Errorf("Verify() failed. Expected %+v, got %+v", v, got)
+	}
+
+	if _, err := key.Verify(token, now.Add(time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify() of an expired token failed. Expected %v, got %v", ErrExpired, err)
+	}
+
+	other, _ := NewKey([]byte(strings.Repeat("o", MinSecretLength)))
+	if _, err := other.Verify(token, now); !errors.Is(err, ErrSignature) {
+		t.Errorf("Verify() with another key failed. Expected %v, got %v", ErrSignature, err)
+	}
+
+	payload, signature, _ := strings.Cut(token, ".")
+	tampered := strings.ToUpper(payload[:1]) + payload[1:]
+	if tampered == payload {
+		tampered = strings.ToLower(payload[:1]) + payload[1:]
+	}
+	if _, err := key.Verify(tampered+"."+signature, now); !errors.Is(err, ErrSignature) {
+		t.Errorf("Verify() of a tampered token failed. Expected %v, got %v", ErrSignature, err)
+	}
+	if _, err := key.Verify("garbage", now); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Verify() of garbage failed. Expected %v, got %v", ErrMalformed, err)
+	}
+}
+
+func TestNewKey_ShortSecret(t *testing.T) {
+	if _, err := NewKey([]byte("short")); err == nil {
+		t.Error("Expected NewKey() to reject a short secret")
+	}
+}
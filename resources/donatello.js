@@ -0,0 +1,466 @@
+/*
+# Donatello SDK v1
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+Runs a Donatello canvas challenge in hidden canvases and delivers the signed verdict token.
+
+    <script src="https://donatello.example.com/sdk/v1/donatello.js"></script>
+    <script>
+        Donatello.run({
+            onVerdict: (token, result) => { ... },
+            onError: (error) => { ... },
+        });
+    </script>
+
+Or without any code, calling the global function named in data-callback once the page has loaded:
+
+    <script src="https://donatello.example.com/sdk/v1/donatello.js" data-callback="onDonatelloVerdict" async></script>
+*/
+(function (global) {
+    'use strict';
+
+    const VERSION = '1';
+    const DEFAULT_CANVAS_SIZE = 20;
+    const COPY_TEST_TIMEOUT = 5000;
+    const DEFAULT_TILE_GRID = 4;
+    const MAX_DIFF_PIXELS = 4096;
+    const FEATURE_VERSION = 1;
+
+    const script = document.currentScript;
+    const defaultServer = script && script.src ? new URL(script.src).origin : '';
+
+    function channelFeatures(channelData, n) {
+        const N = n * n;
+        const C = Float32Array.from(channelData);
+
+        let sum = 0, sumSq = 0, min = 1e9, max = -1e9;
+        for (let i = 0; i < N; i++) {
+            const v = C[i];
+            sum += v;
+            sumSq += v * v;
+            if (v < min) min = v;
+            if (v > max) max = v;
+        }
+        const mean = sum / N;
+        const std = Math.sqrt(Math.max(0, sumSq / N - mean * mean));
+        const tmp = Array.from(C).sort((a, b) => a - b);
+        const median = tmp[Math.floor(N / 2)];
+
+        const bins = new Float32Array(8);
+        for (let i = 0; i < N; i++) bins[Math.min(7, Math.floor(C[i] * 8 / 256))] += 1;
+        for (let i = 0; i < 8; i++) bins[i] /= N;
+
+        let gsum = 0, gmax = 0;
+        for (let y = 0; y < n; y++) {
+            for (let x = 0; x < n; x++) {
+                const c = C[y * n + x];
+                const rx = (x + 1 < n) ? C[y * n + x + 1] : c;
+                const by = (y + 1 < n) ? C[(y + 1) * n + x] : c;
+                const gm = Math.hypot(rx - c, by - c);
+                gsum += gm;
+                if (gm > gmax) gmax = gm;
+            }
+        }
+        const gmean = gsum / N;
+
+        return {
+            mean: mean / 255,
+            std: std / 255,
+            min: min / 255,
+            max: max / 255,
+            median: median / 255,
+            histogram: Array.from(bins),
+            gradientMean: gmean / 255,
+            gradientMax: gmax / 255,
+        };
+    }
+
+    function featureVector(channels, n) {
+        const result = {};
+        for (const name of ['r', 'g', 'b', 'a']) {
+            result[name] = channelFeatures(channels[name], n);
+        }
+        return { version: FEATURE_VERSION, channels: result };
+    }
+
+    function drawRectangle(ctx, color, w, h, x, y) {
+        ctx.fillStyle = '#' + color;
+        ctx.fillRect(x, y, w, h);
+    }
+
+    function drawCircle(ctx, color, r, x, y) {
+        ctx.fillStyle = '#' + color;
+        ctx.beginPath();
+        ctx.arc(x, y, r, 0, Math.PI * 2);
+        ctx.fill();
+    }
+
+    function drawTriangle(ctx, color, x1, y1, x2, y2, x3, y3) {
+        ctx.fillStyle = '#' + color;
+        ctx.beginPath();
+        ctx.moveTo(x1, y1);
+        ctx.lineTo(x2, y2);
+        ctx.lineTo(x3, y3);
+        ctx.closePath();
+        ctx.fill();
+    }
+
+    function drawLine(ctx, color, x1, y1, x2, y2, thickness) {
+        ctx.strokeStyle = '#' + color;
+        ctx.lineWidth = thickness;
+        ctx.beginPath();
+        ctx.moveTo(x1, y1);
+        ctx.lineTo(x2, y2);
+        ctx.stroke();
+    }
+
+    function drawEllipse(ctx, color, rx, ry, x, y) {
+        ctx.fillStyle = '#' + color;
+        ctx.beginPath();
+        ctx.ellipse(x, y, rx, ry, 0, 0, Math.PI * 2);
+        ctx.fill();
+    }
+
+    function interpolateColor(color1, color2, progress) {
+        const r1 = parseInt(color1.substring(0, 2), 16);
+        const g1 = parseInt(color1.substring(2, 4), 16);
+        const b1 = parseInt(color1.substring(4, 6), 16);
+
+        const r2 = parseInt(color2.substring(0, 2), 16);
+        const g2 = parseInt(color2.substring(2, 4), 16);
+        const b2 = parseInt(color2.substring(4, 6), 16);
+
+        const step = 25;
+        let r = Math.round((r1 + progress * (r2 - r1)) / step) * step;
+        let g = Math.round((g1 + progress * (g2 - g1)) / step) * step;
+        let b = Math.round((b1 + progress * (b2 - b1)) / step) * step;
+
+        // Clamp values to 0-255
+        r = Math.max(0, Math.min(255, r));
+        g = Math.max(0, Math.min(255, g));
+        b = Math.max(0, Math.min(255, b));
+
+        return r.toString(16).padStart(2, '0') + g.toString(16).padStart(2, '0') + b.toString(16).padStart(2, '0');
+    }
+
+    function drawBackground(ctx, gridSize, color1, color2) {
+        const cellSize = ctx.canvas.width / gridSize;
+
+        for (let i = 0; i < gridSize; i++) {
+            for (let j = 0; j < gridSize; j++) {
+                const progress = (i * gridSize + j) / (gridSize * gridSize - 1);
+                const color = interpolateColor(color1, color2, progress);
+                drawRectangle(ctx, color, cellSize, cellSize, i * cellSize, j * cellSize);
+            }
+        }
+    }
+
+    function drawTask(ctx, taskString) {
+        if (!taskString) {
+            throw new Error('No task string received');
+        }
+        taskString.split(';').forEach(shapeStr => {
+            if (!shapeStr) return;
+            const parts = shapeStr.split(':');
+            const n = i => parseInt(parts[i]);
+
+            switch (parts[0]) {
+                case 'R':
+                    drawRectangle(ctx, parts[1], n(2), n(3), n(4), n(5));
+                    break;
+                case 'C':
+                    drawCircle(ctx, parts[1], n(2), n(3), n(4));
+                    break;
+                case 'T':
+                    drawTriangle(ctx, parts[1], n(2), n(3), n(4), n(5), n(6), n(7));
+                    break;
+                case 'L':
+                    drawLine(ctx, parts[1], n(2), n(3), n(4), n(5), n(6));
+                    break;
+                case 'E':
+                    drawEllipse(ctx, parts[1], n(2), n(3), n(4), n(5));
+                    break;
+                case 'X':
+                    drawBackground(ctx, n(1), parts[2], parts[3]);
+                    break;
+                default:
+                    console.warn('Donatello: unknown shape type', parts[0]);
+            }
+        });
+    }
+
+    function newTimings() {
+        return { fetch: 0, draw: 0, getImageData: 0, hash: 0, copyTest: 0 };
+    }
+
+    // timed runs fn and adds its duration in milliseconds to timings[step].
+    async function timed(timings, step, fn) {
+        const start = performance.now();
+        try {
+            return await fn();
+        } finally {
+            timings[step] += performance.now() - start;
+        }
+    }
+
+    async function sha256(uint8Array) {
+        const buf = await crypto.subtle.digest('SHA-256', uint8Array);
+        return Array.from(new Uint8Array(buf)).map(b => b.toString(16).padStart(2, '0')).join('');
+    }
+
+    async function getChannelHashes(canvas, timings = newTimings()) {
+        const ctx = canvas.getContext('2d', { willReadFrequently: true });
+        const data = await timed(timings, 'getImageData', () => ctx.getImageData(0, 0, canvas.width, canvas.height).data);
+
+        const size = canvas.width * canvas.height;
+        const channels = {
+            r: new Uint8Array(size),
+            g: new Uint8Array(size),
+            b: new Uint8Array(size),
+            a: new Uint8Array(size),
+        };
+        for (let i = 0; i < size; i++) {
+            channels.r[i] = data[i * 4];
+            channels.g[i] = data[i * 4 + 1];
+            channels.b[i] = data[i * 4 + 2];
+            channels.a[i] = data[i * 4 + 3];
+        }
+
+        const [r, g, b, a] = await timed(timings, 'hash', () => Promise.all([
+            sha256(channels.r), sha256(channels.g), sha256(channels.b), sha256(channels.a),
+        ]));
+        return { hashes: { r, g, b, a }, channels };
+    }
+
+    // tileHashes splits the canvas into grid×grid tiles and hashes each tile's RGBA pixels in row-major tile order.
+    // Tile edges are at floor(i * size / grid), matching the server.
+    async function tileHashes(canvas, grid, timings = newTimings()) {
+        const ctx = canvas.getContext('2d', { willReadFrequently: true });
+        const tiles = [];
+        for (let ty = 0; ty < grid; ty++) {
+            const y0 = Math.floor(ty * canvas.height / grid);
+            const y1 = Math.floor((ty + 1) * canvas.height / grid);
+            for (let tx = 0; tx < grid; tx++) {
+                const x0 = Math.floor(tx * canvas.width / grid);
+                const x1 = Math.floor((tx + 1) * canvas.width / grid);
+                tiles.push(await timed(timings, 'getImageData', () => ctx.getImageData(x0, y0, x1 - x0, y1 - y0).data));
+            }
+        }
+        return timed(timings, 'hash', () => Promise.all(tiles.map(data => sha256(new Uint8Array(data.buffer)))));
+    }
+
+    async function totalHash(hashes) {
+        return sha256(new TextEncoder().encode(hashes.r + hashes.g + hashes.b + hashes.a));
+    }
+
+    // calculateNoiseFingerprint hashes the per-channel absolute difference between the expected and rendered pixels.
+    async function calculateNoiseFingerprint(expected, client) {
+        const size = expected.r.length;
+        const combinedDiff = new Uint8Array(size * 4);
+        ['r', 'g', 'b', 'a'].forEach((channel, c) => {
+            for (let i = 0; i < size; i++) {
+                combinedDiff[c * size + i] = Math.abs(expected[channel][i] - client[channel][i]);
+            }
+        });
+        return sha256(combinedDiff);
+    }
+
+    // sparseDiff lists the pixels that differ from the prediction as flat [index, dr, dg, db, da, ...] with the signed
+    // differences actual - expected. It returns undefined when more than MAX_DIFF_PIXELS pixels differ.
+    function sparseDiff(expected, client) {
+        const diff = [];
+        for (let i = 0; i < expected.r.length; i++) {
+            const dr = client.r[i] - expected.r[i];
+            const dg = client.g[i] - expected.g[i];
+            const db = client.b[i] - expected.b[i];
+            const da = client.a[i] - expected.a[i];
+            if (dr || dg || db || da) {
+                if (diff.length / 5 >= MAX_DIFF_PIXELS) return undefined;
+                diff.push(i, dr, dg, db, da);
+            }
+        }
+        return diff;
+    }
+
+    function createCanvas(size) {
+        const canvas = document.createElement('canvas');
+        canvas.width = size;
+        canvas.height = size;
+        return canvas;
+    }
+
+    // copyMismatch reports whether the canvas hashes differently after a round trip through a data URL.
+    function copyMismatch(canvas, expectedTotalHash) {
+        return new Promise((resolve, reject) => {
+            const img = new Image();
+            const timeout = setTimeout(() => reject(new Error('Canvas copy test timed out')), COPY_TEST_TIMEOUT);
+            img.onload = async () => {
+                clearTimeout(timeout);
+                try {
+                    const copy = createCanvas(canvas.width);
+                    copy.getContext('2d', { willReadFrequently: true }).drawImage(img, 0, 0);
+                    const { hashes } = await getChannelHashes(copy);
+                    resolve(expectedTotalHash !== await totalHash(hashes));
+                } catch (error) {
+                    reject(error);
+                }
+            };
+            img.onerror = () => {
+                clearTimeout(timeout);
+                reject(new Error('Image loading failed for copy test'));
+            };
+            img.src = canvas.toDataURL();
+        }).catch(error => {
+            console.warn('Donatello: canvas copy test failed', error);
+            return true; // Assume mismatch on error
+        });
+    }
+
+    // canvasUpload returns the canvas as a PNG data URL, or undefined if it is longer than maxBytes.
+    function canvasUpload(canvas, maxBytes) {
+        const dataURL = canvas.toDataURL('image/png');
+        return dataURL.length <= maxBytes ? dataURL : undefined;
+    }
+
+    // predict runs the predictor worker. Workers can't be started cross-origin, so the script is loaded into a Blob.
+    async function predict(server, taskString, size) {
+        const response = await fetch(server + '/predictor.worker.js');
+        if (!response.ok) {
+            throw new Error('Failed to load predictor worker: ' + response.status);
+        }
+        const url = URL.createObjectURL(await response.blob());
+        const worker = new Worker(url);
+        try {
+            return await new Promise((resolve, reject) => {
+                worker.onmessage = e => resolve(e.data);
+                worker.onerror = e => reject(new Error('Predictor worker failed: ' + e.message));
+                worker.postMessage({ taskString, width: size, height: size });
+            });
+        } finally {
+            worker.terminate();
+            URL.revokeObjectURL(url);
+        }
+    }
+
+    async function request(url, options) {
+        const response = await fetch(url, options);
+        const data = await response.json();
+        if (!response.ok) {
+            throw new Error(data.error || 'Request failed: ' + response.status);
+        }
+        return data;
+    }
+
+    /**
+     * Runs a challenge and resolves with its result.
+     *
+     * Options:
+     *   server       Donatello origin, defaults to the origin the SDK was loaded from.
+     *   challengeId  existing challenge to answer, a new one is created when omitted.
+     *   visitorCookie set to false when the page's origin is only allowed by "*" on the server, which does not
+     *                accept credentials from unlisted origins. By default the visitor cookie is sent.
+     *   uploadCanvas set to false to never upload the first canvas. By default it is uploaded for investigation when
+     *                the server accepts uploads and the rendering differs from the prediction.
+     *   onVerdict    called with the verdict token and the result.
+     *   onError      called with the error; the returned promise then resolves with null instead of rejecting.
+     *
+     * The result has the fields token, challengeId, noiseDetected, riskScore, firstHash, secondHash, duration (ms)
+     * and timings, the durations of the individual steps (ms) that are also reported to the server.
+     */
+    async function run(options) {
+        options = options || {};
+        const server = (options.server || defaultServer).replace(/\/$/, '');
+        const startTime = performance.now();
+        const timings = newTimings();
+
+        try {
+            let id = options.challengeId;
+            if (!id) {
+                // The visitor cookie links the challenges of this browser when enabled on the server
+                const credentials = options.visitorCookie === false ? 'same-origin' : 'include';
+                id = (await request(server + '/challenge/new', { method: 'POST', credentials })).id;
+            }
+            const data = await timed(timings, 'fetch', () => request(server + '/challenge?id=' + encodeURIComponent(id)));
+            const size = data.canvas_size || DEFAULT_CANVAS_SIZE;
+
+            const canvas1 = createCanvas(size);
+            const canvas2 = createCanvas(size);
+            await timed(timings, 'draw', () => {
+                drawTask(canvas1.getContext('2d', { willReadFrequently: true }), data.first_task);
+                drawTask(canvas2.getContext('2d', { willReadFrequently: true }), data.second_task);
+            });
+
+            const expected = await predict(server, data.first_task, size);
+
+            const first = await getChannelHashes(canvas1, timings);
+            const totalHash1 = await timed(timings, 'hash', () => totalHash(first.hashes));
+            const tiles1 = await tileHashes(canvas1, data.tile_grid || DEFAULT_TILE_GRID, timings);
+            const diffHash = await timed(timings, 'hash', () => calculateNoiseFingerprint(expected.channels, first.channels));
+            const mismatch = await timed(timings, 'copyTest', () => copyMismatch(canvas1, totalHash1));
+            const second = await getChannelHashes(canvas2, timings);
+            const diff1 = sparseDiff(expected.channels, first.channels);
+            // An undefined diff means too many pixels differ
+            const differs = diff1 === undefined || diff1.length > 0;
+            const upload = options.uploadCanvas !== false && data.upload_max_bytes > 0 && differs
+                ? canvasUpload(canvas1, data.upload_max_bytes) : undefined;
+
+            const answer = await request(server + '/challenge', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    id: data.id,
+                    totalHash1: totalHash1,
+                    channelHashes1: first.hashes,
+                    tileHashes1: tiles1,
+                    diff1: diff1,
+                    diffTruncated: diff1 === undefined,
+                    diffHash: diffHash,
+                    totalHash2: second.hashes.a,
+                    features2: featureVector(second.channels, size),
+                    copyMismatch: mismatch,
+                    timings: timings,
+                    canvas1: upload,
+                }),
+            });
+
+            const result = {
+                token: answer.token,
+                challengeId: data.id,
+                noiseDetected: answer.noise_detected,
+                riskScore: answer.risk_score,
+                firstHash: totalHash1,
+                secondHash: second.hashes.a,
+                duration: performance.now() - startTime,
+                timings: timings,
+            };
+            if (options.onVerdict) {
+                options.onVerdict(answer.token, result);
+            }
+            return result;
+        } catch (error) {
+            if (options.onError) {
+                options.onError(error);
+                return null;
+            }
+            throw error;
+        }
+    }
+
+    global.Donatello = { version: VERSION, run };
+
+    const callback = script && script.dataset.callback;
+    if (callback) {
+        const start = () => run({
+            visitorCookie: script.dataset.visitorCookie !== 'false',
+            onVerdict: (token, result) => global[callback](token, result),
+            onError: error => console.error('Donatello: challenge failed', error),
+        });
+        if (document.readyState === 'loading') {
+            document.addEventListener('DOMContentLoaded', start);
+        } else {
+            start();
+        }
+    }
+})(window);
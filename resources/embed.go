@@ -0,0 +1,22 @@
+/*
+# Donatello
+
+Copyright © 2025 Litebrowsers
+Licensed under a Proprietary License
+
+This software is the confidential and proprietary information of Litebrowsers
+Unauthorized copying, redistribution, or use is prohibited.
+For licensing inquiries, contact:
+vera cohopie at gmail dot com
+thor betson at gmail dot com
+*/
+
+// Package resources embeds the web resources served to clients.
+package resources
+
+import "embed"
+
+// FS contains the web resources. The HTML pages are html/templates.
+//
+//go:embed index.html verify.html dashboard.html dashboard_challenge.html predictor.worker.js donatello.js
+var FS embed.FS
@@ -86,6 +86,29 @@ class CanvasPredictor {
         }
     }
 
+    // drawChessboard mirrors tasks.GenerateChessboard: a grid of cells whose colors step through a gradient.
+    drawChessboard(gridSize, color1, color2) {
+        if (gridSize <= 0) return;
+        let cellSize = Math.floor(this.width / gridSize);
+        if (cellSize <= 0) cellSize = 2;
+        for (let i = 0; i < gridSize; i++) {
+            for (let j = 0; j < gridSize; j++) {
+                const progress = (i * gridSize + j) / (gridSize * gridSize - 1);
+                const color = this.interpolateColor(color1, color2, progress);
+                this.drawRectangle({ color, w: cellSize, h: cellSize, x: i * cellSize, y: j * cellSize });
+            }
+        }
+    }
+
+    interpolateColor(color1, color2, progress) {
+        const c1 = this.hexToRGBA(color1);
+        const c2 = this.hexToRGBA(color2);
+        const step = 25;
+        const channel = (a, b) => Math.max(0, Math.min(255, Math.round((a + progress * (b - a)) / step) * step));
+        return [channel(c1.r, c2.r), channel(c1.g, c2.g), channel(c1.b, c2.b)]
+            .map(v => v.toString(16).padStart(2, '0')).join('');
+    }
+
     drawShapes(taskString) {
         const shapes = taskString.split(';');
         shapes.forEach(shapeStr => {
@@ -100,6 +123,9 @@ class CanvasPredictor {
                 case 'L':
                     this.drawLine({ color, x1: parseInt(parts[2]), y1: parseInt(parts[3]), x2: parseInt(parts[4]), y2: parseInt(parts[5]), thickness: parseInt(parts[6]) });
                     break;
+                case 'X':
+                    this.drawChessboard(parseInt(parts[1]), parts[2], parts[3]);
+                    break;
             }
         });
     }